@@ -0,0 +1,506 @@
+package footrest
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type bulkReqElem struct {
+	ID     string            `json:"id"` // referred by later elements as "$ops.{id}.{column}"
+	Method string            `json:"method"`
+	Table  string            `json:"table"`
+	Where  map[string]string `json:"where"`
+	Values map[string]any    `json:"values"`
+
+	WhereExpr json.RawMessage `json:"whereExpr"` // S-expr string or JSON-array form, ANDed with Where
+
+	Returning bool `json:"returning"` // POST returns inserted rows
+}
+type bulkReq []bulkReqElem
+
+// opsRefPrefix prefixes a reference to a column of the first row of an earlier element.
+// A literal "$ops." is escaped as "$$ops.".
+const opsRefPrefix = "$ops."
+
+// BulkOptions controls FootREST.Bulk.
+type BulkOptions struct {
+	// ContinueOnError rolls back only the failing element to a savepoint and goes on.
+	ContinueOnError bool
+}
+
+type bulkResult struct {
+	Index        int      `json:"index"`
+	ID           string   `json:"id,omitempty"`
+	Method       string   `json:"method"`
+	Table        string   `json:"table"`
+	RowsAffected int64    `json:"rowsAffected"`
+	Records      *records `json:"records,omitempty"`
+	Error        string   `json:"error,omitempty"`
+}
+
+// BulkError points to the failing element of a bulk request.
+type BulkError struct {
+	Index  int    `json:"index"`
+	Method string `json:"method"`
+	Table  string `json:"table"`
+	Err    error  `json:"-"`
+}
+
+func (e BulkError) Error() string {
+	return fmt.Sprintf("bulk[%d] %s %s: %v", e.Index, e.Method, e.Table, e.Err)
+}
+
+func (e BulkError) Unwrap() error {
+	return e.Err
+}
+
+func (e BulkError) MarshalJSON() ([]byte, error) {
+	type bulkError BulkError
+	return json.Marshal(struct {
+		bulkError
+		Message string `json:"message"`
+	}{
+		bulkError: bulkError(e),
+		Message:   e.Err.Error(),
+	})
+}
+
+// Bulk executes all elements of b in one transaction and returns a result per element.
+func (r *FootREST) Bulk(ctx context.Context, b bulkReq, opts BulkOptions) ([]bulkResult, error) {
+	if r.conn == nil {
+		return nil, nil
+	}
+
+	if opts.ContinueOnError && (r.dialect.Savepoint == nil || r.dialect.RollbackToSavepoint == nil) {
+		return nil, errors.New("bulk: savepoints are not supported")
+	}
+
+	for _, m := range b {
+		if r.isValidName(m.Table) {
+			_ = r.loadSchemas(m.Table) // an error is of the element
+
+			// statements do not change by resolving references, which are bound as args
+			if strStmts, _, err := r.buildBulkStmts(m); err == nil {
+				r.prepareCache(ctx, strStmts...)
+			}
+		}
+	}
+
+	var results []bulkResult
+	err := r.inTx(ctx, true, func(tx *sql.Tx) error {
+		var err error
+		results, err = r.bulk(ctx, tx, b, opts)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, m := range b {
+		r.invalidate(m.Table)
+	}
+
+	return results, nil
+}
+
+func (r *FootREST) bulk(ctx context.Context, tx *sql.Tx, b bulkReq, opts BulkOptions) ([]bulkResult, error) {
+	results := make([]bulkResult, 0, len(b))
+	refs := make(map[string]*records)
+
+	for i, m := range b {
+		sp := "footrest_bulk" + strconv.Itoa(i)
+		if opts.ContinueOnError {
+			_, err := tx.ExecContext(ctx, r.dialect.Savepoint(sp))
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		var result bulkResult
+		m, err := resolveOpsRefs(m, refs)
+		if err == nil {
+			result, err = r.bulkElem(ctx, tx, m)
+		} else {
+			result = bulkResult{Method: strings.ToUpper(m.Method), Table: m.Table}
+		}
+		result.Index = i
+		result.ID = m.ID
+		if err == nil && m.ID != "" {
+			if _, dup := refs[strings.ToUpper(m.ID)]; dup {
+				err = errors.Errorf("duplicate id %q", m.ID)
+			} else if result.Method == "POST" && r.dialect.Returning == nil {
+				// the records are m.Values, not inserted rows
+				refs[strings.ToUpper(m.ID)] = nil
+			} else {
+				refs[strings.ToUpper(m.ID)] = result.Records
+			}
+		}
+		if result.Records != nil && !m.Returning {
+			result.Records = nil
+		}
+		r.metrics.bulk(m.Method, err)
+		if err != nil {
+			if !opts.ContinueOnError {
+				return nil, BulkError{Index: i, Method: result.Method, Table: result.Table, Err: err}
+			}
+
+			_, rerr := tx.ExecContext(ctx, r.dialect.RollbackToSavepoint(sp))
+			if rerr != nil {
+				return nil, BulkError{Index: i, Method: result.Method, Table: result.Table, Err: rerr}
+			}
+			result.Error = err.Error()
+		}
+
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+func (r *FootREST) bulkElem(ctx context.Context, tx *sql.Tx, m bulkReqElem) (bulkResult, error) {
+	method := strings.ToUpper(m.Method)
+	result := bulkResult{
+		Method: method,
+		Table:  m.Table,
+	}
+
+	if method == "POST" && (m.ID != "" || m.Returning) {
+		return r.bulkPostReturning(ctx, tx, m, result)
+	}
+
+	strStmts, argss, err := r.buildBulkStmts(m)
+	if err != nil {
+		return result, err
+	}
+
+	for i := range strStmts {
+		ra, err := r.execTx(ctx, tx, strStmts[i], argss[i])
+		if err != nil {
+			return result, err
+		}
+		result.RowsAffected += ra
+	}
+
+	if result.RowsAffected == 0 && method == "UPSERT" {
+		strStmts, argss, err = r.BuildPostStmts(m.Table, m.Values)
+		if err != nil {
+			return result, err
+		}
+
+		for i := range strStmts {
+			ra, err := r.execTx(ctx, tx, strStmts[i], argss[i])
+			if err != nil {
+				return result, err
+			}
+			result.RowsAffected += ra
+		}
+	}
+
+	return result, nil
+}
+
+// buildBulkStmts builds statements of m, except for POST keeping inserted rows.
+func (r *FootREST) buildBulkStmts(m bulkReqElem) ([]string, [][]any, error) {
+	wexpr, err := WhereExpr(m.WhereExpr)
+	if err != nil {
+		return nil, nil, err
+	}
+	where := andWhere(r.mapWhere(m.Where), wexpr)
+
+	switch strings.ToUpper(m.Method) {
+	case "POST":
+		return r.BuildPostStmts(m.Table, m.Values)
+
+	case "PUT", "UPSERT":
+		strStmt, args, err := r.BuildPutStmt(m.Table, m.Values, where)
+		return []string{strStmt}, [][]any{args}, err
+
+	case "DELETE":
+		strStmt, args, err := r.BuildDeleteStmt(m.Table, where)
+		return []string{strStmt}, [][]any{args}, err
+	}
+
+	return nil, nil, errors.Errorf("unsupported method %q", m.Method)
+}
+
+// bulkPostReturning inserts m.Values and keeps inserted rows in result.Records.
+//
+// If the dialect does not support returning, the rows are m.Values themselves.
+func (r *FootREST) bulkPostReturning(ctx context.Context, tx *sql.Tx, m bulkReqElem, result bulkResult) (bulkResult, error) {
+	returning := r.dialect.Returning != nil
+
+	strStmts, argss, err := r.buildPostStmts(m.Table, m.Values, true, returning)
+	if err != nil {
+		return result, err
+	}
+
+	var rr records
+	for i := range strStmts {
+		if !returning {
+			ra, err := r.execTx(ctx, tx, strStmts[i], argss[i])
+			if err != nil {
+				return result, err
+			}
+			result.RowsAffected += ra
+			continue
+		}
+
+		srr, err := r.queryTx(ctx, tx, strStmts[i], argss[i])
+		if err != nil {
+			return result, err
+		}
+		if rr.columns == nil {
+			rr.columns = srr.columns
+		}
+		rr.rows = append(rr.rows, srr.rows...)
+		result.RowsAffected += int64(len(srr.rows))
+	}
+
+	if !returning {
+		for c := range m.Values {
+			rr.columns = append(rr.columns, c)
+		}
+		sort.Strings(rr.columns)
+
+		row := make([]any, 0, len(rr.columns))
+		for _, c := range rr.columns {
+			row = append(row, m.Values[c])
+		}
+		rr.rows = [][]any{row}
+	}
+
+	result.Records = &rr
+
+	return result, nil
+}
+
+// resolveOpsRefs replaces "$ops.{id}.{column}" in m.Values, m.Where and m.WhereExpr by values of earlier results,
+// and "$$ops." by "$ops.".
+func resolveOpsRefs(m bulkReqElem, refs map[string]*records) (bulkReqElem, error) {
+	if len(m.Values) > 0 {
+		values := make(map[string]any, len(m.Values))
+		for k, v := range m.Values {
+			if s, ok := v.(string); ok {
+				if strings.HasPrefix(s, "$"+opsRefPrefix) {
+					v = s[1:]
+				} else if strings.HasPrefix(s, opsRefPrefix) {
+					rv, err := lookupOpsRef(s, refs)
+					if err != nil {
+						return m, err
+					}
+					v = rv
+				}
+			}
+			values[k] = v
+		}
+		m.Values = values
+	}
+
+	if len(m.Where) > 0 {
+		where := make(map[string]string, len(m.Where))
+		for k, v := range m.Where {
+			v, err := resolveWhereRefs(v, refs)
+			if err != nil {
+				return m, err
+			}
+			where[k] = v
+		}
+		m.Where = where
+	}
+
+	if bytes.Contains(m.WhereExpr, []byte(opsRefPrefix)) {
+		w, err := WhereExpr(m.WhereExpr)
+		if err != nil {
+			return m, err
+		}
+		w, err = resolveWhereRefs(w, refs)
+		if err != nil {
+			return m, err
+		}
+		m.WhereExpr, err = json.Marshal(w)
+		if err != nil {
+			return m, err
+		}
+	}
+
+	return m, nil
+}
+
+// resolveWhereRefs replaces all the references in a where w by literals of their values,
+// and "$$ops." by "$ops.".
+// A quoted reference like '$ops.p.id' is replaced as a whole.
+func resolveWhereRefs(w string, refs map[string]*records) (string, error) {
+	buf := strings.Builder{}
+	for {
+		idx := strings.Index(w, opsRefPrefix)
+		if idx == -1 {
+			buf.WriteString(w)
+			return buf.String(), nil
+		}
+
+		if idx > 0 && w[idx-1] == '$' {
+			buf.WriteString(w[:idx-1] + opsRefPrefix)
+			w = w[idx+len(opsRefPrefix):]
+			continue
+		}
+
+		end := idx + len(opsRefPrefix)
+		for end < len(w) && isOpsRefChar(w[end]) {
+			end++
+		}
+		ref := w[idx:end]
+
+		rv, err := lookupOpsRef(ref, refs)
+		if err != nil {
+			return "", err
+		}
+		lit, err := sexprLiteral(rv)
+		if err != nil {
+			return "", errors.Wrapf(err, "%s", ref)
+		}
+
+		start := idx
+		if idx > 0 && w[idx-1] == '\'' && end < len(w) && w[end] == '\'' {
+			start, end = start-1, end+1
+		}
+		buf.WriteString(w[:start] + lit)
+		w = w[end:]
+	}
+}
+
+// isOpsRefChar reports whether c can be in {id}.{column} of a reference.
+func isOpsRefChar(c byte) bool {
+	return c == '.' || c == '_' || c == '-' ||
+		('0' <= c && c <= '9') || ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z')
+}
+
+func lookupOpsRef(ref string, refs map[string]*records) (any, error) {
+	path := strings.SplitN(strings.TrimPrefix(ref, opsRefPrefix), ".", 2)
+	if len(path) != 2 {
+		return nil, errors.Errorf("invalid reference %q", ref)
+	}
+
+	rr, found := refs[strings.ToUpper(path[0])]
+	if !found {
+		return nil, errors.Errorf("reference %q: no such id before", ref)
+	}
+	if rr == nil || len(rr.rows) == 0 {
+		return nil, errors.Errorf("reference %q: no rows returned", ref)
+	}
+
+	for i, c := range rr.columns {
+		if strings.EqualFold(c, path[1]) {
+			return rr.rows[0][i], nil
+		}
+	}
+
+	return nil, errors.Errorf("reference %q: no such column", ref)
+}
+
+// sexprLiteral formats v as a literal in the where notation.
+func sexprLiteral(v any) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "NULL", nil
+	case bool:
+		return strings.ToUpper(strconv.FormatBool(v)), nil
+	case int:
+		return "#" + strconv.FormatInt(int64(v), 10), nil
+	case int32:
+		return "#" + strconv.FormatInt(int64(v), 10), nil
+	case int64:
+		return "#" + strconv.FormatInt(v, 10), nil
+	case float64:
+		if v == float64(int64(v)) {
+			return "#" + strconv.FormatInt(int64(v), 10), nil
+		}
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case json.Number:
+		return v.String(), nil
+	case string:
+		return "'" + strings.ReplaceAll(v, "'", "''") + "'", nil
+	}
+
+	return "", errors.Errorf("%T can not be a literal", v)
+}
+
+// execTx executes a statement in tx and returns the number of affected rows.
+func (r *FootREST) execTx(ctx context.Context, tx *sql.Tx, strStmt string, args []any) (int64, error) {
+	encArgs, err := r.encodeArgs(args)
+	if err != nil {
+		return 0, err
+	}
+
+	stmt, done, err := r.prepare(ctx, tx, strStmt)
+	if err != nil {
+		return 0, err
+	}
+	defer done()
+
+	start := time.Now()
+	result, err := stmt.ExecContext(ctx, encArgs...)
+	if err != nil {
+		r.traceStmt(ctx, strStmt, args, start, 0, err)
+		return 0, err
+	}
+
+	ra, err := result.RowsAffected()
+	r.traceStmt(ctx, strStmt, args, start, ra, err)
+	if err != nil {
+		return 0, err
+	}
+	r.metrics.exec(strStmt, time.Since(start), ra)
+
+	return ra, nil
+}
+
+// queryer is *sql.DB or *sql.Tx.
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
+}
+
+// queryTx queries a statement in tx and reads all the rows.
+func (r *FootREST) queryTx(ctx context.Context, tx *sql.Tx, strStmt string, args []any) (records, error) {
+	return r.query(ctx, tx, strStmt, args)
+}
+
+// query queries a statement and reads all the rows.
+func (r *FootREST) query(ctx context.Context, q queryer, strStmt string, args []any) (records, error) {
+	encArgs, err := r.encodeArgs(args)
+	if err != nil {
+		return records{}, err
+	}
+
+	stmt, done, err := r.prepare(ctx, q, strStmt)
+	if err != nil {
+		return records{}, err
+	}
+	defer done()
+
+	start := time.Now()
+	rows, err := stmt.QueryContext(ctx, encArgs...)
+	if err != nil {
+		r.traceStmt(ctx, strStmt, args, start, 0, err)
+		return records{}, err
+	}
+	defer rows.Close()
+
+	rr, err := r.scanRecords(rows)
+	r.traceStmt(ctx, strStmt, args, start, int64(len(rr.rows)), err)
+	if err != nil {
+		return records{}, err
+	}
+	r.metrics.query(strStmt, time.Since(start), int64(len(rr.rows)))
+
+	return rr, nil
+}
@@ -0,0 +1,170 @@
+package footrest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	echo "github.com/labstack/echo/v4"
+)
+
+// HeaderIfNoneMatch is a request header of entity tags a client has, for 304 Not Modified.
+const HeaderIfNoneMatch = "If-None-Match"
+
+// responseCache is an in-process cache of results of GET by statements and args.
+type responseCache struct {
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	rs      recordSet
+	tables  []string // upper-cased tables read
+	expires time.Time
+}
+
+func cacheKey(strStmt string, args []any) string {
+	data, err := json.Marshal(args)
+	if err != nil {
+		return ""
+	}
+	return strStmt + "\x00" + string(data)
+}
+
+func (c *responseCache) get(key string) (recordSet, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, found := c.entries[key]
+	if !found {
+		return recordSet{}, false
+	}
+	if time.Now().After(e.expires) {
+		delete(c.entries, key)
+		return recordSet{}, false
+	}
+	return e.rs, true
+}
+
+// put adds an entry, evicting expired ones or else any one if there are maxEntries.
+func (c *responseCache) put(key string, e cacheEntry, maxEntries int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.entries == nil {
+		c.entries = make(map[string]cacheEntry)
+	}
+
+	if maxEntries > 0 && len(c.entries) >= maxEntries {
+		now := time.Now()
+		for k, old := range c.entries {
+			if now.After(old.expires) {
+				delete(c.entries, k)
+			}
+		}
+		for k := range c.entries {
+			if len(c.entries) < maxEntries {
+				break
+			}
+			delete(c.entries, k)
+		}
+	}
+
+	c.entries[key] = e
+}
+
+// invalidate removes entries reading any of tables, or all entries if no tables.
+func (c *responseCache) invalidate(tables ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(tables) == 0 {
+		c.entries = nil
+		return
+	}
+
+	for k, e := range c.entries {
+		for _, t := range tables {
+			if equalsToAnyOfUpper(t, e.tables...) {
+				delete(c.entries, k)
+				break
+			}
+		}
+	}
+}
+
+var subselectTablePattern = regexp.MustCompile(`(?i)\(\s*select\s+([^\s()]+)`)
+
+// readTables returns table and tables of subselects in whereSExpr, upper-cased.
+func readTables(table, whereSExpr string) []string {
+	tables := []string{strings.ToUpper(table)}
+	for _, m := range subselectTablePattern.FindAllStringSubmatch(whereSExpr, -1) {
+		tables = append(tables, strings.ToUpper(m[1]))
+	}
+	return tables
+}
+
+// cacheTTL returns TTL of cached GET of table, 0 means not cached.
+func (r *FootREST) cacheTTL(table string) time.Duration {
+	ttl, found := lookupFold(r.config.Cache.TTLs, table)
+	if !found {
+		ttl = r.config.Cache.TTL
+	}
+	if ttl <= 0 {
+		return 0
+	}
+	return time.Duration(ttl) * time.Millisecond
+}
+
+// invalidate removes cached results reading any of tables, or all if no tables.
+func (r *FootREST) invalidate(tables ...string) {
+	r.cache.invalidate(tables...)
+}
+
+func contentHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// etagMatches reports whether tag is in a header value of If-None-Match or If-Match.
+func etagMatches(header, tag string) bool {
+	for _, t := range strings.Split(header, ",") {
+		t = strings.TrimPrefix(strings.TrimSpace(t), "W/")
+		if t == "*" || t == strings.TrimPrefix(tag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// queryResponse responds data of GET of table with ETag and Cache-Control,
+// or 304 Not Modified if If-None-Match matches tag.
+//
+// If tag is empty and GET of table is cached, tag is a hash of data.
+func (r *FootREST) queryResponse(c echo.Context, table, tag string, data []byte) error {
+	if tag == "" && r.cacheTTL(table) > 0 {
+		tag = contentHash(data)
+	}
+
+	cc, found := lookupFold(r.config.Cache.Controls, table)
+	if !found {
+		cc = r.config.Cache.Control
+	}
+	if cc != "" {
+		c.Response().Header().Set(echo.HeaderCacheControl, cc)
+	}
+
+	if tag != "" {
+		c.Response().Header().Set(HeaderETag, tag)
+		if inm := c.Request().Header.Get(HeaderIfNoneMatch); inm != "" && etagMatches(inm, tag) {
+			return c.NoContent(http.StatusNotModified)
+		}
+	}
+
+	return c.String(http.StatusOK, strings.ReplaceAll(r.config.Format.QueryOK, "%", string(data)))
+}
@@ -0,0 +1,222 @@
+package footrest
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type callResult struct {
+	Params     map[string]any `json:"params"`
+	Return     any            `json:"return,omitempty"`
+	ResultSets []records      `json:"resultSets"`
+}
+
+// BuildCallStmt builds a statement to call a stored procedure by Dialect.Call.
+//
+// If Dialect.CallOutParams, args of out, inout and return params are sql.Out whose Dest is outs[i] of params[i],
+// otherwise out and inout params are bound as inputs and a return param is not bound.
+func (r *FootREST) BuildCallStmt(procedure string, params []SPParam) (strStmt string, args []any, outs []any, err error) {
+	procedure = strings.TrimSpace(procedure)
+
+	if r.dialect.Call == nil {
+		return "", nil, nil, errors.New("call: not supported")
+	}
+
+	for _, name := range strings.Split(procedure, ".") {
+		if !r.isValidName(name) {
+			return "", nil, nil, errors.Errorf("invalid procedure name %q", procedure)
+		}
+	}
+	if !equalsToAnyOfUpper(procedure, r.config.Procedures...) {
+		return "", nil, nil, errors.Errorf("procedure %q is not allowed", procedure)
+	}
+
+	// the return param first
+	order := make([]int, 0, len(params))
+	for i, p := range params {
+		if strings.EqualFold(p.Direction, "return") {
+			order = append(order, i)
+		}
+	}
+	if len(order) > 1 {
+		return "", nil, nil, errors.New("call: more than one return param")
+	}
+	for i, p := range params {
+		if !strings.EqualFold(p.Direction, "return") {
+			order = append(order, i)
+		}
+	}
+
+	sorted := make([]SPParam, len(order))
+	placeholders := make([]string, len(order))
+	outs = make([]any, len(params))
+	for si, i := range order {
+		p := params[i]
+		sorted[si] = p
+
+		if p.Name != "" && !r.isValidName(strings.TrimPrefix(p.Name, "@")) {
+			return "", nil, nil, errors.Errorf("invalid param name %q", p.Name)
+		}
+
+		dir := strings.ToLower(p.Direction)
+		switch dir {
+		case "", "in":
+			placeholders[si] = r.dialect.Placeholder(len(args))
+			args = append(args, r.dialect.Arg(len(args), p.Value))
+
+		case "out", "inout", "return":
+			if !r.dialect.CallOutParams {
+				if dir == "return" {
+					continue
+				}
+				placeholders[si] = r.dialect.Placeholder(len(args))
+				args = append(args, r.dialect.Arg(len(args), p.Value))
+				continue
+			}
+
+			dest := outDest(p.Type)
+			if dir == "inout" && p.Value != nil {
+				dest = &params[i].Value
+			}
+			outs[i] = dest
+
+			placeholders[si] = r.dialect.Placeholder(len(args))
+			args = append(args, r.dialect.Arg(len(args), sql.Out{Dest: dest, In: dir == "inout"}))
+
+		default:
+			return "", nil, nil, errors.Errorf("param %q: unknown direction %q", p.Name, p.Direction)
+		}
+	}
+
+	return r.dialect.Call(procedure, sorted, placeholders), args, outs, nil
+}
+
+// Call calls a stored procedure and returns out params, the return value and result sets.
+func (r *FootREST) Call(ctx context.Context, procedure string, params []SPParam) (callResult, error) {
+	strStmt, args, outs, err := r.BuildCallStmt(procedure, params)
+	if err != nil {
+		return callResult{}, err
+	}
+
+	result := callResult{
+		Params:     make(map[string]any),
+		ResultSets: []records{},
+	}
+
+	if r.conn == nil {
+		return result, nil
+	}
+
+	// a procedure may not be idempotent, so no retry.
+	err = r.inTx(ctx, false, func(tx *sql.Tx) (err error) {
+		encArgs, err := r.encodeArgs(args)
+		if err != nil {
+			return err
+		}
+
+		stmt, done, err := r.prepare(ctx, tx, strStmt)
+		if err != nil {
+			return err
+		}
+		defer done()
+
+		start := time.Now()
+		defer func() {
+			r.traceStmt(ctx, strStmt, args, start, 0, err)
+			r.metrics.exec(strStmt, time.Since(start), 0)
+		}()
+
+		if r.dialect.CallExec {
+			_, err = stmt.ExecContext(ctx, encArgs...)
+			return err
+		}
+
+		rows, err := stmt.QueryContext(ctx, encArgs...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for {
+			rr, err := r.scanRecords(rows)
+			if err != nil {
+				return err
+			}
+			if len(rr.columns) > 0 {
+				result.ResultSets = append(result.ResultSets, rr)
+			}
+
+			if !rows.NextResultSet() {
+				break
+			}
+		}
+
+		return rows.Close()
+	})
+	if err != nil {
+		return callResult{}, err
+	}
+
+	// a procedure may write any tables
+	r.invalidate()
+
+	for i, p := range params {
+		if outs[i] == nil {
+			continue
+		}
+
+		v, err := r.formatValue(deref(outs[i]), nil, nil)
+		if err != nil {
+			return callResult{}, err
+		}
+		if strings.EqualFold(p.Direction, "return") {
+			result.Return = v
+		} else {
+			result.Params[p.Name] = v
+		}
+	}
+
+	return result, nil
+}
+
+// outDest returns a pointer to receive an out param of typ.
+func outDest(typ string) any {
+	typ = strings.ToUpper(typ)
+	switch {
+	case strings.Contains(typ, "INT"):
+		return new(int64)
+	case strings.Contains(typ, "FLOAT"),
+		strings.Contains(typ, "DOUBLE"),
+		strings.Contains(typ, "REAL"),
+		strings.Contains(typ, "NUM"),
+		strings.Contains(typ, "DEC"):
+		return new(float64)
+	case strings.Contains(typ, "BOOL"), strings.Contains(typ, "BIT"):
+		return new(bool)
+	case strings.Contains(typ, "TIME"), strings.Contains(typ, "DATE"):
+		return new(time.Time)
+	}
+	return new(string)
+}
+
+func deref(p any) any {
+	switch p := p.(type) {
+	case *int64:
+		return *p
+	case *float64:
+		return *p
+	case *bool:
+		return *p
+	case *time.Time:
+		return *p
+	case *string:
+		return *p
+	case *any:
+		return *p
+	}
+	return p
+}
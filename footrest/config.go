@@ -2,14 +2,36 @@ package footrest
 
 import (
 	"context"
+	"database/sql"
+	"strings"
 	"time"
+
+	"github.com/pkg/errors"
 )
 
 type Config struct {
-	Format ResponseFormat
-	Params SpecialParams
+	Format  ResponseFormat
+	Params  SpecialParams
+	Output  OutputFormat
+	Import  ImportConfig
+	BulkGet BulkGetConfig
 
-	Timeout int64
+	Timeout  int64            // ms, negative means no timeout
+	Timeouts map[string]int64 // ms by a table name or a route like "!bulk", overriding Timeout
+	Tx       TxConfig
+
+	Tables     []string                // exposed tables, empty means all
+	Procedures []string                // allowed to be called by /!call
+	Queries    map[string]QueryConfig  // served by /!query/{name}
+	Relations  []Relation              // foreign keys for embed, in addition to introspected ones
+	Search     map[string]SearchConfig // full-text search by table
+	Versions   map[string]string       // version column by table, for ETag and If-Match
+	Cache      CacheConfig
+	Stmt       StmtConfig
+	Pool       PoolConfig
+	Log        LogConfig
+
+	Syntax string // syntax of query params, "" (footrest) or "postgrest"
 
 	Addr string
 	Root string
@@ -22,12 +44,121 @@ type ResponseFormat struct {
 }
 
 type SpecialParams struct {
-	Select string
-	Where  string
-	Upsert string
-	Order  string
-	Rows   string
-	Page   string
+	Select   string
+	Where    string
+	Upsert   string
+	Order    string
+	Rows     string
+	Page     string
+	Columnar string
+	Continue string
+	Count    string
+	Group    string
+	After    string
+
+	Isolation string
+	Parallel  string
+	Embed     string
+	Search    string
+}
+
+// OutputFormat controls how values read from the database are rendered in JSON.
+type OutputFormat struct {
+	Decimal  string // "string" (exact, default) or "number"
+	Binary   string // "base64" (default) or "hex"
+	Columnar bool   // {"columns":[...],"rows":[[...]]} instead of an array of objects
+}
+
+// ImportConfig is for POST of text/csv and NDJSON bodies.
+type ImportConfig struct {
+	BatchSize int // records per INSERT statement
+
+	// Timeout (ms) of an import instead of Timeout, unless Timeouts has the table.
+	// 0 means Timeout, negative means no timeout.
+	Timeout int64
+}
+
+// TxConfig is for transactions of writes.
+type TxConfig struct {
+	// Isolations are isolation levels allowed to be requested by the X-Footrest-Isolation header.
+	Isolations []string
+
+	// Retries is the max number of retries on a serialization failure or a deadlock.
+	Retries      int
+	RetryBackoff int64 // ms, doubled on each retry
+
+	// MaxOpen is the max number of concurrent interactive transactions (/!tx).
+	MaxOpen int
+	// IdleTimeout (ms) rolls back an abandoned interactive transaction.
+	IdleTimeout int64
+}
+
+// BulkGetConfig is for /!bulkget.
+type BulkGetConfig struct {
+	MaxParallel int // upper limit of special parallel query param
+}
+
+// CacheConfig is an in-process cache of results of GET.
+// Writes by footrest invalidate results of the table.
+type CacheConfig struct {
+	TTL        int64            // ms, 0 means not cached
+	TTLs       map[string]int64 // ms by a table, overriding TTL
+	MaxEntries int              // 0 means unlimited
+
+	Control  string            // Cache-Control header of GET
+	Controls map[string]string // by a table, overriding Control
+}
+
+// PoolConfig is for the connection pool of NewConn.
+type PoolConfig struct {
+	MaxOpenConns    int   // 0 means unlimited
+	MaxIdleConns    int   // 0 or less means no idle connections
+	ConnMaxLifetime int64 // ms, 0 means forever
+	ConnMaxIdleTime int64 // ms, 0 means forever
+
+	// PingRetries is the max number of retries of a ping on startup.
+	PingRetries int
+	PingBackoff int64 // ms, doubled on each retry
+}
+
+// LogConfig is for structured logs.
+type LogConfig struct {
+	Format string // "text" (default) or "json"
+	Level  string // "debug", "info" (default), "warn" or "error"
+
+	// SlowQuery (ms) logs statements taking longer as warnings, 0 means none.
+	SlowQuery int64
+	// Redact are sensitive columns, args of statements containing them are not logged.
+	Redact []string
+}
+
+// StmtConfig is for prepared statements.
+type StmtConfig struct {
+	CacheSize int // max number of prepared statements reused across requests, 0 means not cached
+}
+
+// QueryConfig is a named query.
+type QueryConfig struct {
+	SQL    string            // {param} is bound to a query param
+	Params map[string]string // param name -> type: string (default), int, float, bool or time (RFC3339)
+}
+
+// SearchConfig is full-text search of a table by special search query param.
+type SearchConfig struct {
+	Columns  []string // columns to be searched
+	Index    string   // FTS5 table of SQLite, {table}_fts if empty
+	Language string   // text search config of PostgreSQL like "english"
+}
+
+// parseIsolation parses an isolation level name like "read_committed", "repeatable read" or "snapshot".
+func parseIsolation(s string) (sql.IsolationLevel, error) {
+	name := strings.ToUpper(strings.NewReplacer("_", " ", "-", " ").Replace(strings.TrimSpace(s)))
+	for lv := sql.LevelDefault; lv <= sql.LevelLinearizable; lv++ {
+		if strings.ToUpper(lv.String()) == name {
+			return lv, nil
+		}
+	}
+	return sql.LevelDefault, errors.Errorf("unknown isolation level %q", s)
 }
 
 func DefaultConfig() *Config {
@@ -44,9 +175,69 @@ func DefaultConfig() *Config {
 			Order:  "order",
 			Rows:   "rows",
 			Page:   "page",
+
+			Columnar: "columnar",
+			Continue: "continue",
+			Count:    "count",
+			Group:    "group",
+			After:    "after",
+
+			Isolation: "isolation",
+			Parallel:  "parallel",
+			Embed:     "embed",
+			Search:    "search",
+		},
+		Output: OutputFormat{
+			Decimal:  "string",
+			Binary:   "base64",
+			Columnar: false,
+		},
+		Import: ImportConfig{
+			BatchSize: 500,
+			Timeout:   int64(10 * time.Minute / time.Millisecond),
+		},
+		BulkGet: BulkGetConfig{
+			MaxParallel: 4,
 		},
 
 		Timeout: int64(5 * time.Second / time.Millisecond),
+		Tx: TxConfig{
+			Isolations:   []string{"read_committed", "repeatable_read", "serializable"},
+			Retries:      3,
+			RetryBackoff: 50,
+			MaxOpen:      10,
+			IdleTimeout:  int64(30 * time.Second / time.Millisecond),
+		},
+
+		Tables:     []string{},
+		Procedures: []string{},
+		Queries:    map[string]QueryConfig{},
+		Relations:  []Relation{},
+		Search:     map[string]SearchConfig{},
+		Versions:   map[string]string{},
+		Cache: CacheConfig{
+			TTL:        0,
+			TTLs:       map[string]int64{},
+			MaxEntries: 1000,
+			Controls:   map[string]string{},
+		},
+		Stmt: StmtConfig{
+			CacheSize: 100,
+		},
+		Pool: PoolConfig{
+			MaxOpenConns:    0,
+			MaxIdleConns:    2,
+			ConnMaxLifetime: 0,
+			ConnMaxIdleTime: 0,
+			PingRetries:     5,
+			PingBackoff:     500,
+		},
+		Log: LogConfig{
+			Format:    "text",
+			Level:     "info",
+			SlowQuery: 0,
+			Redact:    []string{},
+		},
 
 		Addr: ":12345",
 		Root: "/",
@@ -54,10 +245,28 @@ func DefaultConfig() *Config {
 }
 
 func (c Config) Context() (context.Context, context.CancelFunc) {
+	return c.ContextFor("")
+}
+
+// importRoute is a name of imports for Timeouts.
+const importRoute = "!import"
+
+// ContextFor is Context with Timeouts[name] if exists.
+func (c Config) ContextFor(name string) (context.Context, context.CancelFunc) {
+	timeout := c.Timeout
+	if strings.EqualFold(name, importRoute) && c.Import.Timeout != 0 {
+		timeout = c.Import.Timeout
+	}
+	for k, t := range c.Timeouts {
+		if name != "" && strings.EqualFold(k, name) {
+			timeout = t
+		}
+	}
+
 	var cancel context.CancelFunc
 	ctx := context.Background()
-	if c.Timeout >= 0 {
-		ctx, cancel = context.WithTimeout(ctx, time.Duration(c.Timeout)*time.Millisecond)
+	if timeout >= 0 {
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(timeout)*time.Millisecond)
 	} else {
 		cancel = func() {}
 	}
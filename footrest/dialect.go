@@ -1,6 +1,7 @@
 package footrest
 
 import (
+	"errors"
 	"fmt"
 	"strconv"
 	"strings"
@@ -50,12 +51,79 @@ func GetDialect(name string) *Dialect {
 // The best way to define a dialect is use DefaultDialect() and tweek the returned dialect.
 type Dialect struct {
 	Operators   map[string]Operator // key(Operator.Name) must be upper case.
+	Functions   map[string]Function // key(Function.Name) must be upper case.
 	Placeholder func(int) string
 	Arg         func(int, any) any
 
 	IsValidName func(string) bool
 
-	Paginate func(uint, uint) [2]string // (rows_per_page,page) -> stmt
+	Paginate    func(uint, uint) [2]string // (rows_per_page,page) -> stmt
+	LimitOffset func(uint, uint) [2]string // (limit,offset) -> stmt, limit 0 means unlimited
+
+	// WrapQuery wraps a named query as a subquery to be ordered and paginated.
+	// An error means the query can not be a subquery, like a CTE on SQL Server.
+	// nil means not supported.
+	WrapQuery func(query string) (string, error)
+
+	// MultiRowInsert builds an INSERT statement of rows of placeholders.
+	// nil means one row per statement.
+	MultiRowInsert func(table string, columns []string, rows [][]string) string
+	MaxInsertRows  int // max rows per INSERT statement, 0 means unlimited
+	MaxParams      int // max placeholders per statement, 0 means unlimited
+
+	// Returning returns clauses to return inserted rows,
+	// [0] follows the column list and [1] follows VALUES.
+	// nil means not supported.
+	Returning func() [2]string
+
+	// LockRows returns clauses to lock selected rows until the end of the transaction,
+	// [0] follows the table name and [1] follows the statement.
+	// nil means not needed or not supported.
+	LockRows func() [2]string
+
+	// IsRetryable reports whether err is a serialization failure or a deadlock
+	// and the transaction can be retried.
+	IsRetryable func(err error) bool
+
+	Savepoint           func(name string) string // nil means savepoints are not supported
+	RollbackToSavepoint func(name string) string
+
+	// ReadOnlyTx means the driver accepts sql.TxOptions.ReadOnly.
+	ReadOnlyTx bool
+	// SetReadOnly is a statement run first in a transaction of default options to make it read-only,
+	// for a driver rejecting sql.TxOptions.
+	// "" means not used.
+	SetReadOnly string
+
+	// Call builds a statement to call a stored procedure,
+	// or a function if params[0] is of direction "return".
+	// placeholders[i] is for params[i], "" if not bound.
+	// nil means not supported.
+	Call func(name string, params []SPParam, placeholders []string) string
+	// CallOutParams means out, inout and return params are bound as sql.Out.
+	// Otherwise they are returned as a result set.
+	CallOutParams bool
+	// CallExec means Call is executed without reading result sets.
+	CallExec bool
+
+	// Search builds a full-text search condition of conf on table.
+	// placeholder returns a new placeholder bound to the search text.
+	// nil means not supported.
+	Search func(table string, conf SearchConfig, placeholder func() string) string
+
+	// ForeignKeys is a statement to list foreign keys of all tables
+	// as rows of (table, id, column, ref_table, ref_column), where id identifies a key of the table.
+	// "" means not supported.
+	ForeignKeys string
+
+	// Tables is a statement to list tables as rows of (table).
+	// "" means not supported.
+	Tables string
+
+	// PrimaryKeys is a statement to list primary keys of all tables
+	// as rows of (table, column) in the order of columns in a key.
+	// "" means not supported.
+	PrimaryKeys string
 }
 
 func (d *Dialect) AddOperator(name string, format string, f ...OperatorFormatter) {
@@ -92,6 +160,10 @@ func DefaultDialect() Dialect {
 	d.AddOperator("!<", "")
 	d.AddOperator("!>", "")
 	d.AddOperator("LIKE", "")
+	d.AddOperator("ILIKE", "UPPER($1) LIKE UPPER($2)")
+	d.AddOperator("ICONTAINS", "UPPER($1) LIKE '%' || UPPER("+LikeEscape("$2")+") || '%' ESCAPE '\\'")
+	d.AddOperator("STARTSWITH", "$1 LIKE "+LikeEscape("$2")+" || '%' ESCAPE '\\'")
+	d.AddOperator("LIKEESCAPE", "$1 LIKE $2 ESCAPE '\\'")
 	d.AddOperator("BETWEEN", "$1 BETWEEN $2 AND $3")
 
 	d.AddOperator("IS", "")
@@ -101,6 +173,25 @@ func DefaultDialect() Dialect {
 
 	d.AddOperator("NOT", "NOT ($1)")
 
+	d.AddOperator("IN", "$1 IN ($2)", func(args ...string) (string, error) {
+		if len(args) < 2 {
+			return "", errors.New("IN needs a column and values")
+		}
+		if len(args) == 2 && strings.HasPrefix(args[1], "(SELECT ") {
+			return args[0] + " IN " + args[1], nil
+		}
+		return args[0] + " IN (" + strings.Join(args[1:], ", ") + ")", nil
+	})
+	d.AddOperator("NOTIN", "$1 NOT IN ($2)", func(args ...string) (string, error) {
+		if len(args) < 2 {
+			return "", errors.New("NOTIN needs a column and values")
+		}
+		if len(args) == 2 && strings.HasPrefix(args[1], "(SELECT ") {
+			return args[0] + " NOT IN " + args[1], nil
+		}
+		return args[0] + " NOT IN (" + strings.Join(args[1:], ", ") + ")", nil
+	})
+
 	d.AddOperator("AND", "($1) AND ($2)", func(args ...string) (string, error) {
 		myargs := make([]string, len(args))
 		for i := range args {
@@ -118,6 +209,22 @@ func DefaultDialect() Dialect {
 
 	d.AddOperator("||", "")
 
+	d.addArithmetic("+")
+	d.addArithmetic("-")
+	d.addArithmetic("*")
+	d.addArithmetic("/")
+
+	d.AddFunction("LOWER", "", exprText, exprText)
+	d.AddFunction("UPPER", "", exprText, exprText)
+	d.AddFunction("TRIM", "", exprText, exprText)
+	d.AddFunction("LENGTH", "", exprNumber, exprText)
+	d.AddFunction("SUBSTR", "", exprText, exprText, exprNumber, exprNumber)
+	d.AddFunction("REPLACE", "", exprText, exprText, exprText, exprText)
+	d.AddFunction("ABS", "", exprNumber, exprNumber)
+	d.AddFunction("ROUND", "", exprNumber, exprNumber, exprNumber)
+	d.AddFunction("COALESCE", "", "", exprAny+"...")
+	d.AddFunction("NULLIF", "", "", exprAny, exprAny)
+
 	d.Placeholder = func(int) string {
 		return "?"
 	}
@@ -156,9 +263,94 @@ func DefaultDialect() Dialect {
 		}
 	}
 
+	d.WrapQuery = func(query string) (string, error) {
+		return "SELECT * FROM (" + query + ") q", nil
+	}
+
+	d.LimitOffset = func(limit, offset uint) [2]string {
+		if limit == 0 && offset == 0 {
+			return [2]string{
+				"", "",
+			}
+		}
+		if limit == 0 {
+			return [2]string{
+				"", fmt.Sprintf("LIMIT -1 OFFSET %d", offset),
+			}
+		}
+
+		return [2]string{
+			"", fmt.Sprintf("LIMIT %d OFFSET %d", limit, offset),
+		}
+	}
+
+	d.MultiRowInsert = DefaultMultiRowInsert
+
+	d.IsRetryable = func(err error) bool {
+		// SQLSTATE 40001 serialization_failure, 40P01 deadlock_detected
+		var state interface{ SQLState() string }
+		if errors.As(err, &state) {
+			return state.SQLState() == "40001" || state.SQLState() == "40P01"
+		}
+
+		msg := strings.ToLower(err.Error())
+		return strings.Contains(msg, "deadlock") || strings.Contains(msg, "could not serialize")
+	}
+
+	d.ReadOnlyTx = true
+
+	d.Savepoint = func(name string) string {
+		return "SAVEPOINT " + name
+	}
+	d.RollbackToSavepoint = func(name string) string {
+		return "ROLLBACK TO SAVEPOINT " + name
+	}
+
+	d.Call = func(name string, params []SPParam, placeholders []string) string {
+		if len(params) > 0 && strings.EqualFold(params[0].Direction, "return") {
+			return "SELECT " + name + "(" + strings.Join(placeholders[1:], ", ") + ")"
+		}
+		return "CALL " + name + "(" + strings.Join(placeholders, ", ") + ")"
+	}
+
 	return d
 }
 
+// DefaultMultiRowInsert builds INSERT INTO table (columns) VALUES (row1), (row2), ...
+func DefaultMultiRowInsert(table string, columns []string, rows [][]string) string {
+	return insertValues(table, columns, rows, [2]string{})
+}
+
+func insertValues(table string, columns []string, rows [][]string, returning [2]string) string {
+	buf := strings.Builder{}
+	buf.WriteString("INSERT INTO ")
+	buf.WriteString(table)
+	buf.WriteString(" (")
+	buf.WriteString(strings.Join(columns, ", "))
+	buf.WriteString(") ")
+	if returning[0] != "" {
+		buf.WriteString(returning[0])
+		buf.WriteString(" ")
+	}
+	buf.WriteString("VALUES ")
+
+	for i, row := range rows {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		buf.WriteByte('(')
+		buf.WriteString(strings.Join(row, ", "))
+		buf.WriteByte(')')
+	}
+
+	if returning[1] != "" {
+		buf.WriteString(" ")
+		buf.WriteString(returning[1])
+	}
+
+	return buf.String()
+}
+
 const DefaultOperatorFormat = `$1 {OPERATOR} $2`
 
 type OperatorFormatter func(args ...string) (string, error)
@@ -167,6 +359,9 @@ type Operator struct {
 	Name      string
 	Format    string            // "$1 == $2", "$1 BETWEEN $2 AND $3"
 	Formatter OperatorFormatter // optional
+
+	Operand string // kind of operands for type checking, "" means unchecked
+	Result  string // kind of the result, "" means unknown
 }
 
 func (o Operator) ApplyFormat(args ...string) (string, error) {
@@ -174,9 +369,41 @@ func (o Operator) ApplyFormat(args ...string) (string, error) {
 		return o.Formatter(args...)
 	}
 
-	result := o.Format
-	for i, a := range args {
-		result = strings.ReplaceAll(result, "$"+strconv.Itoa(i+1), a)
+	return formatArgs(o.Format, args), nil
+}
+
+// formatArgs replaces $1, $2, ... in format with args in a single pass,
+// not to replace $n of an arg (a placeholder of PostgreSQL) again.
+// $n out of args is left as is.
+func formatArgs(format string, args []string) string {
+	var buf strings.Builder
+	for {
+		i := strings.IndexByte(format, '$')
+		if i < 0 {
+			buf.WriteString(format)
+			return buf.String()
+		}
+
+		j := i + 1
+		for j < len(format) && '0' <= format[j] && format[j] <= '9' {
+			j++
+		}
+		n, err := strconv.Atoi(format[i+1 : j])
+		if err != nil || n < 1 || len(args) < n {
+			buf.WriteString(format[:j])
+		} else {
+			buf.WriteString(format[:i])
+			buf.WriteString(args[n-1])
+		}
+		format = format[j:]
+	}
+}
+
+// LikeEscape returns an SQL expression of x whose wildcards % and _ (and more wildcards) are escaped by \,
+// to be matched by LIKE ... ESCAPE '\'.
+func LikeEscape(x string, wildcards ...string) string {
+	for _, w := range append([]string{"\\", "%", "_"}, wildcards...) {
+		x = "REPLACE(" + x + ", '" + w + "', '\\" + w + "')"
 	}
-	return result, nil
+	return x
 }
@@ -3,6 +3,7 @@ package oracle
 import (
 	"fmt"
 	"strconv"
+	"strings"
 
 	"github.com/shu-go/footrest/footrest"
 )
@@ -32,5 +33,84 @@ func Dialect() footrest.Dialect {
 		}
 	}
 
+	d.LimitOffset = func(limit, offset uint) [2]string {
+		if limit == 0 && offset == 0 {
+			return [2]string{
+				"", "",
+			}
+		}
+		if limit == 0 {
+			return [2]string{
+				"", fmt.Sprintf("OFFSET %d ROWS", offset),
+			}
+		}
+
+		return [2]string{
+			"", fmt.Sprintf("OFFSET %d ROWS FETCH FIRST %d ROWS ONLY", offset, limit),
+		}
+	}
+
+	d.MultiRowInsert = func(table string, columns []string, rows [][]string) string {
+		into := "INTO " + table + " (" + strings.Join(columns, ", ") + ") VALUES "
+
+		buf := strings.Builder{}
+		buf.WriteString("INSERT ALL")
+		for _, row := range rows {
+			buf.WriteString(" ")
+			buf.WriteString(into)
+			buf.WriteString("(")
+			buf.WriteString(strings.Join(row, ", "))
+			buf.WriteString(")")
+		}
+		buf.WriteString(" SELECT 1 FROM DUAL")
+
+		return buf.String()
+	}
+	// 65535 bind variables per statement (1000 is the limit of an IN list, not of binds)
+	d.MaxParams = 65535
+
+	d.IsRetryable = func(err error) bool {
+		// ORA-08177: can't serialize access, ORA-00060: deadlock detected
+		msg := err.Error()
+		return strings.Contains(msg, "ORA-08177") || strings.Contains(msg, "ORA-00060")
+	}
+
+	// go-ora rejects read-only transactions and non-default isolation levels.
+	// A read-only transaction reads a snapshot of its beginning, whatever the isolation level.
+	d.ReadOnlyTx = false
+	d.SetReadOnly = "SET TRANSACTION READ ONLY"
+	d.LockRows = func() [2]string {
+		return [2]string{"", "FOR UPDATE"}
+	}
+
+	d.Call = func(name string, params []footrest.SPParam, placeholders []string) string {
+		if len(params) > 0 && strings.EqualFold(params[0].Direction, "return") {
+			return "BEGIN " + placeholders[0] + " := " + name + "(" + strings.Join(placeholders[1:], ", ") + "); END;"
+		}
+		return "BEGIN " + name + "(" + strings.Join(placeholders, ", ") + "); END;"
+	}
+	d.CallOutParams = true
+
+	d.Search = func(table string, conf footrest.SearchConfig, placeholder func() string) string {
+		// Oracle Text: a CONTEXT index per column
+		conds := make([]string, 0, len(conf.Columns))
+		for _, c := range conf.Columns {
+			conds = append(conds, "CONTAINS("+c+", "+placeholder()+") > 0")
+		}
+		return strings.Join(conds, " OR ")
+	}
+
+	// units of PostgreSQL to format models, other units are NULL ('day' of TRUNC is the first day of a week)
+	d.AddFunction("DATE_TRUNC", "TRUNC($2, DECODE(LOWER($1), 'minute', 'MI', 'hour', 'HH24', 'day', 'DD', 'week', 'IW', 'month', 'MM', 'quarter', 'Q', 'year', 'YYYY'))", "time", "text", "time")
+	d.AddFunction("NOW", "SYSTIMESTAMP", "time")
+	d.CallExec = true
+
+	d.Tables = `SELECT table_name FROM user_tables`
+	d.PrimaryKeys = `SELECT c.table_name, cc.column_name
+FROM user_constraints c
+JOIN user_cons_columns cc ON cc.constraint_name = c.constraint_name
+WHERE c.constraint_type = 'P'
+ORDER BY c.table_name, cc.position`
+
 	return d
 }
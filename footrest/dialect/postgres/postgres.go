@@ -1,7 +1,9 @@
 package postgres
 
 import (
+	"fmt"
 	"strconv"
+	"strings"
 
 	"github.com/shu-go/footrest/footrest"
 )
@@ -16,5 +18,59 @@ func Dialect() footrest.Dialect {
 	d.Placeholder = func(num int) string {
 		return "$" + strconv.Itoa(num+1)
 	}
+	d.LimitOffset = func(limit, offset uint) [2]string {
+		if limit == 0 && offset == 0 {
+			return [2]string{
+				"", "",
+			}
+		}
+		if limit == 0 {
+			return [2]string{
+				"", fmt.Sprintf("OFFSET %d", offset),
+			}
+		}
+
+		return [2]string{
+			"", fmt.Sprintf("LIMIT %d OFFSET %d", limit, offset),
+		}
+	}
+	d.MaxParams = 65535
+	d.Returning = func() [2]string {
+		return [2]string{"", "RETURNING *"}
+	}
+	d.LockRows = func() [2]string {
+		return [2]string{"", "FOR UPDATE"}
+	}
+	d.AddOperator("ILIKE", "$1 ILIKE $2")
+	d.AddOperator("ICONTAINS", "$1 ILIKE '%' || "+footrest.LikeEscape("$2")+" || '%' ESCAPE '\\'")
+
+	d.Search = func(table string, conf footrest.SearchConfig, placeholder func() string) string {
+		cols := make([]string, 0, len(conf.Columns))
+		for _, c := range conf.Columns {
+			cols = append(cols, "COALESCE("+c+", '')")
+		}
+		lang := ""
+		if conf.Language != "" {
+			lang = "'" + conf.Language + "', "
+		}
+		return "to_tsvector(" + lang + strings.Join(cols, " || ' ' || ") + ") @@ plainto_tsquery(" + lang + placeholder() + ")"
+	}
+
+	d.AddFunction("DATE_TRUNC", "", "time", "text", "time")
+	d.AddFunction("NOW", "", "time")
+	d.ForeignKeys = `SELECT c.conrelid::regclass::text, c.conname, a.attname, c.confrelid::regclass::text, af.attname
+FROM pg_constraint c
+CROSS JOIN LATERAL unnest(c.conkey, c.confkey) WITH ORDINALITY AS k(attnum, fattnum, n)
+JOIN pg_attribute a ON a.attrelid = c.conrelid AND a.attnum = k.attnum
+JOIN pg_attribute af ON af.attrelid = c.confrelid AND af.attnum = k.fattnum
+WHERE c.contype = 'f'
+ORDER BY 1, 2, k.n`
+	d.Tables = `SELECT table_name FROM information_schema.tables WHERE table_schema = current_schema() AND table_type = 'BASE TABLE'`
+	d.PrimaryKeys = `SELECT c.conrelid::regclass::text, a.attname
+FROM pg_constraint c
+CROSS JOIN LATERAL unnest(c.conkey) WITH ORDINALITY AS k(attnum, n)
+JOIN pg_attribute a ON a.attrelid = c.conrelid AND a.attnum = k.attnum
+WHERE c.contype = 'p'
+ORDER BY 1, k.n`
 	return d
 }
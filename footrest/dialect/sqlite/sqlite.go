@@ -1,6 +1,10 @@
 package sqlite
 
-import "github.com/shu-go/footrest/footrest"
+import (
+	"strings"
+
+	"github.com/shu-go/footrest/footrest"
+)
 
 func init() {
 	d := Dialect()
@@ -9,5 +13,40 @@ func init() {
 
 func Dialect() footrest.Dialect {
 	d := footrest.DefaultDialect()
+	d.MaxParams = 32766
+	d.IsRetryable = func(err error) bool {
+		// SQLITE_BUSY, SQLITE_LOCKED
+		msg := err.Error()
+		return strings.Contains(msg, "SQLITE_BUSY") || strings.Contains(msg, "database is locked") ||
+			strings.Contains(msg, "SQLITE_LOCKED") || strings.Contains(msg, "database table is locked")
+	}
+	d.Returning = func() [2]string {
+		return [2]string{"", "RETURNING *"}
+	}
+	d.Call = nil // no stored procedures
+	d.Search = func(table string, conf footrest.SearchConfig, placeholder func() string) string {
+		// FTS5 table whose rowid is of table
+		index := conf.Index
+		if index == "" {
+			index = table + "_fts"
+		}
+		query := placeholder()
+		if len(conf.Columns) > 0 {
+			query = "'{" + strings.Join(conf.Columns, " ") + "}: (' || " + query + " || ')'"
+		}
+		return "rowid IN (SELECT rowid FROM " + index + " WHERE " + index + " MATCH " + query + ")"
+	}
+	d.AddFunction("STRFTIME", "", "text", "text", "time")
+	d.AddFunction("NOW", "CURRENT_TIMESTAMP", "time")
+	d.ForeignKeys = `SELECT m.name, CAST(p.id AS TEXT), p."from", p."table",
+COALESCE(p."to", (SELECT i.name FROM pragma_table_info(p."table") i WHERE i.pk = p.seq + 1))
+FROM sqlite_master m, pragma_foreign_key_list(m.name) p
+WHERE m.type = 'table'
+ORDER BY m.name, p.id, p.seq`
+	d.Tables = `SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%'`
+	d.PrimaryKeys = `SELECT m.name, p.name
+FROM sqlite_master m, pragma_table_info(m.name) p
+WHERE m.type = 'table' AND p.pk > 0
+ORDER BY m.name, p.pk`
 	return d
 }
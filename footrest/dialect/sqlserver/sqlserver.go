@@ -2,8 +2,11 @@ package sqlserver
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"strconv"
+	"strings"
+	"unicode"
 
 	"github.com/shu-go/footrest/footrest"
 )
@@ -37,5 +40,182 @@ func Dialect() footrest.Dialect {
 		}
 	}
 
+	d.LimitOffset = func(limit, offset uint) [2]string {
+		if limit == 0 && offset == 0 {
+			return [2]string{
+				"", "",
+			}
+		}
+		if limit == 0 {
+			return [2]string{
+				"", fmt.Sprintf("OFFSET %d ROWS", offset),
+			}
+		}
+
+		return [2]string{
+			"", fmt.Sprintf("OFFSET %d ROWS FETCH FIRST %d ROWS ONLY", offset, limit),
+		}
+	}
+
+	// a derived table can not be a CTE, nor have ORDER BY without TOP or OFFSET
+	d.WrapQuery = func(query string) (string, error) {
+		words := topLevelWords(query)
+		if len(words) > 0 && words[0] == "WITH" {
+			return "", errors.New("a query of WITH can not be a subquery")
+		}
+		if contains(words, "ORDER") && !contains(words, "TOP") && !contains(words, "OFFSET") {
+			return "", errors.New("a query of ORDER BY without TOP or OFFSET can not be a subquery")
+		}
+		return "SELECT * FROM (" + query + ") q", nil
+	}
+
+	d.IsRetryable = func(err error) bool {
+		// 1205: deadlock victim, 3960: snapshot update conflict
+		var num interface{ SQLErrorNumber() int32 }
+		if errors.As(err, &num) {
+			return num.SQLErrorNumber() == 1205 || num.SQLErrorNumber() == 3960
+		}
+		return false
+	}
+
+	// go-mssqldb rejects read-only transactions
+	d.ReadOnlyTx = false
+
+	d.Savepoint = func(name string) string {
+		return "SAVE TRANSACTION " + name
+	}
+	d.RollbackToSavepoint = func(name string) string {
+		return "ROLLBACK TRANSACTION " + name
+	}
+
+	d.Returning = func() [2]string {
+		return [2]string{"OUTPUT INSERTED.*", ""}
+	}
+	d.LockRows = func() [2]string {
+		return [2]string{"WITH (UPDLOCK, ROWLOCK)", ""}
+	}
+
+	d.Call = func(name string, params []footrest.SPParam, placeholders []string) string {
+		buf := strings.Builder{}
+		buf.WriteString("EXEC ")
+
+		args := make([]string, 0, len(params))
+		for i, p := range params {
+			dir := strings.ToLower(p.Direction)
+			if dir == "return" {
+				buf.WriteString(placeholders[i] + " = ")
+				continue
+			}
+
+			a := placeholders[i]
+			if p.Name != "" {
+				a = "@" + strings.TrimPrefix(p.Name, "@") + " = " + a
+			}
+			if dir == "out" || dir == "inout" {
+				a += " OUTPUT"
+			}
+			args = append(args, a)
+		}
+		buf.WriteString(name)
+		if len(args) > 0 {
+			buf.WriteString(" ")
+			buf.WriteString(strings.Join(args, ", "))
+		}
+
+		return buf.String()
+	}
+	d.CallOutParams = true
+
+	// UPPER, not a collation, not to depend on the collation of the database
+	d.AddOperator("ILIKE", "UPPER($1) LIKE UPPER($2)")
+	// [ is also a wildcard
+	d.AddOperator("ICONTAINS", "UPPER($1) LIKE '%' + UPPER("+footrest.LikeEscape("$2", "[")+") + '%' ESCAPE '\\'")
+	d.AddOperator("STARTSWITH", "$1 LIKE "+footrest.LikeEscape("$2", "[")+" + '%' ESCAPE '\\'")
+
+	d.Search = func(table string, conf footrest.SearchConfig, placeholder func() string) string {
+		return "CONTAINS((" + strings.Join(conf.Columns, ", ") + "), " + placeholder() + ")"
+	}
+
+	d.AddFunction("LENGTH", "LEN($1)", "number", "text")
+	d.AddFunction("SUBSTR", "SUBSTRING($1, $2, $3)", "text", "text", "number", "number")
+	d.AddFunction("NOW", "SYSDATETIME()", "time")
+
+	d.MaxInsertRows = 1000
+	// 2100 params of a procedure, sp_executesql takes 2 of them for the statement and the definitions
+	d.MaxParams = 2098
+
+	d.Tables = `SELECT TABLE_NAME FROM INFORMATION_SCHEMA.TABLES WHERE TABLE_TYPE = 'BASE TABLE'`
+	d.PrimaryKeys = `SELECT k.TABLE_NAME, k.COLUMN_NAME
+FROM INFORMATION_SCHEMA.TABLE_CONSTRAINTS c
+JOIN INFORMATION_SCHEMA.KEY_COLUMN_USAGE k ON k.CONSTRAINT_NAME = c.CONSTRAINT_NAME AND k.TABLE_NAME = c.TABLE_NAME
+WHERE c.CONSTRAINT_TYPE = 'PRIMARY KEY'
+ORDER BY k.TABLE_NAME, k.ORDINAL_POSITION`
+
 	return d
 }
+
+// topLevelWords returns upper-cased words of query out of parentheses, quotes and comments.
+func topLevelWords(query string) []string {
+	var words []string
+	depth := 0
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == '\'' || c == '"' || c == '[':
+			end := c
+			if c == '[' {
+				end = ']'
+			}
+			j := strings.IndexByte(query[i+1:], end)
+			if j < 0 {
+				return words
+			}
+			i += j + 2
+
+		case strings.HasPrefix(query[i:], "--"):
+			j := strings.IndexByte(query[i:], '\n')
+			if j < 0 {
+				return words
+			}
+			i += j + 1
+
+		case strings.HasPrefix(query[i:], "/*"):
+			j := strings.Index(query[i:], "*/")
+			if j < 0 {
+				return words
+			}
+			i += j + 2
+
+		case c == '(':
+			depth++
+			i++
+
+		case c == ')':
+			depth--
+			i++
+
+		case c == '_' || c == '@' || c == '#' || unicode.IsLetter(rune(c)) || unicode.IsDigit(rune(c)):
+			j := i + 1
+			for j < len(query) && (query[j] == '_' || unicode.IsLetter(rune(query[j])) || unicode.IsDigit(rune(query[j]))) {
+				j++
+			}
+			if depth == 0 {
+				words = append(words, strings.ToUpper(query[i:j]))
+			}
+			i = j
+
+		default:
+			i++
+		}
+	}
+	return words
+}
+
+func contains(words []string, word string) bool {
+	for _, w := range words {
+		if w == word {
+			return true
+		}
+	}
+	return false
+}
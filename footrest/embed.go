@@ -0,0 +1,329 @@
+package footrest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Relation is a foreign key from Table.Columns to RefTable.RefColumns.
+type Relation struct {
+	Table      string
+	Columns    []string
+	RefTable   string
+	RefColumns []string
+
+	Name        string // embed name of RefTable in Table, RefTable if empty
+	ReverseName string // embed name of Table in RefTable, Table if empty
+}
+
+// Embed is a related resource embedded into each row of GET.
+//
+// A row of RefTable is embedded as an object into a row of Table (to-one),
+// rows of Table are embedded as an array into a row of RefTable (to-many).
+type Embed struct {
+	Name   string
+	Select []string
+	Where  string // S-expr
+	Order  []string
+	Embeds []Embed // embedded into rows of this resource
+}
+
+// inCond is rows whose columns are one of values.
+type inCond struct {
+	columns []string
+	values  [][]any
+}
+
+// record is a row marshaled as an object.
+type record struct {
+	columns []string
+	values  []any
+}
+
+func (rec record) MarshalJSON() ([]byte, error) {
+	buf := bytes.Buffer{}
+	err := writeObject(&buf, rec.columns, rec.values)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// getRelations returns relations in config Relations and introspected by Dialect.ForeignKeys.
+func (r *FootREST) getRelations(ctx context.Context) ([]Relation, error) {
+	r.scMut.Lock()
+	if r.relations != nil {
+		rels := r.relations
+		r.scMut.Unlock()
+		return rels, nil
+	}
+	r.scMut.Unlock()
+
+	for _, rel := range r.config.Relations {
+		if rel.Table == "" || rel.RefTable == "" || len(rel.Columns) == 0 || len(rel.Columns) != len(rel.RefColumns) {
+			return nil, errors.Errorf("invalid relation %q -> %q: %d columns to %d columns", rel.Table, rel.RefTable, len(rel.Columns), len(rel.RefColumns))
+		}
+	}
+
+	rels := append([]Relation{}, r.config.Relations...)
+
+	if r.conn != nil && r.dialect.ForeignKeys != "" {
+		rows, err := r.conn.QueryContext(ctx, r.dialect.ForeignKeys)
+		if err != nil {
+			return nil, errors.Wrap(err, "foreign keys")
+		}
+		defer rows.Close()
+
+		byID := make(map[string]int) // table+id -> index of rels
+		for rows.Next() {
+			var table, id, col, refTable, refCol string
+			err = rows.Scan(&table, &id, &col, &refTable, &refCol)
+			if err != nil {
+				return nil, errors.Wrap(err, "foreign keys")
+			}
+
+			key := strings.ToUpper(table) + "\x00" + id
+			i, found := byID[key]
+			if !found {
+				i = len(rels)
+				byID[key] = i
+				rels = append(rels, Relation{Table: table, RefTable: refTable})
+			}
+			rels[i].Columns = append(rels[i].Columns, col)
+			rels[i].RefColumns = append(rels[i].RefColumns, refCol)
+		}
+		if err = rows.Err(); err != nil {
+			return nil, errors.Wrap(err, "foreign keys")
+		}
+	}
+
+	r.scMut.Lock()
+	r.relations = rels
+	r.scMut.Unlock()
+
+	return rels, nil
+}
+
+// findRelation finds a relation of table named name.
+func findRelation(rels []Relation, table, name string) (rel Relation, toOne bool, found bool) {
+	for _, rel := range rels {
+		if !strings.EqualFold(rel.Table, table) {
+			continue
+		}
+		n := rel.Name
+		if n == "" {
+			n = rel.RefTable
+		}
+		if strings.EqualFold(n, name) {
+			return rel, true, true
+		}
+	}
+	for _, rel := range rels {
+		if !strings.EqualFold(rel.RefTable, table) {
+			continue
+		}
+		n := rel.ReverseName
+		if n == "" {
+			n = rel.Table
+		}
+		if strings.EqualFold(n, name) {
+			return rel, false, true
+		}
+	}
+	return Relation{}, false, false
+}
+
+// embed adds a column of each Embed to rr by batched IN queries.
+func (r *FootREST) embed(ctx context.Context, q queryer, table string, rr records, embeds []Embed) (records, error) {
+	rels, err := r.getRelations(ctx)
+	if err != nil {
+		return records{}, err
+	}
+
+	for _, e := range embeds {
+		rel, toOne, found := findRelation(rels, table, e.Name)
+		if !found {
+			return records{}, errors.Errorf("embed: no relation %q of %q", e.Name, table)
+		}
+
+		keyColumns, subTable, subColumns := rel.RefColumns, rel.Table, rel.Columns
+		if toOne {
+			keyColumns, subTable, subColumns = rel.Columns, rel.RefTable, rel.RefColumns
+		}
+
+		keyIdx, err := columnIndices(rr.columns, keyColumns)
+		if err != nil {
+			return records{}, errors.Wrapf(err, "embed %q", e.Name)
+		}
+
+		// distinct keys
+
+		var keys [][]any
+		seen := make(map[string]bool)
+		for _, row := range rr.rows {
+			k, ks, ok := rowKey(row, keyIdx)
+			if !ok || seen[ks] {
+				continue
+			}
+			seen[ks] = true
+			keys = append(keys, k)
+		}
+
+		// select
+
+		// join keys not selected are added, and removed after joined
+		sel := e.Select
+		var added []string
+		if len(sel) != 0 && !(len(sel) == 1 && strings.TrimSpace(sel[0]) == "*") {
+			sel = append([]string{}, sel...)
+			for _, c := range subColumns {
+				if !equalsToAnyOfUpper(c, sel...) {
+					sel = append(sel, c)
+					added = append(added, c)
+				}
+			}
+		}
+
+		// keys per statement, under 1000 of an IN list of Oracle
+		perStmt := 500
+		if max := r.dialect.MaxParams / 2 / len(subColumns); r.dialect.MaxParams > 0 && max < perStmt {
+			perStmt = max
+		}
+
+		var columns []string
+		grouped := make(map[string][][]any)
+		for len(keys) > 0 {
+			n := min(perStmt, len(keys))
+			chunk := keys[:n]
+			keys = keys[n:]
+
+			strStmt, args, err := r.BuildGetStmtOpts(subTable, sel, e.Where, e.Order, 0, 0, GetOptions{in: &inCond{columns: subColumns, values: chunk}})
+			if err != nil {
+				return records{}, errors.Wrapf(err, "embed %q", e.Name)
+			}
+
+			subrr, err := r.query(ctx, q, strStmt, args)
+			if err != nil {
+				return records{}, errors.Wrapf(err, "embed %q", e.Name)
+			}
+			if len(e.Embeds) > 0 {
+				subrr, err = r.embed(ctx, q, subTable, subrr, e.Embeds)
+				if err != nil {
+					return records{}, errors.Wrapf(err, "embed %q", e.Name)
+				}
+			}
+
+			columns = subrr.columns
+			subIdx, err := columnIndices(subrr.columns, subColumns)
+			if err != nil {
+				return records{}, errors.Wrapf(err, "embed %q", e.Name)
+			}
+			for _, row := range subrr.rows {
+				_, ks, _ := rowKey(row, subIdx)
+				grouped[ks] = append(grouped[ks], row)
+			}
+		}
+
+		if len(added) > 0 {
+			columns, grouped = stripColumns(columns, grouped, added)
+		}
+
+		// attach
+
+		rr.columns = append(rr.columns[:len(rr.columns):len(rr.columns)], e.Name)
+		for i, row := range rr.rows {
+			_, ks, ok := rowKey(row, keyIdx)
+
+			var v any
+			if toOne {
+				if rows := grouped[ks]; ok && len(rows) > 0 {
+					v = record{columns: columns, values: rows[0]}
+				}
+			} else {
+				sub := records{columns: columns, rows: [][]any{}}
+				if ok {
+					sub.rows = append(sub.rows, grouped[ks]...)
+				}
+				v = sub
+			}
+			rr.rows[i] = append(row[:len(row):len(row)], v)
+		}
+	}
+
+	return rr, nil
+}
+
+// stripColumns removes names from columns and rows of grouped.
+func stripColumns(columns []string, grouped map[string][][]any, names []string) ([]string, map[string][][]any) {
+	var keep []int
+	var kept []string
+	for i, c := range columns {
+		if !equalsToAnyOfUpper(c, names...) {
+			keep = append(keep, i)
+			kept = append(kept, c)
+		}
+	}
+
+	for ks, rows := range grouped {
+		for i, row := range rows {
+			krow := make([]any, 0, len(keep))
+			for _, x := range keep {
+				krow = append(krow, row[x])
+			}
+			rows[i] = krow
+		}
+		grouped[ks] = rows
+	}
+
+	return kept, grouped
+}
+
+func isEmbedName(embeds []Embed, name string) bool {
+	for _, e := range embeds {
+		if strings.EqualFold(e.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func columnIndices(columns, names []string) ([]int, error) {
+	idx := make([]int, 0, len(names))
+	for _, n := range names {
+		found := false
+		for i, c := range columns {
+			if strings.EqualFold(c, n) {
+				idx = append(idx, i)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, errors.Errorf("column %q is not selected", n)
+		}
+	}
+	return idx, nil
+}
+
+// rowKey returns values of row at idx and its string form.
+// ok is false if any of them is NULL.
+func rowKey(row []any, idx []int) (key []any, ks string, ok bool) {
+	key = make([]any, len(idx))
+	for i, x := range idx {
+		if row[x] == nil {
+			return nil, "", false
+		}
+		key[i] = row[x]
+	}
+
+	data, err := json.Marshal(key)
+	if err != nil {
+		return key, fmt.Sprint(key...), true
+	}
+	return key, string(data), true
+}
@@ -0,0 +1,269 @@
+package footrest
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	echo "github.com/labstack/echo/v4"
+)
+
+// HeaderETag is a response header of an entity tag of a single row.
+const HeaderETag = "ETag"
+
+// HeaderIfMatch is a request header of an entity tag a row must have to be written.
+const HeaderIfMatch = "If-Match"
+
+// ErrPreconditionFailed is returned when a row does not match If-Match.
+var ErrPreconditionFailed = errors.New("precondition failed")
+
+// versionColumn returns a version column of table in config Versions.
+func (r *FootREST) versionColumn(table string) string {
+	col, _ := lookupFold(r.config.Versions, table)
+	return col
+}
+
+// etag returns an entity tag of rr if rr is a single row.
+//
+// It is the version column of the table, or a hash of the row if allColumns.
+// "" means no tag.
+func (r *FootREST) etag(table string, rr records, allColumns bool) string {
+	if len(rr.rows) != 1 {
+		return ""
+	}
+
+	if col := r.versionColumn(table); col != "" {
+		idx, err := columnIndices(rr.columns, []string{col})
+		if err != nil || rr.rows[0][idx[0]] == nil {
+			return ""
+		}
+		return strconv.Quote(fmt.Sprint(rr.rows[0][idx[0]]))
+	}
+
+	if !allColumns {
+		return ""
+	}
+	return rowHash(rr.columns, rr.rows[0])
+}
+
+func rowHash(columns []string, row []any) string {
+	data, err := json.Marshal(record{columns: columns, values: row})
+	if err != nil {
+		return ""
+	}
+	return contentHash(data)
+}
+
+// ifMatch returns where narrowed to the row of etag.
+//
+// With a version column, the version is added to where.
+// Otherwise, the row of where is read and locked by Dialect.LockRows in tx, and its hash is compared.
+// "*" matches any row, and a weak tag matches no row.
+func (r *FootREST) ifMatch(ctx context.Context, tx *sql.Tx, table, where, etag string) (string, error) {
+	etag = strings.TrimSpace(etag)
+	if strings.HasPrefix(etag, "W/") {
+		return "", errors.Wrapf(ErrPreconditionFailed, "weak etag %s", etag)
+	}
+	if etag == "*" {
+		return where, nil
+	}
+
+	if col := r.versionColumn(table); col != "" {
+		v, err := strconv.Unquote(etag)
+		if err != nil {
+			return "", errors.Wrapf(ErrPreconditionFailed, "invalid etag %s", etag)
+		}
+
+		lit, err := sexprLiteral(v)
+		if i, ierr := strconv.ParseInt(v, 10, 64); ierr == nil {
+			lit, err = sexprLiteral(i)
+		}
+		if err != nil {
+			return "", errors.Wrapf(ErrPreconditionFailed, "invalid etag %s", etag)
+		}
+		return andWhere(where, "(= ."+strings.ToUpper(col)+" "+lit+")"), nil
+	}
+
+	selStmt, selArgs, err := r.BuildGetStmtOpts(table, nil, where, nil, 0, 0, GetOptions{lock: true})
+	if err != nil {
+		return "", err
+	}
+
+	rr, err := r.queryTx(ctx, tx, selStmt, selArgs)
+	if err != nil {
+		return "", err
+	}
+	if len(rr.rows) != 1 || rowHash(rr.columns, rr.rows[0]) != etag {
+		return "", ErrPreconditionFailed
+	}
+	return where, nil
+}
+
+// PutIfMatch is Put of the row whose entity tag is etag.
+// If no row matches, ErrPreconditionFailed is returned.
+// If where matches more than one row, nothing is written.
+func (r *FootREST) PutIfMatch(ctx context.Context, table string, set map[string]any, where, etag string) (int64, error) {
+	_, ra, err := r.writeIfMatch(ctx, table, where, etag, func(where string) (string, []any, error) {
+		return r.BuildPutStmt(table, set, where)
+	}, nil)
+	return ra, err
+}
+
+// PutIfMatchReturning is PutIfMatch returning the written row.
+// The row is read back by its primary key in the same transaction.
+func (r *FootREST) PutIfMatchReturning(ctx context.Context, table string, set map[string]any, where, etag string) (records, int64, error) {
+	build := func(where string) (string, []any, error) {
+		return r.BuildPutStmt(table, set, where)
+	}
+	if _, _, err := build(where); err != nil {
+		return records{}, 0, err
+	}
+	if r.conn == nil {
+		return records{rows: [][]any{}}, 0, nil
+	}
+
+	keyColumns, err := r.primaryKey(ctx, table)
+	if err != nil {
+		return records{}, 0, err
+	}
+
+	return r.writeIfMatch(ctx, table, where, etag, build, func(tx *sql.Tx, where string) (func() (records, error), error) {
+		keyStmt, keyArgs, err := r.BuildGetStmt(table, keyColumns, where, nil, 0, 0)
+		if err != nil {
+			return nil, err
+		}
+		krr, err := r.queryTx(ctx, tx, keyStmt, keyArgs)
+		if err != nil {
+			return nil, err
+		}
+
+		return func() (records, error) {
+			// keys after updated
+			for i, c := range keyColumns {
+				for k, v := range set {
+					if strings.EqualFold(k, c) {
+						for _, row := range krr.rows {
+							row[i] = v
+						}
+					}
+				}
+			}
+			return r.queryByKeys(ctx, tx, table, keyColumns, krr.rows)
+		}, nil
+	})
+}
+
+// DeleteIfMatch is Delete of the row whose entity tag is etag.
+// If no row matches, ErrPreconditionFailed is returned.
+// If where matches more than one row, nothing is deleted.
+func (r *FootREST) DeleteIfMatch(ctx context.Context, table string, where, etag string) (int64, error) {
+	_, ra, err := r.writeIfMatch(ctx, table, where, etag, func(where string) (string, []any, error) {
+		return r.BuildDeleteStmt(table, where)
+	}, nil)
+	return ra, err
+}
+
+// DeleteIfMatchReturning is DeleteIfMatch returning the deleted row.
+// The row is read in the same transaction before it is deleted.
+func (r *FootREST) DeleteIfMatchReturning(ctx context.Context, table string, where, etag string) (records, int64, error) {
+	return r.writeIfMatch(ctx, table, where, etag, func(where string) (string, []any, error) {
+		return r.BuildDeleteStmt(table, where)
+	}, func(tx *sql.Tx, where string) (func() (records, error), error) {
+		selStmt, selArgs, err := r.BuildGetStmt(table, nil, where, nil, 0, 0)
+		if err != nil {
+			return nil, err
+		}
+		rr, err := r.queryTx(ctx, tx, selStmt, selArgs)
+		if err != nil {
+			return nil, err
+		}
+		return func() (records, error) {
+			return rr, nil
+		}, nil
+	})
+}
+
+// writeIfMatch writes a row of etag by a statement of build.
+//
+// If read is not nil, it is called in the transaction before the write with the narrowed where,
+// and the function it returns reads rows after the write.
+func (r *FootREST) writeIfMatch(ctx context.Context, table, where, etag string,
+	build func(where string) (string, []any, error),
+	read func(tx *sql.Tx, where string) (func() (records, error), error),
+) (records, int64, error) {
+	// validate and load the schema out of the transaction
+	if _, _, err := build(where); err != nil {
+		return records{}, 0, err
+	}
+
+	rr := records{rows: [][]any{}}
+	if r.conn == nil {
+		return rr, 0, nil
+	}
+
+	var ra int64
+	err := r.inTx(ctx, true, func(tx *sql.Tx) error {
+		w, err := r.ifMatch(ctx, tx, table, where, etag)
+		if err != nil {
+			return err
+		}
+
+		strStmt, args, err := build(w)
+		if err != nil {
+			return err
+		}
+
+		var after func() (records, error)
+		if read != nil {
+			after, err = read(tx, w)
+			if err != nil {
+				return err
+			}
+		}
+
+		ra, err = r.execTx(ctx, tx, strStmt, args)
+		if err != nil {
+			return err
+		}
+		if ra == 0 {
+			return ErrPreconditionFailed
+		}
+		if ra > 1 {
+			// rolled back
+			return errors.Errorf("If-Match is of a single row, where matches %d rows", ra)
+		}
+
+		if after != nil {
+			rr, err = after()
+		}
+		return err
+	})
+	if err != nil {
+		return records{}, 0, err
+	}
+
+	r.invalidate(table)
+
+	return rr, ra, nil
+}
+
+// ifMatchResponse responds to a write with If-Match,
+// with the written row and its new ETag if Prefer: return=representation.
+func (r *FootREST) ifMatchResponse(c echo.Context, table string, rr records, rowsAffected int64) error {
+	if !wantsRepresentation(c) {
+		return c.String(
+			http.StatusOK,
+			strings.ReplaceAll(r.config.Format.ExecOK, "%", strconv.FormatInt(rowsAffected, 10)))
+	}
+
+	if tag := r.etag(table, rr, true); tag != "" {
+		c.Response().Header().Set(HeaderETag, tag)
+	}
+	return representationResponse(c, r.config, rr)
+}
@@ -0,0 +1,202 @@
+package footrest
+
+import (
+	"database/sql"
+	"strings"
+
+	"github.com/fvbommel/sexpr"
+	"github.com/pkg/errors"
+)
+
+// kinds of expressions for type checking, "" means unknown.
+const (
+	exprText   = "text"
+	exprNumber = "number"
+	exprTime   = "time"
+	exprBool   = "bool"
+	exprAny    = "any"
+)
+
+// Function is a scalar function allowed in expressions of where, select and order.
+type Function struct {
+	Name   string
+	Format string   // "LEN($1)", "" means NAME($1, $2, ...)
+	Args   []string // kinds of args: "text", "number", "time", "bool" or "any". "any..." as the last one is variadic.
+	Result string   // kind of the result
+}
+
+// AddFunction adds a function name(args...) returning result.
+func (d *Dialect) AddFunction(name, format, result string, args ...string) {
+	if d.Functions == nil {
+		d.Functions = make(map[string]Function)
+	}
+	d.Functions[strings.ToUpper(name)] = Function{
+		Name:   strings.ToUpper(name),
+		Format: format,
+		Args:   args,
+		Result: result,
+	}
+}
+
+// addArithmetic adds an operator (op a b c) -> (a op b op c) of numbers.
+func (d *Dialect) addArithmetic(op string) {
+	d.AddOperator(op, "($1 "+op+" $2)", func(args ...string) (string, error) {
+		if len(args) < 2 {
+			return "", errors.Errorf("%s needs two or more operands", op)
+		}
+		return "(" + strings.Join(args, " "+op+" ") + ")", nil
+	})
+	o := d.Operators[op]
+	o.Operand = exprNumber
+	o.Result = exprNumber
+	d.Operators[op] = o
+}
+
+func (r *FootREST) buildFunction(f Function, cdr []*sexpr.Node, phnum *int, sc map[string]*sql.ColumnType) (string, []any, string, error) {
+	variadic := len(f.Args) > 0 && strings.HasSuffix(f.Args[len(f.Args)-1], "...")
+	if (!variadic && len(cdr) != len(f.Args)) || (variadic && len(cdr) < len(f.Args)) {
+		return "", nil, "", errors.Errorf("function %q: %d args, want %d", f.Name, len(cdr), len(f.Args))
+	}
+
+	args := []any{}
+	subww := make([]string, 0, len(cdr))
+	for i := range cdr {
+		want := f.Args[min(i, len(f.Args)-1)]
+		want = strings.TrimSuffix(want, "...")
+
+		w, a, kind, err := r.buildOperand(cdr, i, phnum, sc)
+		if err != nil {
+			return "", nil, "", err
+		}
+		if !kindMatches(want, kind) {
+			return "", nil, "", errors.Errorf("function %q: arg %d: %s is expected, got %s", f.Name, i+1, want, kind)
+		}
+		subww = append(subww, w)
+		args = append(args, a...)
+	}
+
+	if f.Format == "" {
+		return f.Name + "(" + strings.Join(subww, ", ") + ")", args, f.Result, nil
+	}
+
+	return formatArgs(f.Format, subww), args, f.Result, nil
+}
+
+// buildExprString builds an expression in S-expr whose placeholders start at *phnum.
+func (r *FootREST) buildExprString(expr string, phnum *int, sc map[string]*sql.ColumnType) (string, []any, error) {
+	node, err := parseSExpr(expr)
+	if err != nil {
+		return "", nil, err
+	}
+	w, args, _, err := r.buildExpr(node, phnum, sc)
+	return w, args, err
+}
+
+func kindMatches(want, got string) bool {
+	return want == "" || want == exprAny || got == "" || want == got
+}
+
+// exprKind returns a kind of a column type.
+func exprKind(typ *sql.ColumnType) string {
+	if typ == nil {
+		return ""
+	}
+
+	name := strings.ToUpper(typ.DatabaseTypeName())
+	switch {
+	case strings.Contains(name, "INTERVAL"), strings.Contains(name, "POINT"):
+		return ""
+
+	case strings.Contains(name, "CHAR"),
+		strings.Contains(name, "TEXT"),
+		strings.Contains(name, "CLOB"):
+		return exprText
+
+	case strings.Contains(name, "DATE"),
+		strings.Contains(name, "TIME"):
+		return exprTime
+
+	case strings.Contains(name, "INT"),
+		strings.Contains(name, "DEC"),
+		strings.Contains(name, "NUM"),
+		strings.Contains(name, "FLOAT"),
+		strings.Contains(name, "REAL"),
+		strings.Contains(name, "DOUBLE"),
+		strings.Contains(name, "MONEY"):
+		return exprNumber
+
+	case strings.Contains(name, "BOOL"):
+		return exprBool
+	}
+
+	return ""
+}
+
+// literalKind returns a kind of a value converted by conv.
+func literalKind(v any) string {
+	switch v.(type) {
+	case int, int64, float64:
+		return exprNumber
+	case bool:
+		return exprBool
+	case string:
+		return exprText
+	}
+	return ""
+}
+
+// computedColumn splits "alias=(expr)" of select.
+func computedColumn(c string) (alias, expr string, ok bool) {
+	eq := strings.Index(c, "=")
+	if eq == -1 {
+		return "", "", false
+	}
+	alias = strings.TrimSpace(c[:eq])
+	expr = strings.TrimSpace(c[eq+1:])
+	if !strings.HasPrefix(expr, "(") {
+		return "", "", false
+	}
+	return alias, expr, true
+}
+
+// exprColumns returns names of columns (.name) in node, out of subselects.
+func exprColumns(node *sexpr.Node) []string {
+	if node.Type == sexpr.TokListOpen {
+		if isSubselect(node) {
+			return nil
+		}
+		var columns []string
+		for _, c := range node.Children {
+			columns = append(columns, exprColumns(c)...)
+		}
+		return columns
+	}
+
+	if data := string(node.Data); node.Type != sexpr.TokString && strings.HasPrefix(data, ".") {
+		return []string{data[1:]}
+	}
+	return nil
+}
+
+// splitTopLevel splits s by commas out of parentheses and quotes.
+func splitTopLevel(s string) []string {
+	var result []string
+	depth := 0
+	quoted := false
+	last := 0
+	for i, c := range s {
+		switch {
+		case c == '\'':
+			quoted = !quoted
+		case quoted:
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == ',' && depth == 0:
+			result = append(result, s[last:i])
+			last = i + 1
+		}
+	}
+	return append(result, s[last:])
+}
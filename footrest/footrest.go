@@ -7,16 +7,18 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
+	"mime"
 	"net/http"
+	"os"
 	"path"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/pkg/errors"
-	"github.com/shu-go/rog"
 	"github.com/shu-go/stacktrace"
 
 	"github.com/fvbommel/sexpr"
@@ -34,12 +36,31 @@ type FootREST struct {
 
 	scMut       sync.Mutex
 	schemaCache map[string](map[string]*sql.ColumnType)
+	relations   []Relation          // nil until loaded
+	tables      []string            // exposed tables, nil until loaded
+	keys        map[string][]string // primary key columns by upper-cased table
 
 	colConds []colCond // prefix of a query parameter => where notation
 
+	txMut   sync.Mutex
+	openTxs map[string]*openTx // interactive transactions by id
+	opening int                // transactions being begun, counted in Tx.MaxOpen
+
+	cache   responseCache
+	stmts   stmtCache
+	metrics metrics
+
+	log *slog.Logger
+
 	config Config
 }
 
+// HeaderNextCursor is a response header of a keyset cursor of the next page.
+const HeaderNextCursor = "X-Footrest-Next"
+
+// HeaderPrefer is a request header, "Prefer: return=representation" returns written rows.
+const HeaderPrefer = "Prefer"
+
 // Columns is for FootREST.Get(ctx, "my_table", Columns("a", "b", "c"), ...)
 func Columns(cols ...string) []string {
 	if len(cols) == 0 {
@@ -55,8 +76,11 @@ type colCond struct {
 
 // New creates new FootREST with already Opened connection(*sql.DB).
 func New(conn *sql.DB, dialect string, enc encoding.Encoding, useSchema bool, config *Config) *FootREST {
-	d := GetDialect(dialect)
+	return NewDialect(conn, GetDialect(dialect), enc, useSchema, config)
+}
 
+// NewDialect is New with a Dialect not registered by RegisterDialect.
+func NewDialect(conn *sql.DB, d *Dialect, enc encoding.Encoding, useSchema bool, config *Config) *FootREST {
 	r := FootREST{
 		conn:      conn,
 		dialect:   d,
@@ -69,7 +93,20 @@ func New(conn *sql.DB, dialect string, enc encoding.Encoding, useSchema bool, co
 	} else {
 		r.config = *config
 	}
+	r.log = NewLogger(r.config.Log, os.Stderr)
 
+	r.colConds = append(r.colConds, colCond{
+		name: "in.",
+		f: func(k, v string) string {
+			return fmt.Sprintf("(in .%v %v)", k, strings.ReplaceAll(v, ",", " "))
+		},
+	})
+	r.colConds = append(r.colConds, colCond{
+		name: "notin.",
+		f: func(k, v string) string {
+			return fmt.Sprintf("(notin .%v %v)", k, strings.ReplaceAll(v, ",", " "))
+		},
+	})
 	r.colConds = append(r.colConds, colCond{
 		name: ">=",
 		f: func(k, v string) string {
@@ -123,20 +160,103 @@ func NewConn(driverName, dataSourceName string, enc encoding.Encoding, useSchema
 		return nil, nil, err
 	}
 
+	if config == nil {
+		config = DefaultConfig()
+	}
+	config.Pool.apply(conn)
+
+	if err := pingRetry(conn, config, NewLogger(config.Log, os.Stderr)); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+
 	return New(conn, driverName, enc, useSchema, config), conn, nil
 }
 
+// Serve serves the REST API at config Addr.
 func (r *FootREST) Serve() {
+	addr := r.config.Addr
+	if addr == "" {
+		addr = ":12345"
+	}
+
+	e := r.newEcho()
+	e.Logger.Fatal(e.Start(addr))
+}
+
+// Handler returns the REST API as an http.Handler, to be served by another server or tested.
+func (r *FootREST) Handler() http.Handler {
+	return r.newEcho()
+}
+
+func (r *FootREST) newEcho() *echo.Echo {
+	getPostgREST := func(c echo.Context) error {
+		table := strings.ToUpper(c.Param("table"))
+
+		q, err := r.parsePostgREST(c.QueryParams(), r.config.Params.Columnar)
+		if err != nil {
+			return errorResponse(c, r.config, err)
+		}
+
+		ctx, cancel, err := r.requestContext(c, table)
+		if err != nil {
+			return errorResponse(c, r.config, err)
+		}
+		defer cancel()
+		rs, err := r.GetOpts(ctx, table, q.Select, q.Where, q.Order, 0, 0, GetOptions{Embed: q.Embed, Limit: q.Limit, Offset: q.Offset})
+		if err != nil {
+			return errorResponse(c, r.config, err)
+		}
+		var tag string
+		if len(q.Embed) == 0 {
+			tag = r.etag(table, rs.Records, len(q.Select) == 0)
+		}
+
+		if b, err := strconv.ParseBool(c.QueryParam(r.config.Params.Columnar)); err == nil {
+			rs.Columnar = b
+		}
+
+		data, err := json.Marshal(rs.body())
+		if err != nil {
+			return errorResponse(c, r.config, err)
+		}
+
+		return r.queryResponse(c, table, tag, data)
+	}
+
 	restGet := func() echo.HandlerFunc {
 		return func(c echo.Context) error {
+			if strings.EqualFold(r.config.Syntax, SyntaxPostgREST) {
+				return getPostgREST(c)
+			}
+
 			table := strings.ToUpper(c.Param("table"))
 			sel := strings.ToUpper(c.QueryParam(r.config.Params.Select))
 			where := strings.ToUpper(c.QueryParam(r.config.Params.Where))
 			order := strings.ToUpper(c.QueryParam(r.config.Params.Order))
 
+			var embeds []Embed
+			if embed := c.QueryParam(r.config.Params.Embed); embed != "" {
+				for _, name := range strings.Split(embed, ",") {
+					e := Embed{Name: strings.TrimSpace(name)}
+					if s := strings.ToUpper(c.QueryParam(e.Name + "." + r.config.Params.Select)); s != "" {
+						e.Select = splitTopLevel(s)
+					}
+					e.Where = strings.ToUpper(c.QueryParam(e.Name + "." + r.config.Params.Where))
+					if o := strings.ToUpper(c.QueryParam(e.Name + "." + r.config.Params.Order)); o != "" {
+						e.Order = splitTopLevel(o)
+					}
+					embeds = append(embeds, e)
+				}
+			}
+
 			var extraWhere []string
 			for k, v := range c.QueryParams() {
-				if equalsToAnyOfUpper(k, r.config.Params.Select, r.config.Params.Where, r.config.Params.Order, r.config.Params.Rows, r.config.Params.Page) {
+				if equalsToAnyOfUpper(k, r.config.Params.Select, r.config.Params.Where, r.config.Params.Order, r.config.Params.Rows, r.config.Params.Page, r.config.Params.Columnar,
+					r.config.Params.Count, r.config.Params.Group, r.config.Params.After, r.config.Params.Embed, r.config.Params.Search) {
+					continue
+				}
+				if dot := strings.Index(k, "."); dot != -1 && isEmbedName(embeds, k[:dot]) {
 					continue
 				}
 
@@ -163,8 +283,8 @@ func (r *FootREST) Serve() {
 			if sel == "" {
 				sel = "*"
 			}
-			selColumns := strings.Split(sel, ",")
-			orderColumns := strings.Split(order, ",")
+			selColumns := splitTopLevel(sel)
+			orderColumns := splitTopLevel(order)
 			if order == "" {
 				orderColumns = nil
 			}
@@ -177,14 +297,122 @@ func (r *FootREST) Serve() {
 				page = uint(test)
 			}
 
-			ctx, cancel := r.config.Context()
+			var opts GetOptions
+			if b, err := strconv.ParseBool(c.QueryParam(r.config.Params.Count)); err == nil {
+				opts.Count = b
+			}
+			if group := strings.ToUpper(c.QueryParam(r.config.Params.Group)); group != "" {
+				opts.Group = strings.Split(group, ",")
+			}
+			if v, found := lookupFold(c.QueryParams(), r.config.Params.After); found {
+				var cursor string
+				if len(v) > 0 {
+					cursor = v[0]
+				}
+				after, err := decodeCursor(cursor)
+				if err != nil {
+					return errorResponse(c, r.config, err)
+				}
+				opts.After = after
+			}
+			opts.Embed = embeds
+			opts.Search = c.QueryParam(r.config.Params.Search)
+
+			ctx, cancel, err := r.requestContext(c, table)
+			if err != nil {
+				return errorResponse(c, r.config, err)
+			}
+			defer cancel()
+			rs, err := r.GetOpts(ctx, table, selColumns, where, orderColumns, rows, page, opts)
+			if err != nil {
+				return errorResponse(c, r.config, err)
+			}
+			if rs.Next != "" {
+				c.Response().Header().Set(HeaderNextCursor, rs.Next)
+			}
+			var tag string
+			if !opts.Count && opts.Group == nil && len(embeds) == 0 {
+				tag = r.etag(table, rs.Records, sel == "*")
+			}
+
+			if b, err := strconv.ParseBool(c.QueryParam(r.config.Params.Columnar)); err == nil {
+				rs.Columnar = b
+			}
+
+			data, err := json.Marshal(rs.body())
+			if err != nil {
+				return errorResponse(c, r.config, err)
+			}
+
+			return r.queryResponse(c, table, tag, data)
+		}
+	}
+
+	restTableQuery := func() echo.HandlerFunc {
+		return func(c echo.Context) error {
+			//
+			// POST http://localhost:12345/Table1/!query HTTP/1.1
+			// content-type: application/json
+			//
+			// {
+			//   "where": {"and": [{">=": [".Col2", 100]}, {"like": [".Col3", "abc%"]}]},
+			//   "select": ["Col1", "Col2"],
+			//   "order": ["-Col2"],
+			//   "rows": 10,
+			//   "page": 1
+			// }
+			//
+
+			table := c.Param("table")
+
+			data, err := io.ReadAll(c.Request().Body)
+			if err != nil {
+				return errorResponse(c, r.config, err)
+			}
+
+			var q queryReq
+			if len(bytes.TrimSpace(data)) > 0 {
+				err = json.Unmarshal(data, &q)
+				if err != nil {
+					return errorResponse(c, r.config, err)
+				}
+			}
+
+			where, err := WhereExpr(q.Where)
+			if err != nil {
+				return errorResponse(c, r.config, err)
+			}
+
+			opts := GetOptions{
+				Count:  q.Count,
+				Group:  q.Group,
+				Search: q.Search,
+			}
+			if q.After != nil {
+				opts.After, err = decodeCursor(*q.After)
+				if err != nil {
+					return errorResponse(c, r.config, err)
+				}
+			}
+
+			ctx, cancel, err := r.requestContext(c, table)
+			if err != nil {
+				return errorResponse(c, r.config, err)
+			}
 			defer cancel()
-			rs, err := r.Get(ctx, table, selColumns, where, orderColumns, rows, page)
+			rs, err := r.GetOpts(ctx, table, q.Select, where, q.Order, q.Rows, q.Page, opts)
 			if err != nil {
 				return errorResponse(c, r.config, err)
 			}
+			if rs.Next != "" {
+				c.Response().Header().Set(HeaderNextCursor, rs.Next)
+			}
+
+			if q.Columnar != nil {
+				rs.Columnar = *q.Columnar
+			}
 
-			data, err := json.Marshal(rs.Records)
+			data, err = json.Marshal(rs.body())
 			if err != nil {
 				return errorResponse(c, r.config, err)
 			}
@@ -207,12 +435,35 @@ func (r *FootREST) Serve() {
 				return errorResponse(c, r.config, err)
 			}
 
-			ctx, cancel := r.config.Context()
+			var opts BulkGetOptions
+			if iso := c.QueryParam(r.config.Params.Isolation); iso != "" {
+				opts.Consistent = true
+				opts.Isolation, err = parseIsolation(iso)
+				if err != nil {
+					return errorResponse(c, r.config, err)
+				}
+			}
+			if n, err := strconv.Atoi(c.QueryParam(r.config.Params.Parallel)); err == nil {
+				opts.Parallel = n
+				if max := r.config.BulkGet.MaxParallel; opts.Parallel > max {
+					opts.Parallel = max
+				}
+			}
+
+			ctx, cancel, err := r.requestContext(c, "!bulkget")
+			if err != nil {
+				return errorResponse(c, r.config, err)
+			}
 			defer cancel()
-			bulkrs, err := r.BulkGet(ctx, b)
+			bulkrs, err := r.BulkGet(ctx, b, opts)
 			if err != nil {
 				return errorResponse(c, r.config, err)
 			}
+			if b, err := strconv.ParseBool(c.QueryParam(r.config.Params.Columnar)); err == nil {
+				for i := range bulkrs {
+					bulkrs[i].Columnar = b
+				}
+			}
 
 			data, err = json.Marshal(bulkrs)
 			if err != nil {
@@ -261,22 +512,75 @@ func (r *FootREST) Serve() {
 				return errorResponse(c, r.config, err)
 			}
 
-			ctx, cancel := r.config.Context()
+			var opts BulkOptions
+			if b, err := strconv.ParseBool(c.QueryParam(r.config.Params.Continue)); err == nil {
+				opts.ContinueOnError = b
+			}
+
+			ctx, cancel, err := r.requestContext(c, "!bulk")
+			if err != nil {
+				return errorResponse(c, r.config, err)
+			}
 			defer cancel()
-			rowsAffected, err := r.Bulk(ctx, b)
+			results, err := r.Bulk(ctx, b, opts)
+			if err != nil {
+				return errorResponse(c, r.config, err)
+			}
+
+			data, err = json.Marshal(results)
 			if err != nil {
 				return errorResponse(c, r.config, err)
 			}
 
 			return c.String(
 				http.StatusOK,
-				strings.ReplaceAll(r.config.Format.ExecOK, "%", strconv.FormatInt(rowsAffected, 10)))
+				strings.ReplaceAll(r.config.Format.ExecOK, "%", string(data)))
+		}
+	}
+	restImport := func(c echo.Context, table string, mediaType string) error {
+		var src recordSource
+		if mediaType == "text/csv" {
+			csvsrc, err := r.newCSVSource(table, c.Request().Body)
+			if err != nil {
+				return errorResponse(c, r.config, err)
+			}
+			src = csvsrc
+		} else {
+			src = newNDJSONSource(c.Request().Body)
+		}
+
+		// a long import is not cut off by Timeout
+		name := importRoute
+		if _, found := lookupFold(r.config.Timeouts, table); found {
+			name = table
+		}
+		ctx, cancel, err := r.requestContext(c, name)
+		if err != nil {
+			return errorResponse(c, r.config, err)
+		}
+		defer cancel()
+		report, err := r.Import(ctx, table, src, r.config.Import.BatchSize)
+		if err != nil {
+			return errorResponse(c, r.config, err)
+		}
+
+		data, err := json.Marshal(report)
+		if err != nil {
+			return errorResponse(c, r.config, err)
 		}
+
+		return c.String(http.StatusOK, strings.ReplaceAll(r.config.Format.ExecOK, "%", string(data)))
 	}
 	restPost := func() echo.HandlerFunc {
 		return func(c echo.Context) error {
 			table := strings.ToUpper(c.Param("table"))
 
+			mediaType, _, _ := mime.ParseMediaType(c.Request().Header.Get(echo.HeaderContentType))
+			switch mediaType {
+			case "text/csv", "application/x-ndjson", "application/ndjson", "application/jsonl":
+				return restImport(c, table, mediaType)
+			}
+
 			data, err := io.ReadAll(c.Request().Body)
 			if err != nil {
 				return errorResponse(c, r.config, err)
@@ -305,8 +609,18 @@ func (r *FootREST) Serve() {
 				return errorResponse(c, r.config, err)
 			}
 
-			ctx, cancel := r.config.Context()
+			ctx, cancel, err := r.requestContext(c, table)
+			if err != nil {
+				return errorResponse(c, r.config, err)
+			}
 			defer cancel()
+			if wantsRepresentation(c) {
+				rr, err := r.PostReturning(ctx, table, records)
+				if err != nil {
+					return errorResponse(c, r.config, err)
+				}
+				return representationResponse(c, r.config, rr)
+			}
 			rowsAffected, err := r.Post(ctx, table, records)
 			if err != nil {
 				return errorResponse(c, r.config, err)
@@ -323,7 +637,7 @@ func (r *FootREST) Serve() {
 			where := strings.ToUpper(c.QueryParam(r.config.Params.Where))
 			upsert := false
 			if b, err := strconv.ParseBool(strings.ToUpper(c.QueryParam(r.config.Params.Upsert))); err == nil {
-				upsert = b
+				upsert = b && c.Request().Method == http.MethodPut
 			}
 
 			data, err := io.ReadAll(c.Request().Body)
@@ -366,9 +680,45 @@ func (r *FootREST) Serve() {
 			if len(extraWhere) > 0 {
 				where = fmt.Sprintf("(AND %v %v)", where, strings.Join(extraWhere, ""))
 			}
+			if strings.EqualFold(r.config.Syntax, SyntaxPostgREST) {
+				q, err := r.parsePostgREST(c.QueryParams(), r.config.Params.Upsert)
+				if err != nil {
+					return errorResponse(c, r.config, err)
+				}
+				where = q.Where
+			}
 
-			ctx, cancel := r.config.Context()
+			ctx, cancel, err := r.requestContext(c, table)
+			if err != nil {
+				return errorResponse(c, r.config, err)
+			}
 			defer cancel()
+			if etag := c.Request().Header.Get(HeaderIfMatch); etag != "" {
+				var rr records
+				var rowsAffected int64
+				if wantsRepresentation(c) {
+					rr, rowsAffected, err = r.PutIfMatchReturning(ctx, table, set, where, etag)
+				} else {
+					rowsAffected, err = r.PutIfMatch(ctx, table, set, where, etag)
+				}
+				if err != nil {
+					return errorResponse(c, r.config, err)
+				}
+				return r.ifMatchResponse(c, table, rr, rowsAffected)
+			}
+			if wantsRepresentation(c) {
+				rr, rowsAffected, err := r.PutReturning(ctx, table, set, where)
+				if err != nil {
+					return errorResponse(c, r.config, err)
+				}
+				if upsert && rowsAffected == 0 {
+					rr, err = r.PostReturning(ctx, table, set)
+					if err != nil {
+						return errorResponse(c, r.config, err)
+					}
+				}
+				return representationResponse(c, r.config, rr)
+			}
 			rowsAffected, err := r.Put(ctx, table, set, where)
 			if err != nil {
 				return errorResponse(c, r.config, err)
@@ -416,9 +766,42 @@ func (r *FootREST) Serve() {
 			if len(extraWhere) > 0 {
 				where = fmt.Sprintf("(AND %v %v)", where, strings.Join(extraWhere, ""))
 			}
+			if strings.EqualFold(r.config.Syntax, SyntaxPostgREST) {
+				q, err := r.parsePostgREST(c.QueryParams())
+				if err != nil {
+					return errorResponse(c, r.config, err)
+				}
+				where = q.Where
+			}
 
-			ctx, cancel := r.config.Context()
+			ctx, cancel, err := r.requestContext(c, table)
+			if err != nil {
+				return errorResponse(c, r.config, err)
+			}
 			defer cancel()
+			if etag := c.Request().Header.Get(HeaderIfMatch); etag != "" {
+				if wantsRepresentation(c) {
+					rr, _, err := r.DeleteIfMatchReturning(ctx, table, where, etag)
+					if err != nil {
+						return errorResponse(c, r.config, err)
+					}
+					return representationResponse(c, r.config, rr)
+				}
+				rowsAffected, err := r.DeleteIfMatch(ctx, table, where, etag)
+				if err != nil {
+					return errorResponse(c, r.config, err)
+				}
+				return c.String(
+					http.StatusOK,
+					strings.ReplaceAll(r.config.Format.ExecOK, "%", strconv.FormatInt(rowsAffected, 10)))
+			}
+			if wantsRepresentation(c) {
+				rr, err := r.DeleteReturning(ctx, table, where)
+				if err != nil {
+					return errorResponse(c, r.config, err)
+				}
+				return representationResponse(c, r.config, rr)
+			}
 			rowsAffected, err := r.Delete(ctx, table, where)
 			if err != nil {
 				return errorResponse(c, r.config, err)
@@ -430,640 +813,1351 @@ func (r *FootREST) Serve() {
 		}
 	}
 
-	theURL := path.Join(r.config.Root, ":table")
-	bulkURL := path.Join(r.config.Root, "!bulk")
-	bulkGetURL := path.Join(r.config.Root, "!bulkget")
+	restQuery := func() echo.HandlerFunc {
+		return func(c echo.Context) error {
+			name := c.Param("name")
+			order := c.QueryParam(r.config.Params.Order)
 
-	e := echo.New()
-	e.HideBanner = true
-	e.Use(middleware.Secure())
-	e.Use(middleware.CORS())
-	e.Use(middleware.Logger())
-	e.Use(stacktraceMiddleware)
+			params := make(map[string]string)
+			for k, v := range c.QueryParams() {
+				if equalsToAnyOfUpper(k, r.config.Params.Order, r.config.Params.Rows, r.config.Params.Page, r.config.Params.Columnar) {
+					continue
+				}
+				if len(v) > 0 {
+					params[k] = v[0]
+				}
+			}
 
-	e.POST(bulkURL, restBulk())
-	e.POST(bulkGetURL, restBulkGet())
-	e.GET(bulkURL, restBulkGet())
+			orderColumns := strings.Split(order, ",")
+			if order == "" {
+				orderColumns = nil
+			}
 
-	e.GET(theURL, restGet())
-	e.POST(theURL, restPost())
-	e.PUT(theURL, restPut())
-	e.DELETE(theURL, restDelete())
+			var rows, page uint
+			if test, err := strconv.ParseInt(c.QueryParam(r.config.Params.Rows), 10, 64); err == nil {
+				rows = uint(test)
+			}
+			if test, err := strconv.ParseInt(c.QueryParam(r.config.Params.Page), 10, 64); err == nil {
+				page = uint(test)
+			}
 
-	addr := r.config.Addr
-	if addr == "" {
-		addr = ":12345"
-	}
-	e.Logger.Fatal(e.Start(addr))
-}
+			ctx, cancel, err := r.requestContext(c, "!query/"+name)
+			if err != nil {
+				return errorResponse(c, r.config, err)
+			}
+			defer cancel()
+			rs, err := r.NamedQuery(ctx, name, params, orderColumns, rows, page)
+			if err != nil {
+				return errorResponse(c, r.config, err)
+			}
 
-func (r *FootREST) Get(ctx context.Context, table string, selColumns []string, whereSExpr string, orderColumns []string, rowsPerPage, page uint) (recordSet, error) {
-	strStmt, args, err := r.BuildGetStmt(table, selColumns, whereSExpr, orderColumns, rowsPerPage, page)
-	if err != nil {
-		return recordSet{}, err
-	}
+			if b, err := strconv.ParseBool(c.QueryParam(r.config.Params.Columnar)); err == nil {
+				rs.Columnar = b
+			}
 
-	rog.Debug("GET:")
-	rog.Debug("  stmt=", strStmt)
-	rog.Debug("  args=", args)
+			data, err := json.Marshal(rs.body())
+			if err != nil {
+				return errorResponse(c, r.config, err)
+			}
 
-	dbStmt, err := r.conn.PrepareContext(ctx, strStmt)
-	if err != nil {
+			return c.String(http.StatusOK, strings.ReplaceAll(r.config.Format.QueryOK, "%", string(data)))
+		}
+	}
+
+	restCall := func() echo.HandlerFunc {
+		return func(c echo.Context) error {
+			//
+			// POST http://localhost:12345/!call/Proc1 HTTP/1.1
+			// content-type: application/json
+			//
+			// [
+			//   {"name": "Param1", "value": 123},
+			//   {"name": "Param2", "direction": "out", "type": "int"}
+			// ]
+			//
+
+			procedure := c.Param("procedure")
+
+			data, err := io.ReadAll(c.Request().Body)
+			if err != nil {
+				return errorResponse(c, r.config, err)
+			}
+
+			var params []SPParam
+			if len(bytes.TrimSpace(data)) > 0 {
+				err = json.Unmarshal(data, &params)
+				if err != nil {
+					return errorResponse(c, r.config, err)
+				}
+			}
+
+			ctx, cancel, err := r.requestContext(c, "!call")
+			if err != nil {
+				return errorResponse(c, r.config, err)
+			}
+			defer cancel()
+			result, err := r.Call(ctx, procedure, params)
+			if err != nil {
+				return errorResponse(c, r.config, err)
+			}
+
+			jsonBytes, err := json.Marshal(result)
+			if err != nil {
+				return errorResponse(c, r.config, err)
+			}
+
+			return c.String(
+				http.StatusOK,
+				strings.ReplaceAll(r.config.Format.ExecOK, "%", string(jsonBytes)))
+		}
+	}
+
+	restTx := func() echo.HandlerFunc {
+		return func(c echo.Context) error {
+			ctx, cancel := r.config.ContextFor("!tx")
+			defer cancel()
+			ctx = withLogger(ctx, r.logger(c.Request().Context()))
+
+			ctx, err := r.isolationContext(ctx, c.Request().Header.Get(HeaderIsolation))
+			if err != nil {
+				return errorResponse(c, r.config, err)
+			}
+
+			id, err := r.OpenTx(ctx)
+			if err != nil {
+				return errorResponse(c, r.config, err)
+			}
+
+			return c.String(
+				http.StatusOK,
+				strings.ReplaceAll(r.config.Format.ExecOK, "%", `"`+escape(id)+`"`))
+		}
+	}
+	restTxEnd := func() echo.HandlerFunc {
+		return func(c echo.Context) error {
+			id := c.Param("id")
+
+			var err error
+			switch strings.ToLower(c.Param("action")) {
+			case "commit":
+				err = r.CommitTx(id)
+			case "rollback":
+				err = r.RollbackTx(id)
+			default:
+				err = errors.Errorf("unknown action %q", c.Param("action"))
+			}
+			if err != nil {
+				return errorResponse(c, r.config, err)
+			}
+
+			return c.String(
+				http.StatusOK,
+				strings.ReplaceAll(r.config.Format.ExecOK, "%", `"`+escape(id)+`"`))
+		}
+	}
+
+	odataService := func() echo.HandlerFunc {
+		return func(c echo.Context) error {
+			ctx, cancel, err := r.requestContext(c, "!odata")
+			if err != nil {
+				return odataError(c, http.StatusBadRequest, err)
+			}
+			defer cancel()
+			tables, _, err := r.getTables(ctx)
+			if err != nil {
+				return odataError(c, odataStatus(err), err)
+			}
+
+			sets := make([]map[string]string, 0, len(tables))
+			for _, t := range tables {
+				sets = append(sets, map[string]string{"name": t, "kind": "EntitySet", "url": t})
+			}
+			return c.JSON(http.StatusOK, map[string]any{
+				"@odata.context": "$metadata",
+				"value":          sets,
+			})
+		}
+	}
+	odataMetadata := func() echo.HandlerFunc {
+		return func(c echo.Context) error {
+			ctx, cancel, err := r.requestContext(c, "!odata")
+			if err != nil {
+				return odataError(c, http.StatusBadRequest, err)
+			}
+			defer cancel()
+			data, err := r.ODataMetadata(ctx)
+			if err != nil {
+				return odataError(c, odataStatus(err), err)
+			}
+
+			return c.Blob(http.StatusOK, echo.MIMEApplicationXMLCharsetUTF8, data)
+		}
+	}
+	odataGet := func() echo.HandlerFunc {
+		return func(c echo.Context) error {
+			table := c.Param("table")
+
+			q, err := parseOData(c.QueryParams())
+			if err != nil {
+				return odataError(c, http.StatusBadRequest, err)
+			}
+
+			ctx, cancel, err := r.requestContext(c, table)
+			if err != nil {
+				return odataError(c, http.StatusBadRequest, err)
+			}
+			defer cancel()
+			rr, count, err := r.odataGet(ctx, strings.ToUpper(table), q)
+			if err != nil {
+				return odataError(c, odataStatus(err), err)
+			}
+
+			data, err := json.Marshal(rr)
+			if err != nil {
+				return odataError(c, http.StatusInternalServerError, err)
+			}
+
+			buf := bytes.Buffer{}
+			buf.WriteString(`{"@odata.context":"$metadata#` + escape(table) + `"`)
+			if count != nil {
+				cdata, err := json.Marshal(count)
+				if err != nil {
+					return odataError(c, http.StatusInternalServerError, err)
+				}
+				buf.WriteString(`,"@odata.count":`)
+				buf.Write(cdata)
+			}
+			buf.WriteString(`,"value":`)
+			buf.Write(data)
+			buf.WriteString(`}`)
+
+			return c.Blob(http.StatusOK, echo.MIMEApplicationJSONCharsetUTF8, buf.Bytes())
+		}
+	}
+	odataCount := func() echo.HandlerFunc {
+		return func(c echo.Context) error {
+			table := strings.ToUpper(c.Param("table"))
+
+			q, err := parseOData(c.QueryParams())
+			if err != nil {
+				return odataError(c, http.StatusBadRequest, err)
+			}
+
+			ctx, cancel, err := r.requestContext(c, table)
+			if err != nil {
+				return odataError(c, http.StatusBadRequest, err)
+			}
+			defer cancel()
+			count, err := r.odataCount(ctx, table, q.Where)
+			if err != nil {
+				return odataError(c, odataStatus(err), err)
+			}
+
+			return c.String(http.StatusOK, fmt.Sprint(count))
+		}
+	}
+
+	graphqlSchema := func() echo.HandlerFunc {
+		return func(c echo.Context) error {
+			ctx, cancel, err := r.requestContext(c, "!graphql")
+			if err != nil {
+				return errorResponse(c, r.config, err)
+			}
+			defer cancel()
+			sdl, err := r.GraphQLSchema(ctx)
+			if err != nil {
+				return errorResponse(c, r.config, err)
+			}
+
+			return c.String(http.StatusOK, sdl)
+		}
+	}
+	graphql := func() echo.HandlerFunc {
+		return func(c echo.Context) error {
+			respond := func(data any, err error) error {
+				resp := map[string]any{"data": data}
+				if err != nil {
+					resp["errors"] = []map[string]string{{"message": err.Error()}}
+				}
+				_ = c.JSON(http.StatusOK, resp)
+				return err
+			}
+
+			var req graphqlReq
+			if err := json.NewDecoder(c.Request().Body).Decode(&req); err != nil {
+				return respond(nil, errors.Wrap(err, "body"))
+			}
+
+			ctx, cancel, err := r.requestContext(c, "!graphql")
+			if err != nil {
+				return respond(nil, err)
+			}
+			defer cancel()
+			data, err := r.GraphQL(ctx, req.Query, req.Variables, req.OperationName)
+			if err != nil {
+				return respond(nil, err)
+			}
+
+			return respond(data, nil)
+		}
+	}
+
+	health := func() echo.HandlerFunc {
+		return func(c echo.Context) error {
+			return c.JSON(http.StatusOK, healthStatus{Status: "ok"})
+		}
+	}
+
+	ready := func() echo.HandlerFunc {
+		return func(c echo.Context) error {
+			ctx, cancel := r.config.ContextFor("!ready")
+			defer cancel()
+
+			if err := r.Ping(ctx); err != nil {
+				return c.JSON(http.StatusServiceUnavailable, healthStatus{Status: "unavailable", Error: err.Error()})
+			}
+			return c.JSON(http.StatusOK, healthStatus{Status: "ok"})
+		}
+	}
+
+	metricsHandler := func() echo.HandlerFunc {
+		return func(c echo.Context) error {
+			return c.Blob(http.StatusOK, metricsContentType, r.Metrics())
+		}
+	}
+
+	theURL := path.Join(r.config.Root, ":table")
+	tableQueryURL := path.Join(r.config.Root, ":table", "!query")
+	txURL := path.Join(r.config.Root, "!tx")
+	txEndURL := path.Join(r.config.Root, "!tx", ":id", ":action")
+	bulkURL := path.Join(r.config.Root, "!bulk")
+	bulkGetURL := path.Join(r.config.Root, "!bulkget")
+	callURL := path.Join(r.config.Root, "!call", ":procedure")
+	queryURL := path.Join(r.config.Root, "!query", ":name")
+	odataURL := path.Join(r.config.Root, "odata")
+	graphqlURL := path.Join(r.config.Root, "!graphql")
+	healthURL := path.Join(r.config.Root, "!health")
+	readyURL := path.Join(r.config.Root, "!ready")
+	metricsURL := path.Join(r.config.Root, "!metrics")
+
+	e := echo.New()
+	e.HideBanner = true
+	e.Use(middleware.Secure())
+	e.Use(middleware.CORS())
+	e.Use(r.logMiddleware)
+	e.Use(r.metricsMiddleware)
+	e.Use(r.stacktraceMiddleware)
+
+	e.GET(healthURL, health())
+	e.GET(readyURL, ready())
+	e.GET(metricsURL, metricsHandler())
+
+	e.POST(txURL, restTx())
+	e.POST(txEndURL, restTxEnd())
+
+	e.POST(bulkURL, restBulk())
+	e.POST(bulkGetURL, restBulkGet())
+	e.GET(bulkURL, restBulkGet())
+
+	e.POST(callURL, restCall())
+	e.GET(queryURL, restQuery())
+
+	e.POST(graphqlURL, graphql())
+	e.GET(graphqlURL, graphqlSchema())
+
+	e.GET(odataURL, odataService())
+	e.GET(odataURL+"/", odataService())
+	e.GET(path.Join(odataURL, "$metadata"), odataMetadata())
+	e.GET(path.Join(odataURL, ":table"), odataGet())
+	e.GET(path.Join(odataURL, ":table", "$count"), odataCount())
+
+	e.GET(theURL, restGet())
+	e.POST(tableQueryURL, restTableQuery())
+	e.POST(theURL, restPost())
+	e.PUT(theURL, restPut())
+	e.PATCH(theURL, restPut())
+	e.DELETE(theURL, restDelete())
+
+	return e
+}
+
+func (r *FootREST) Get(ctx context.Context, table string, selColumns []string, whereSExpr string, orderColumns []string, rowsPerPage, page uint) (recordSet, error) {
+	return r.GetOpts(ctx, table, selColumns, whereSExpr, orderColumns, rowsPerPage, page, GetOptions{})
+}
+
+// GetOpts is Get with GetOptions.
+//
+// If opts.After is not nil, the result has a cursor of the next page.
+func (r *FootREST) GetOpts(ctx context.Context, table string, selColumns []string, whereSExpr string, orderColumns []string, rowsPerPage, page uint, opts GetOptions) (recordSet, error) {
+	strStmt, args, err := r.BuildGetStmtOpts(table, selColumns, whereSExpr, orderColumns, rowsPerPage, page, opts)
+	if err != nil {
 		return recordSet{}, err
 	}
-	defer dbStmt.Close()
 
-	rows, err := dbStmt.QueryContext(ctx, args...)
+	// results in a transaction or with embeds are not cached
+	ttl := r.cacheTTL(table)
+	var key string
+	if ttl > 0 && len(opts.Embed) == 0 && openTxFrom(ctx) == nil {
+		key = cacheKey(strStmt, args)
+		if rs, found := r.cache.get(key); key != "" && found {
+			r.logger(ctx).Debug("cache hit", slog.String("stmt", strStmt))
+			return rs, nil
+		}
+	}
+
+	rr, err := r.query(ctx, r.queryerFrom(ctx), strStmt, args)
+	if err != nil {
+		return recordSet{}, err
+	}
+
+	if len(opts.Embed) > 0 {
+		rr, err = r.embed(ctx, r.queryerFrom(ctx), table, rr, opts.Embed)
+		if err != nil {
+			return recordSet{}, err
+		}
+	}
+
+	rs := recordSet{Table: table, Records: rr, Columnar: r.config.Output.Columnar}
+	if opts.After != nil {
+		rs.Next, err = nextCursor(rr, orderColumns, rowsPerPage)
+		if err != nil {
+			return recordSet{}, err
+		}
+	}
+
+	if key != "" {
+		r.cache.put(key, cacheEntry{
+			rs:      rs,
+			tables:  readTables(table, whereSExpr),
+			expires: time.Now().Add(ttl),
+		}, r.config.Cache.MaxEntries)
+	}
+
+	return rs, nil
+}
+
+type bulkGetReqElem struct {
+	Table     string            `json:"table"`
+	Where     map[string]string `json:"where"`
+	WhereExpr json.RawMessage   `json:"whereExpr"` // S-expr string or JSON-array form
+	Select    []string          `json:"select"`
+	Order     []string          `json:"order"`
+	Rows      uint              `json:"rows"`
+	Page      uint              `json:"page"`
+	Count     bool              `json:"count"`
+	Group     []string          `json:"group"`
+	After     *string           `json:"after"` // keyset cursor, "" for the first page
+}
+type bulkGetReq []bulkGetReqElem
+
+// queryReq is a body of POST /{table}/!query.
+type queryReq struct {
+	Where    json.RawMessage `json:"where"` // JSON-object form, JSON-array form or S-expr string
+	Select   []string        `json:"select"`
+	Order    []string        `json:"order"`
+	Rows     uint            `json:"rows"`
+	Page     uint            `json:"page"`
+	Count    bool            `json:"count"`
+	Group    []string        `json:"group"`
+	After    *string         `json:"after"` // keyset cursor, "" for the first page
+	Search   string          `json:"search"`
+	Columnar *bool           `json:"columnar"`
+}
+
+type bulkRecordSet []recordSet
+
+// mapWhere converts column conditions {"Col1": "'123'", "Col2": ">=100"} into a where S-expr.
+func (r *FootREST) mapWhere(conds map[string]string) string {
+	if len(conds) == 0 {
+		return ""
+	}
+
+	var extraWhere []string
+	for k, v := range conds {
+		var cond func(k, v string) string
+		for _, cc := range r.colConds {
+			if strings.HasPrefix(strings.ToUpper(v), strings.ToUpper(cc.name)) {
+				cond = cc.f
+				v = v[len(cc.name):]
+			}
+		}
+		if cond == nil {
+			cond = func(k, v string) string {
+				return fmt.Sprintf("(= .%v %v)", k, v)
+			}
+		}
+		extraWhere = append(extraWhere, cond(k, v))
+	}
+
+	return fmt.Sprintf("(AND %v)", strings.Join(extraWhere, ""))
+}
+
+// BulkGetOptions controls FootREST.BulkGet.
+type BulkGetOptions struct {
+	// Consistent reads all in one read-only transaction of Isolation.
+	//
+	// Isolation is ignored by a dialect of Dialect.SetReadOnly.
+	Consistent bool
+	Isolation  sql.IsolationLevel
+
+	// Parallel is the max number of concurrent queries if not Consistent.
+	// 0 or 1 means sequential.
+	Parallel int
+}
+
+func (r *FootREST) BulkGet(ctx context.Context, b bulkGetReq, opts BulkGetOptions) (bulkRecordSet, error) {
+	if r.conn == nil {
+		return nil, nil
+	}
+
+	bulkrs := make(bulkRecordSet, len(b))
+
+	if otx := openTxFrom(ctx); otx != nil {
+		for i, m := range b {
+			var err error
+			bulkrs[i], err = r.bulkGetElem(ctx, otx.tx, m)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		return bulkrs, nil
+	}
+
+	if opts.Consistent {
+		for _, m := range b {
+			if r.isValidName(m.Table) {
+				_ = r.loadSchemas(m.Table) // an error is of the element
+			}
+		}
+
+		if r.dialect.SetReadOnly != "" {
+			ctx = WithTxOptions(ctx, nil)
+		} else {
+			ctx = WithTxOptions(ctx, &sql.TxOptions{Isolation: opts.Isolation, ReadOnly: r.dialect.ReadOnlyTx})
+		}
+		err := r.inTx(ctx, true, func(tx *sql.Tx) error {
+			if r.dialect.SetReadOnly != "" {
+				if _, err := tx.ExecContext(ctx, r.dialect.SetReadOnly); err != nil {
+					return err
+				}
+			}
+
+			for i, m := range b {
+				var err error
+				bulkrs[i], err = r.bulkGetElem(ctx, tx, m)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		return bulkrs, nil
+	}
+
+	if opts.Parallel <= 1 {
+		for i, m := range b {
+			var err error
+			bulkrs[i], err = r.bulkGetElem(ctx, r.conn, m)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		return bulkrs, nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	var errOnce sync.Once
+	var firstErr error
+	sem := make(chan struct{}, opts.Parallel)
+
+	for i, m := range b {
+		// stop at the first error or the end of ctx
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func(i int, m bulkGetReqElem) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			rs, err := r.bulkGetElem(ctx, r.conn, m)
+			if err != nil {
+				errOnce.Do(func() {
+					firstErr = err
+					cancel()
+				})
+				return
+			}
+			bulkrs[i] = rs
+		}(i, m)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return bulkrs, nil
+}
+
+func (r *FootREST) bulkGetElem(ctx context.Context, q queryer, m bulkGetReqElem) (recordSet, error) {
+	wexpr, err := WhereExpr(m.WhereExpr)
+	if err != nil {
+		return recordSet{}, err
+	}
+	where := andWhere(r.mapWhere(m.Where), wexpr)
+
+	selColumns := m.Select
+	if len(selColumns) == 0 {
+		selColumns = []string{"*"}
+	}
+
+	opts := GetOptions{
+		Count: m.Count,
+		Group: m.Group,
+	}
+	if m.After != nil {
+		opts.After, err = decodeCursor(*m.After)
+		if err != nil {
+			return recordSet{}, err
+		}
+	}
+
+	strStmt, args, err := r.BuildGetStmtOpts(m.Table, selColumns, where, m.Order, m.Rows, m.Page, opts)
+	if err != nil {
+		return recordSet{}, err
+	}
+
+	rr, err := r.query(ctx, q, strStmt, args)
+	if err != nil {
+		return recordSet{}, err
+	}
+
+	rs := recordSet{
+		Table:    m.Table,
+		Records:  rr,
+		Columnar: r.config.Output.Columnar,
+	}
+	if opts.After != nil {
+		rs.Next, err = nextCursor(rr, m.Order, m.Rows)
+		if err != nil {
+			return recordSet{}, err
+		}
+	}
+
+	return rs, nil
+}
+
+func (r *FootREST) Post(ctx context.Context, table string, values any) (int64, error) {
+	strStmts, argss, err := r.BuildPostStmts(table, values)
+	if err != nil {
+		return 0, err
+	}
+
+	if r.conn == nil {
+		return 0, nil
+	}
+
+	r.prepareCache(ctx, strStmts...)
+
+	var ra int64
+	err = r.inTx(ctx, true, func(tx *sql.Tx) error {
+		ra = 0
+		for i := range strStmts {
+			rra, err := r.execTx(ctx, tx, strStmts[i], argss[i])
+			if err != nil {
+				return err
+			}
+			ra += rra
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	r.invalidate(table)
+
+	return ra, nil
+}
+
+func (r *FootREST) Put(ctx context.Context, table string, set map[string]any, where string) (int64, error) {
+	strStmt, args, err := r.BuildPutStmt(table, set, where)
+	if err != nil {
+		return 0, err
+	}
+
+	if r.conn == nil {
+		return 0, nil
+	}
+
+	r.prepareCache(ctx, strStmt)
+
+	var ra int64
+	err = r.inTx(ctx, true, func(tx *sql.Tx) error {
+		var err error
+		ra, err = r.execTx(ctx, tx, strStmt, args)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	r.invalidate(table)
+
+	return ra, nil
+}
+
+func (r *FootREST) Delete(ctx context.Context, table string, where string) (int64, error) {
+	strStmt, args, err := r.BuildDeleteStmt(table, where)
+	if err != nil {
+		return 0, err
+	}
+
+	if r.conn == nil {
+		return 0, nil
+	}
+
+	r.prepareCache(ctx, strStmt)
+
+	var ra int64
+	err = r.inTx(ctx, true, func(tx *sql.Tx) error {
+		var err error
+		ra, err = r.execTx(ctx, tx, strStmt, args)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	r.invalidate(table)
+
+	return ra, nil
+}
+
+// PostReturning is Post returning inserted rows by Dialect.Returning.
+func (r *FootREST) PostReturning(ctx context.Context, table string, values any) (records, error) {
+	if r.dialect.Returning == nil {
+		return records{}, errors.New("returning: not supported")
+	}
+
+	strStmts, argss, err := r.buildPostStmts(table, values, true, true)
 	if err != nil {
-		return recordSet{}, err
+		return records{}, err
+	}
+
+	rr := records{rows: [][]any{}}
+	if r.conn == nil {
+		return rr, nil
 	}
-	defer rows.Close()
 
-	colnames, err := rows.Columns()
+	err = r.inTx(ctx, true, func(tx *sql.Tx) error {
+		rr = records{rows: [][]any{}}
+		for i := range strStmts {
+			srr, err := r.queryTx(ctx, tx, strStmts[i], argss[i])
+			if err != nil {
+				return err
+			}
+			rr.columns = srr.columns
+			rr.rows = append(rr.rows, srr.rows...)
+		}
+		return nil
+	})
 	if err != nil {
-		return recordSet{}, err
+		return records{}, err
+	}
+
+	r.invalidate(table)
+
+	return rr, nil
+}
+
+// PutReturning is Put returning updated rows and the number of rows affected.
+//
+// Rows are read back by their primary keys, which may be updated by set.
+func (r *FootREST) PutReturning(ctx context.Context, table string, set map[string]any, where string) (records, int64, error) {
+	strStmt, args, err := r.BuildPutStmt(table, set, where)
+	if err != nil {
+		return records{}, 0, err
+	}
+
+	rr := records{rows: [][]any{}}
+	if r.conn == nil {
+		return rr, 0, nil
 	}
 
-	var dec *encoding.Decoder
-	if r.encoding != nil {
-		dec = r.encoding.NewDecoder()
+	keyColumns, err := r.primaryKey(ctx, table)
+	if err != nil {
+		return records{}, 0, err
+	}
+	keyStmt, keyArgs, err := r.BuildGetStmt(table, keyColumns, where, nil, 0, 0)
+	if err != nil {
+		return records{}, 0, err
 	}
 
-	rs := recordSet{}
-	for rows.Next() {
-		cols := make([]any, len(colnames))
-		colptrs := make([]any, len(cols))
-		for i := range cols {
-			colptrs[i] = &cols[i]
+	var ra int64
+	err = r.inTx(ctx, true, func(tx *sql.Tx) error {
+		krr, err := r.queryTx(ctx, tx, keyStmt, keyArgs)
+		if err != nil {
+			return err
 		}
 
-		err = rows.Scan(colptrs...)
+		ra, err = r.execTx(ctx, tx, strStmt, args)
 		if err != nil {
-			return recordSet{}, err
+			return err
 		}
 
-		if dec != nil {
-			for i := range cols {
-				if s, ok := cols[i].(string); ok {
-					s, err := dec.String(s)
-					if err != nil {
-						return recordSet{}, err
+		// keys after updated
+		for i, c := range keyColumns {
+			for k, v := range set {
+				if strings.EqualFold(k, c) {
+					for _, row := range krr.rows {
+						row[i] = v
 					}
-					cols[i] = s
 				}
 			}
 		}
 
-		m := make(map[string]any)
-		for i := range cols {
-			m[colnames[i]] = cols[i]
-		}
-		rs.Records = append(rs.Records, m)
+		rr, err = r.queryByKeys(ctx, tx, table, keyColumns, krr.rows)
+		return err
+	})
+	if err != nil {
+		return records{}, 0, err
 	}
 
-	return rs, nil
-}
+	r.invalidate(table)
 
-type bulkReqElem struct {
-	Method string            `json:"method"`
-	Table  string            `json:"table"`
-	Where  map[string]string `json:"where"`
-	Values map[string]any    `json:"values"`
+	return rr, ra, nil
 }
-type bulkReq []bulkReqElem
 
-type bulkGetReqElem struct {
-	Table  string            `json:"table"`
-	Where  map[string]string `json:"where"`
-	Select []string          `json:"select"`
-	Order  []string          `json:"order"`
-	Rows   uint              `json:"rows"`
-	Page   uint              `json:"page"`
-}
-type bulkGetReq []bulkGetReqElem
+// primaryKey returns columns of the primary key of table.
+func (r *FootREST) primaryKey(ctx context.Context, table string) ([]string, error) {
+	_, keys, err := r.getTables(ctx)
+	if err != nil {
+		return nil, err
+	}
 
-type recordSet struct {
-	Table   string           `json:"table"`
-	Records []map[string]any `json:"records"`
+	key, found := lookupFold(keys, table)
+	if !found || len(key) == 0 {
+		return nil, errors.Errorf("no primary key of %q", table)
+	}
+	return key, nil
 }
-type bulkRecordSet []recordSet
 
-func (r *FootREST) BulkGet(ctx context.Context, b bulkGetReq) (bulkRecordSet, error) {
-	if r.conn == nil {
-		return nil, nil
+// queryByKeys reads rows of table whose keyColumns are one of keys.
+func (r *FootREST) queryByKeys(ctx context.Context, q queryer, table string, keyColumns []string, keys [][]any) (records, error) {
+	perStmt := 500
+	if max := r.dialect.MaxParams / len(keyColumns); r.dialect.MaxParams > 0 && max < perStmt {
+		perStmt = max
 	}
 
-	bulkrs := make(bulkRecordSet, 0, len(b))
-
-	for _, m := range b {
-		where := ""
-		if len(m.Where) != 0 {
-			var extraWhere []string
-			for k, v := range m.Where {
-				var cond func(k, v string) string
-				for _, cc := range r.colConds {
-					if strings.HasPrefix(strings.ToUpper(v), strings.ToUpper(cc.name)) {
-						cond = cc.f
-						v = v[len(cc.name):]
-					}
-				}
-				if cond == nil {
-					cond = func(k, v string) string {
-						return fmt.Sprintf("(= .%v %v)", k, v)
-					}
-				}
-				extraWhere = append(extraWhere, cond(k, v))
-			}
-			if len(extraWhere) > 0 {
-				where = fmt.Sprintf("(AND %v %v)", where, strings.Join(extraWhere, ""))
-			}
-		}
-
-		selColumns := m.Select
-		if len(selColumns) == 0 {
-			selColumns = []string{"*"}
-		}
+	rr := records{rows: [][]any{}}
+	for len(keys) > 0 {
+		n := min(perStmt, len(keys))
+		chunk := keys[:n]
+		keys = keys[n:]
 
-		strStmt, args, err := r.BuildGetStmt(m.Table, selColumns, where, m.Order, m.Rows, m.Page)
+		strStmt, args, err := r.BuildGetStmtOpts(table, nil, "", nil, 0, 0, GetOptions{in: &inCond{columns: keyColumns, values: chunk}})
 		if err != nil {
-			return nil, err
+			return records{}, err
 		}
-		rog.Debug("SELECT(Bulk):")
-		rog.Debug("  stmt=", strStmt)
-		rog.Debug("  args=", args)
-
-		dbStmt, err := r.conn.PrepareContext(ctx, strStmt)
+		srr, err := r.query(ctx, q, strStmt, args)
 		if err != nil {
-			return nil, err
+			return records{}, err
 		}
-		defer dbStmt.Close()
+		rr.columns = srr.columns
+		rr.rows = append(rr.rows, srr.rows...)
+	}
+
+	return rr, nil
+}
 
-		rows, err := dbStmt.QueryContext(ctx, args...)
+// DeleteReturning is Delete returning deleted rows.
+func (r *FootREST) DeleteReturning(ctx context.Context, table string, where string) (records, error) {
+	strStmt, args, err := r.BuildDeleteStmt(table, where)
+	if err != nil {
+		return records{}, err
+	}
+	selStmt, selArgs, err := r.BuildGetStmt(table, nil, where, nil, 0, 0)
+	if err != nil {
+		return records{}, err
+	}
+
+	rr := records{rows: [][]any{}}
+	if r.conn == nil {
+		return rr, nil
+	}
+
+	err = r.inTx(ctx, true, func(tx *sql.Tx) error {
+		var err error
+		rr, err = r.queryTx(ctx, tx, selStmt, selArgs)
 		if err != nil {
-			return nil, err
+			return err
 		}
-		defer rows.Close()
+		_, err = r.execTx(ctx, tx, strStmt, args)
+		return err
+	})
+	if err != nil {
+		return records{}, err
+	}
+
+	r.invalidate(table)
 
-		colnames, err := rows.Columns()
+	return rr, nil
+}
+
+// requestContext returns a context with the logger of the request, the timeout for name,
+// the isolation level requested by HeaderIsolation
+// and the interactive transaction of HeaderTx.
+func (r *FootREST) requestContext(c echo.Context, name string) (context.Context, context.CancelFunc, error) {
+	ctx, cancel := r.config.ContextFor(name)
+	ctx = withLogger(ctx, r.logger(c.Request().Context()))
+
+	ctx, err := r.isolationContext(ctx, c.Request().Header.Get(HeaderIsolation))
+	if err != nil {
+		cancel()
+		return nil, nil, err
+	}
+
+	if id := c.Request().Header.Get(HeaderTx); id != "" {
+		otx, release, err := r.acquireTx(id)
 		if err != nil {
-			return nil, err
+			cancel()
+			return nil, nil, err
 		}
 
-		var dec *encoding.Decoder
-		if r.encoding != nil {
-			dec = r.encoding.NewDecoder()
+		ctxCancel := cancel
+		cancel = func() {
+			ctxCancel()
+			release()
 		}
+		ctx = withOpenTx(ctx, otx)
+	}
 
-		rs := recordSet{
-			Table: m.Table,
-		}
-		if m.Rows == 0 {
-			rs.Records = make([]map[string]any, 0, m.Rows)
-		} else {
-			rs.Records = make([]map[string]any, 0, 8)
-		}
-		cols := make([]any, len(colnames))
-		colptrs := make([]any, len(cols))
-		for i := range cols {
-			colptrs[i] = &cols[i]
-		}
-		for rows.Next() {
-			err = rows.Scan(colptrs...)
+	return ctx, cancel, nil
+}
+
+// encodeArgs returns a copy of args whose strings are encoded by r.encoding.
+//
+// Statements are built of args in UTF-8, and encoded once when executed.
+func (r *FootREST) encodeArgs(args []any) ([]any, error) {
+	if r.encoding == nil {
+		return args, nil
+	}
+
+	enc := r.encoding.NewEncoder()
+	encoded := make([]any, len(args))
+	for i := range args {
+		encoded[i] = args[i]
+		switch a := args[i].(type) {
+		case string:
+			s, err := enc.String(a)
 			if err != nil {
 				return nil, err
 			}
-
-			if dec != nil {
-				for i := range cols {
-					if s, ok := cols[i].(string); ok {
-						s, err := dec.String(s)
-						if err != nil {
-							return nil, err
-						}
-						cols[i] = s
-					}
+			encoded[i] = s
+		case sql.NamedArg:
+			if s, ok := a.Value.(string); ok {
+				s, err := enc.String(s)
+				if err != nil {
+					return nil, err
 				}
+				a.Value = s
+				encoded[i] = a
 			}
-
-			m := make(map[string]any)
-			for i := range cols {
-				m[colnames[i]] = cols[i]
-			}
-			rs.Records = append(rs.Records, m)
 		}
-
-		bulkrs = append(bulkrs, rs)
 	}
 
-	return bulkrs, nil
+	return encoded, nil
 }
 
-func (r *FootREST) Bulk(ctx context.Context, b bulkReq) (int64, error) {
-	if r.conn == nil {
-		return 0, nil
-	}
+func (r *FootREST) BuildGetStmt(table string, selColumns []string, whereSExpr string, orderColumns []string, rowsPerPage, page uint) (string, []any, error) {
+	return r.BuildGetStmtOpts(table, selColumns, whereSExpr, orderColumns, rowsPerPage, page, GetOptions{})
+}
 
-	tx, err := r.conn.BeginTx(ctx, nil)
-	if err != nil {
-		return 0, err
-	}
+// GetOptions are optional parts of a SELECT statement.
+type GetOptions struct {
+	// Count selects COUNT(*) AS COUNT instead of columns (or in addition to Group columns).
+	Count bool
 
-	ra := int64(0)
+	// Group is a list of GROUP BY columns.
+	// Selected columns must be in Group.
+	Group []string
 
-	for _, m := range b {
-		where := ""
-		if len(m.Where) != 0 {
-			var extraWhere []string
-			for k, v := range m.Where {
-				var cond func(k, v string) string
-				for _, cc := range r.colConds {
-					if strings.HasPrefix(strings.ToUpper(v), strings.ToUpper(cc.name)) {
-						cond = cc.f
-						v = v[len(cc.name):]
-					}
-				}
-				if cond == nil {
-					cond = func(k, v string) string {
-						return fmt.Sprintf("(= .%v %v)", k, v)
-					}
-				}
-				extraWhere = append(extraWhere, cond(k, v))
-			}
-			if len(extraWhere) > 0 {
-				where = fmt.Sprintf("(AND %v %v)", where, strings.Join(extraWhere, ""))
-			}
-		}
+	// After is a keyset cursor, values of orderColumns of the last row of the previous page.
+	// A page is the first rowsPerPage rows after the cursor.
+	// An empty (not nil) After is the first page.
+	After []any
 
-		var strStmt string
-		var args []any
+	// Embed is a list of related resources embedded into each row.
+	Embed []Embed
 
-		switch strings.ToUpper(m.Method) {
-		case "POST":
-			strStmt, args, err = r.BuildPostStmt(m.Table, m.Values)
-			if err != nil {
-				return 0, err
-			}
-			rog.Debug("POST(Bulk):")
-			rog.Debug("  stmt=", strStmt)
-			rog.Debug("  args=", args)
+	// Search is a text of full-text search by config Search of the table.
+	Search string
 
-		case "PUT":
-			strStmt, args, err = r.BuildPutStmt(m.Table, m.Values, where)
-			if err != nil {
-				return 0, err
-			}
-			rog.Debug("PUT(Bulk):")
-			rog.Debug("  stmt=", strStmt)
-			rog.Debug("  args=", args)
+	// Limit and Offset paginate by Dialect.LimitOffset instead of rowsPerPage and page.
+	Limit, Offset uint
 
-		case "UPSERT":
-			strStmt, args, err = r.BuildPutStmt(m.Table, m.Values, where)
-			if err != nil {
-				return 0, err
-			}
-			rog.Debug("PUT(Upsert; Bulk):")
-			rog.Debug("  stmt=", strStmt)
-			rog.Debug("  args=", args)
+	in *inCond // for embedding
 
-		case "DELETE":
-			strStmt, args, err = r.BuildDeleteStmt(m.Table, where)
-			if err != nil {
-				return 0, err
-			}
-			rog.Debug("DELETE(Bulk):")
-			rog.Debug("  stmt=", strStmt)
-			rog.Debug("  args=", args)
-		}
+	lock bool // locks rows by Dialect.LockRows
+}
 
-		var enc *encoding.Encoder
-		if r.encoding != nil {
-			enc = r.encoding.NewEncoder()
+func (r *FootREST) BuildGetStmtOpts(table string, selColumns []string, whereSExpr string, orderColumns []string, rowsPerPage, page uint, opts GetOptions) (string, []any, error) {
+	table = strings.TrimSpace(table)
+	whereSExpr = strings.TrimSpace(whereSExpr)
 
-			for i := range args {
-				if s, ok := args[i].(string); ok {
-					s, err = enc.String(s)
-					if err != nil {
-						return 0, err
-					}
-					args[i] = s
-				}
-			}
-		}
+	if err := r.validateTableName(table); err != nil {
+		return "", nil, err
+	}
 
-		dbStmt, err := tx.PrepareContext(ctx, strStmt)
+	var err error
+	var sc map[string]*sql.ColumnType
+	if r.useSchema {
+		sc, err = r.getSchema(table)
 		if err != nil {
-			_ = tx.Rollback()
-			return 0, err
+			return "", nil, errors.Wrap(err, "schema")
 		}
-		defer dbStmt.Close()
+	}
 
-		result, err := dbStmt.ExecContext(ctx, args...)
-		if err != nil {
-			_ = tx.Rollback()
-			return 0, err
-		}
+	// SELECT
 
-		rra, err := result.RowsAffected()
+	if len(selColumns) == 0 {
+		selColumns = append(selColumns, "*")
+	}
+	for _, c := range selColumns {
+		if _, _, ok := computedColumn(c); ok {
+			continue
+		}
+		err = r.validateColumnName(c, sc)
 		if err != nil {
-			return 0, err
+			return "", nil, errors.Wrap(err, "validate select")
 		}
-		ra += rra
-
-		if rra == 0 && strings.ToUpper(m.Method) == "UPSERT" {
-			strStmt, args, err = r.BuildPostStmt(m.Table, m.Values)
-			if err != nil {
-				_ = tx.Rollback()
-				return 0, err
-			}
-			rog.Debug("POST(Upsert; Bulk):")
-			rog.Debug("  stmt=", strStmt)
-			rog.Debug("  args=", args)
-
-			var enc *encoding.Encoder
-			if r.encoding != nil {
-				enc = r.encoding.NewEncoder()
-
-				for i := range args {
-					if s, ok := args[i].(string); ok {
-						s, err = enc.String(s)
-						if err != nil {
-							_ = tx.Rollback()
-							return 0, err
+	}
+	for _, c := range opts.Group {
+		err = r.validateColumnName(c, sc)
+		if err != nil || strings.TrimSpace(c) == "*" {
+			return "", nil, errors.Errorf("validate group: invalid column name %q", c)
+		}
+	}
+	if len(opts.Group) > 0 {
+		if len(selColumns) == 1 && strings.TrimSpace(selColumns[0]) == "*" {
+			selColumns = opts.Group
+		} else {
+			for _, c := range selColumns {
+				if _, expr, ok := computedColumn(c); ok {
+					// no aggregate functions, so only of group columns
+					node, err := parseSExpr(expr)
+					if err != nil {
+						return "", nil, errors.Wrap(err, "validate select")
+					}
+					for _, cc := range exprColumns(node) {
+						if !equalsToAnyOfUpper(cc, opts.Group...) {
+							return "", nil, errors.Errorf("validate select: column %q of %q is not in group", cc, c)
 						}
-						args[i] = s
 					}
+					continue
 				}
-			}
-
-			dbStmt, err := tx.PrepareContext(ctx, strStmt)
-			if err != nil {
-				_ = tx.Rollback()
-				return 0, err
-			}
-			defer dbStmt.Close()
-
-			result, err := dbStmt.ExecContext(ctx, args...)
-			if err != nil {
-				_ = tx.Rollback()
-				return 0, err
-			}
-
-			rra, err := result.RowsAffected()
-			if err != nil {
-				_ = tx.Rollback()
-				return 0, err
-			}
-			ra += rra
-		}
-	}
-
-	err = tx.Commit()
-	if err != nil {
-		_ = tx.Rollback()
-		return 0, err
-	}
-
-	return ra, nil
-}
-
-func (r *FootREST) Post(ctx context.Context, table string, values any) (int64, error) {
-	strStmt, args, err := r.BuildPostStmt(table, values)
-	if err != nil {
-		return 0, err
-	}
-
-	rog.Debug("POST:")
-	rog.Debug("  stmt=", strStmt)
-	rog.Debug("  args=", args)
-
-	var enc *encoding.Encoder
-	if r.encoding != nil {
-		enc = r.encoding.NewEncoder()
-
-		for i := range args {
-			if s, ok := args[i].(string); ok {
-				s, err = enc.String(s)
-				if err != nil {
-					return 0, err
+				if !equalsToAnyOfUpper(strings.TrimSpace(c), opts.Group...) {
+					return "", nil, errors.Errorf("validate select: column %q is not in group", c)
 				}
-				args[i] = s
 			}
 		}
 	}
-
-	if r.conn == nil {
-		return 0, nil
+	if opts.Count {
+		if len(opts.Group) == 0 {
+			selColumns = []string{"COUNT(*) AS COUNT"}
+		} else {
+			selColumns = append(append([]string{}, selColumns...), "COUNT(*) AS COUNT")
+		}
 	}
+	var args []any
+	var aliases []string
+	selExprs := make([]string, 0, len(selColumns))
+	for _, c := range selColumns {
+		alias, expr, ok := computedColumn(c)
+		if !ok {
+			selExprs = append(selExprs, c)
+			continue
+		}
+		if !r.isValidName(alias) {
+			return "", nil, errors.Errorf("validate select: invalid alias %q", alias)
+		}
 
-	tx, err := r.conn.BeginTx(ctx, nil)
-	if err != nil {
-		return 0, err
+		phnum := len(args)
+		w, a, err := r.buildExprString(expr, &phnum, sc)
+		if err != nil {
+			return "", nil, errors.Wrapf(err, "build select %q", alias)
+		}
+		selExprs = append(selExprs, w+" AS "+alias)
+		args = append(args, a...)
+		aliases = append(aliases, alias)
 	}
+	selectClause := "SELECT " + strings.Join(selExprs, ", ")
 
-	dbStmt, err := tx.PrepareContext(ctx, strStmt)
-	if err != nil {
-		return 0, err
-	}
-	defer dbStmt.Close()
+	// FROM
 
-	result, err := dbStmt.ExecContext(ctx, args...)
-	if err != nil {
-		_ = tx.Rollback()
-		return 0, err
-	}
+	fromClause := "FROM " + table
 
-	err = tx.Commit()
-	if err != nil {
-		return 0, err
+	lock := [2]string{}
+	if opts.lock && r.dialect.LockRows != nil {
+		lock = r.dialect.LockRows()
+		fromClause = strings.TrimSpace(fromClause + " " + lock[0])
 	}
 
-	return result.RowsAffected()
-}
+	// WHERE
 
-func (r *FootREST) Put(ctx context.Context, table string, set map[string]any, where string) (int64, error) {
-	strStmt, args, err := r.BuildPutStmt(table, set, where)
-	if err != nil {
-		return 0, err
+	var conds []string
+	if whereSExpr != "" {
+		w, wargs, err := r.buildWhereClauseAt(whereSExpr, len(args), sc)
+		if err != nil {
+			return "", nil, errors.Wrap(err, "build where")
+		}
+		conds = append(conds, w)
+		args = append(args, wargs...)
 	}
+	if opts.After != nil {
+		if opts.Count || len(opts.Group) > 0 {
+			return "", nil, errors.New("cursor can not be used with count or group")
+		}
+		if page != 0 {
+			return "", nil, errors.New("cursor can not be used with page")
+		}
+		if len(orderColumns) == 0 {
+			return "", nil, errors.New("cursor needs order columns")
+		}
+		if len(opts.After) != 0 && len(opts.After) != len(orderColumns) {
+			return "", nil, errors.Errorf("cursor has %d values for %d order columns", len(opts.After), len(orderColumns))
+		}
 
-	rog.Debug("PUT:")
-	rog.Debug("  stmt=", strStmt)
-	rog.Debug("  args=", args)
-
-	var enc *encoding.Encoder
-	if r.encoding != nil {
-		enc = r.encoding.NewEncoder()
-
-		for i := range args {
-			if s, ok := args[i].(string); ok {
-				s, err = enc.String(s)
-				if err != nil {
-					return 0, err
-				}
-				args[i] = s
+		if len(opts.After) != 0 {
+			err = r.validateOrderByColumns(orderColumns, sc)
+			if err != nil {
+				return "", nil, errors.Wrap(err, "validate order")
 			}
-		}
-	}
 
-	if r.conn == nil {
-		return 0, nil
-	}
+			var k string
+			k, args = r.buildKeysetCond(orderColumns, opts.After, args)
+			conds = append(conds, k)
+		}
 
-	tx, err := r.conn.BeginTx(ctx, nil)
-	if err != nil {
-		return 0, err
+		page = 1
 	}
-
-	dbStmt, err := tx.PrepareContext(ctx, strStmt)
-	if err != nil {
-		return 0, err
+	if opts.Search != "" {
+		var s string
+		s, args, err = r.buildSearchCond(table, opts.Search, args)
+		if err != nil {
+			return "", nil, errors.Wrap(err, "search")
+		}
+		conds = append(conds, s)
 	}
-	defer dbStmt.Close()
+	if opts.in != nil {
+		for _, c := range opts.in.columns {
+			err = r.validateColumnName(c, sc)
+			if err != nil {
+				return "", nil, errors.Wrap(err, "validate embed")
+			}
+		}
 
-	result, err := dbStmt.ExecContext(ctx, args...)
-	if err != nil {
-		_ = tx.Rollback()
-		return 0, err
+		var k string
+		k, args = r.buildInCond(opts.in.columns, opts.in.values, args)
+		conds = append(conds, k)
 	}
-
-	err = tx.Commit()
-	if err != nil {
-		return 0, err
+	whereClause := ""
+	if len(conds) == 1 {
+		whereClause = "WHERE " + conds[0]
+	} else if len(conds) > 1 {
+		whereClause = "WHERE (" + strings.Join(conds, ") AND (") + ")"
 	}
 
-	return result.RowsAffected()
-}
+	// GROUP BY
 
-func (r *FootREST) Delete(ctx context.Context, table string, where string) (int64, error) {
-	strStmt, args, err := r.BuildDeleteStmt(table, where)
-	if err != nil {
-		return 0, err
+	groupByClause := ""
+	if len(opts.Group) > 0 {
+		groupByClause = "GROUP BY " + strings.Join(opts.Group, ", ")
 	}
 
-	rog.Debug("DELETE:")
-	rog.Debug("  stmt=", strStmt)
-	rog.Debug("  args=", args)
+	// ORDER BY
+
+	orderByClause := ""
+	if len(orderColumns) != 0 && !(opts.Count && len(opts.Group) == 0) {
+		var plain []string
+		for _, o := range orderColumns {
+			o = strings.TrimPrefix(strings.TrimSpace(o), "-")
+			if !strings.HasPrefix(o, "(") && !equalsToAnyOfUpper(o, aliases...) {
+				plain = append(plain, o)
+			}
+		}
+		err = r.validateOrderByColumns(plain, sc)
+		if err != nil {
+			return "", nil, errors.Wrap(err, "validate order")
+		}
 
-	var enc *encoding.Encoder
-	if r.encoding != nil {
-		enc = r.encoding.NewEncoder()
+		orders := make([]string, 0, len(orderColumns))
+		for _, o := range orderColumns {
+			o = strings.TrimSpace(o)
+			desc := strings.HasPrefix(o, "-")
+			o = strings.TrimPrefix(o, "-")
 
-		for i := range args {
-			if s, ok := args[i].(string); ok {
-				s, err = enc.String(s)
+			if strings.HasPrefix(o, "(") {
+				phnum := len(args)
+				w, a, err := r.buildExprString(o, &phnum, sc)
 				if err != nil {
-					return 0, err
+					return "", nil, errors.Wrap(err, "build order")
 				}
-				args[i] = s
+				o = w
+				args = append(args, a...)
+			}
+			if desc {
+				o += " DESC"
 			}
+			orders = append(orders, o)
 		}
-	}
 
-	if r.conn == nil {
-		return 0, nil
+		orderByClause = "ORDER BY " + strings.Join(orders, ", ")
 	}
 
-	tx, err := r.conn.BeginTx(ctx, nil)
-	if err != nil {
-		return 0, err
+	pagination := [2]string{}
+	if !(opts.Count && len(opts.Group) == 0) {
+		if (opts.Limit != 0 || opts.Offset != 0) && r.dialect.LimitOffset != nil {
+			pagination = r.dialect.LimitOffset(opts.Limit, opts.Offset)
+		} else {
+			pagination = r.dialect.Paginate(rowsPerPage, page)
+		}
 	}
 
-	dbStmt, err := tx.PrepareContext(ctx, strStmt)
-	if err != nil {
-		return 0, err
-	}
-	defer dbStmt.Close()
+	return strings.TrimSpace(strings.Join(nonEmpty(pagination[0], selectClause, fromClause, whereClause, groupByClause, orderByClause, pagination[1], lock[1]), " ")), args, nil
+}
 
-	result, err := dbStmt.ExecContext(ctx, args...)
-	if err != nil {
-		_ = tx.Rollback()
-		return 0, err
-	}
+// buildKeysetCond builds (a > ?) OR ((a = ?) AND (b < ?)) for ORDER BY a, b DESC.
+func (r *FootREST) buildKeysetCond(orderColumns []string, after []any, args []any) (string, []any) {
+	var ors []string
+	for i := range orderColumns {
+		var ands []string
+		for j := 0; j <= i; j++ {
+			col := orderColumns[j]
+			op := "="
+			if j == i {
+				op = ">"
+				if strings.HasPrefix(col, "-") {
+					op = "<"
+				}
+			}
+			col = strings.TrimPrefix(col, "-")
 
-	err = tx.Commit()
-	if err != nil {
-		return 0, err
+			ands = append(ands, col+" "+op+" "+r.dialect.Placeholder(len(args)))
+			args = append(args, r.dialect.Arg(len(args), after[j]))
+		}
+		ors = append(ors, "("+strings.Join(ands, " AND ")+")")
 	}
 
-	return result.RowsAffected()
+	return strings.Join(ors, " OR "), args
 }
 
-func (r *FootREST) BuildGetStmt(table string, selColumns []string, whereSExpr string, orderColumns []string, rowsPerPage, page uint) (string, []any, error) {
-	table = strings.TrimSpace(table)
-	whereSExpr = strings.TrimSpace(whereSExpr)
-
-	if !r.isValidName(table) {
-		return "", nil, errors.Errorf("invalid table name %q", table)
-	}
-
-	var err error
-	var sc map[string]*sql.ColumnType
-	if r.useSchema {
-		sc, err = r.getSchema(table)
-		if err != nil {
-			return "", nil, errors.Wrap(err, "schema")
-		}
+// buildSearchCond builds a full-text search condition by Dialect.Search.
+func (r *FootREST) buildSearchCond(table, text string, args []any) (string, []any, error) {
+	if r.dialect.Search == nil {
+		return "", nil, errors.New("not supported")
 	}
 
-	// SELECT
-
-	if len(selColumns) == 0 {
-		selColumns = append(selColumns, "*")
+	conf, found := lookupFold(r.config.Search, table)
+	if !found {
+		return "", nil, errors.Errorf("not configured for %q", table)
 	}
-	for _, c := range selColumns {
-		err = r.validateColumnName(c, sc)
-		if err != nil {
-			return "", nil, errors.Wrap(err, "validate select")
+	for _, c := range conf.Columns {
+		if !r.isValidName(c) {
+			return "", nil, errors.Errorf("invalid column name %q", c)
 		}
 	}
-	selectClause := "SELECT " + strings.Join(selColumns, ", ")
-
-	// FROM
-
-	fromClause := "FROM " + table
+	if (conf.Index != "" && !r.isValidName(conf.Index)) || (conf.Language != "" && !r.isValidName(conf.Language)) {
+		return "", nil, errors.Errorf("invalid config of %q", table)
+	}
 
-	// WHERE
+	cond := r.dialect.Search(table, conf, func() string {
+		ph := r.dialect.Placeholder(len(args))
+		args = append(args, r.dialect.Arg(len(args), text))
+		return ph
+	})
+	return cond, args, nil
+}
 
-	whereClause := ""
-	var args []any
-	if whereSExpr != "" {
-		var w string
-		w, args, err = r.buildWhereClause(whereSExpr, sc)
-		if err != nil {
-			return "", nil, errors.Wrap(err, "build where")
+// buildInCond builds a IN (?, ?) for a column, or (a = ? AND b = ?) OR (...) for columns.
+func (r *FootREST) buildInCond(columns []string, values [][]any, args []any) (string, []any) {
+	if len(columns) == 1 {
+		phs := make([]string, 0, len(values))
+		for _, v := range values {
+			phs = append(phs, r.dialect.Placeholder(len(args)))
+			args = append(args, r.dialect.Arg(len(args), v[0]))
 		}
-		whereClause = "WHERE " + w
+		return columns[0] + " IN (" + strings.Join(phs, ", ") + ")", args
 	}
 
-	// ORDER BY
-
-	orderByClause := ""
-	if len(orderColumns) != 0 {
-		err = r.validateOrderByColumns(orderColumns, sc)
-		if err != nil {
-			return "", nil, errors.Wrap(err, "validate order")
+	ors := make([]string, 0, len(values))
+	for _, v := range values {
+		ands := make([]string, 0, len(columns))
+		for i, c := range columns {
+			ands = append(ands, c+" = "+r.dialect.Placeholder(len(args)))
+			args = append(args, r.dialect.Arg(len(args), v[i]))
 		}
+		ors = append(ors, "("+strings.Join(ands, " AND ")+")")
+	}
+	return strings.Join(ors, " OR "), args
+}
 
-		for i, o := range orderColumns {
-			if strings.HasPrefix(o, "-") {
-				o = o[1:] + " DESC"
-			}
-			orderColumns[i] = o
+func nonEmpty(ss ...string) []string {
+	result := make([]string, 0, len(ss))
+	for _, s := range ss {
+		if s != "" {
+			result = append(result, s)
 		}
+	}
+	return result
+}
 
-		orderByClause = "ORDER BY " + strings.Join(orderColumns, ", ")
+// BuildPostStmt builds one INSERT statement of all values.
+//
+// values is map[string]any or []map[string]any.
+func (r *FootREST) BuildPostStmt(table string, values any) (string, []any, error) {
+	strStmts, argss, err := r.buildPostStmts(table, values, false, false)
+	if err != nil {
+		return "", nil, err
 	}
 
-	pagination := r.dialect.Paginate(rowsPerPage, page)
+	return strStmts[0], argss[0], nil
+}
 
-	return strings.TrimSpace(strings.Join([]string{pagination[0], selectClause, fromClause, whereClause, orderByClause, pagination[1]}, " ")), args, nil
+// BuildPostStmts builds INSERT statements of values,
+// split by Dialect.MaxParams and Dialect.MaxInsertRows.
+func (r *FootREST) BuildPostStmts(table string, values any) ([]string, [][]any, error) {
+	return r.buildPostStmts(table, values, true, false)
 }
 
-func (r *FootREST) BuildPostStmt(table string, values any) (string, []any, error) {
+// buildPostStmts builds INSERT statements.
+// If returning, the statements return inserted rows by Dialect.Returning.
+func (r *FootREST) buildPostStmts(table string, values any, split, returning bool) ([]string, [][]any, error) {
 	table = strings.TrimSpace(table)
 
-	if !r.isValidName(table) {
-		return "", nil, errors.Errorf("invalid table name %q", table)
+	if err := r.validateTableName(table); err != nil {
+		return nil, nil, err
 	}
 
 	var err error
@@ -1071,7 +2165,7 @@ func (r *FootREST) BuildPostStmt(table string, values any) (string, []any, error
 	if r.useSchema {
 		sc, err = r.getSchema(table)
 		if err != nil {
-			return "", nil, errors.Wrap(err, "schema")
+			return nil, nil, errors.Wrap(err, "schema")
 		}
 	}
 
@@ -1083,6 +2177,9 @@ func (r *FootREST) BuildPostStmt(table string, values any) (string, []any, error
 	} else {
 		panic(fmt.Sprintf("unsupported type %T of values", values))
 	}
+	if len(svalues) == 0 {
+		return nil, nil, errors.New("no values")
+	}
 
 	// normalize svalues
 
@@ -1108,58 +2205,81 @@ func (r *FootREST) BuildPostStmt(table string, values any) (string, []any, error
 		}
 	}
 
-	// INSERT INTO
-
-	buf := bytes.NewBufferString("INSERT INTO ")
-	buf.WriteString(table)
-	buf.WriteString(" (")
-
-	for i, c := range allColumns {
+	for _, c := range allColumns {
+		if !r.isValidName(c) {
+			return nil, nil, errors.Errorf("invalid column name %q", c)
+		}
 		if sc != nil {
 			_, ok := sc[strings.ToUpper(c)]
 			if !ok {
-				return "", nil, errors.Errorf("column %q is not in %q scheme", c, table)
+				return nil, nil, errors.Errorf("column %q is not in %q scheme", c, table)
 			}
 		}
+	}
 
-		if i > 0 {
-			buf.WriteString(", ")
+	// rows per statement
+
+	chunk := len(svalues)
+	if split {
+		if r.dialect.MultiRowInsert == nil {
+			chunk = 1
+		}
+		if max := r.dialect.MaxInsertRows; max > 0 && chunk > max {
+			chunk = max
+		}
+		if max := r.dialect.MaxParams; max > 0 && len(allColumns) > 0 && chunk*len(allColumns) > max {
+			chunk = max / len(allColumns)
+			if chunk == 0 {
+				return nil, nil, errors.Errorf("%d columns exceed %d parameters", len(allColumns), max)
+			}
 		}
-		buf.WriteString(c)
 	}
-	buf.WriteString(") VALUES ")
 
-	var args []any
-	ph := 0
+	var strStmts []string
+	var argss [][]any
 
-	for si := range svalues {
-		if si > 0 {
-			buf.WriteString(", ")
+	for start := 0; start < len(svalues); start += chunk {
+		end := start + chunk
+		if end > len(svalues) {
+			end = len(svalues)
 		}
-		buf.WriteByte('(')
 
-		for i, c := range allColumns {
-			if i > 0 {
-				buf.WriteString(", ")
+		var args []any
+		ph := 0
+
+		rows := make([][]string, 0, end-start)
+		for si := start; si < end; si++ {
+			row := make([]string, 0, len(allColumns))
+			for _, c := range allColumns {
+				row = append(row, r.dialect.Placeholder(ph))
+				args = append(args, r.dialect.Arg(ph, svalues[si][c]))
+				ph++
 			}
+			rows = append(rows, row)
+		}
 
-			buf.WriteString(r.dialect.Placeholder(ph))
-			ph++
-			args = append(args, svalues[si][c])
+		var strStmt string
+		if returning && r.dialect.Returning != nil {
+			strStmt = insertValues(table, allColumns, rows, r.dialect.Returning())
+		} else if len(rows) > 1 && r.dialect.MultiRowInsert != nil {
+			strStmt = r.dialect.MultiRowInsert(table, allColumns, rows)
+		} else {
+			strStmt = DefaultMultiRowInsert(table, allColumns, rows)
 		}
 
-		buf.WriteByte(')')
+		strStmts = append(strStmts, strStmt)
+		argss = append(argss, args)
 	}
 
-	return buf.String(), args, nil
+	return strStmts, argss, nil
 }
 
 func (r *FootREST) BuildDeleteStmt(table string, whereSExpr string) (string, []any, error) {
 	table = strings.TrimSpace(table)
 	whereSExpr = strings.TrimSpace(whereSExpr)
 
-	if !r.isValidName(table) {
-		return "", nil, errors.Errorf("invalid table name %q", table)
+	if err := r.validateTableName(table); err != nil {
+		return "", nil, err
 	}
 
 	var err error
@@ -1197,8 +2317,8 @@ func (r *FootREST) BuildPutStmt(table string, values map[string]any, whereSExpr
 	table = strings.TrimSpace(table)
 	whereSExpr = strings.TrimSpace(whereSExpr)
 
-	if !r.isValidName(table) {
-		return "", nil, errors.Errorf("invalid table name %q", table)
+	if err := r.validateTableName(table); err != nil {
+		return "", nil, err
 	}
 
 	var err error
@@ -1210,8 +2330,13 @@ func (r *FootREST) BuildPutStmt(table string, values map[string]any, whereSExpr
 		}
 	}
 
+	version := r.versionColumn(table)
+
 	allColumns := make([]string, 0, len(values))
 	for c := range values {
+		if version != "" && strings.EqualFold(c, version) {
+			continue // incremented below
+		}
 		allColumns = append(allColumns, c)
 	}
 	sort.Slice(allColumns, func(i, j int) bool {
@@ -1249,6 +2374,12 @@ func (r *FootREST) BuildPutStmt(table string, values map[string]any, whereSExpr
 		ph++
 		args = append(args, v)
 	}
+	if version != "" {
+		if len(allColumns) > 0 {
+			buf.WriteString(", ")
+		}
+		buf.WriteString(version + " = COALESCE(" + version + ", 0) + 1")
+	}
 
 	// WHERE
 
@@ -1268,6 +2399,65 @@ func (r *FootREST) BuildPutStmt(table string, values map[string]any, whereSExpr
 }
 
 func (r *FootREST) buildWhereClause(w string, sc map[string]*sql.ColumnType) (whereClause string, args []any, err error) {
+	return r.buildWhereClauseAt(w, 0, sc)
+}
+
+// buildWhereClauseAt is buildWhereClause whose placeholders start at phnum.
+func (r *FootREST) buildWhereClauseAt(w string, phnum int, sc map[string]*sql.ColumnType) (whereClause string, args []any, err error) {
+	node, err := parseSExpr(w)
+	if err != nil {
+		return "", nil, err
+	}
+	return r.buildWhereClauseInner(node, &phnum, sc)
+}
+
+// quoteMark stands for a doubled quote in a string literal while parsed.
+const quoteMark = "\x00"
+
+// escapeQuotes replaces doubled quotes in string literals of w by quoteMark.
+func escapeQuotes(w string) (string, error) {
+	if strings.Contains(w, quoteMark) {
+		return "", errors.New("invalid character NUL")
+	}
+	if !strings.Contains(w, "''") {
+		return w, nil
+	}
+
+	buf := strings.Builder{}
+	quoted := false
+	for i := 0; i < len(w); i++ {
+		if w[i] != '\'' {
+			buf.WriteByte(w[i])
+			continue
+		}
+		if quoted && i+1 < len(w) && w[i+1] == '\'' {
+			buf.WriteString(quoteMark)
+			i++
+			continue
+		}
+		quoted = !quoted
+		buf.WriteByte(w[i])
+	}
+	return buf.String(), nil
+}
+
+// unescapeQuotes replaces quoteMark in string literals of node by '.
+func unescapeQuotes(node *sexpr.Node) {
+	if node.Type == sexpr.TokString {
+		node.Data = bytes.ReplaceAll(node.Data, []byte(quoteMark), []byte("'"))
+	}
+	for _, c := range node.Children {
+		unescapeQuotes(c)
+	}
+}
+
+// parseSExpr parses w, whose string literal may have a quote escaped by doubling it.
+func parseSExpr(w string) (*sexpr.Node, error) {
+	w, err := escapeQuotes(w)
+	if err != nil {
+		return nil, err
+	}
+
 	syntax := &sexpr.Syntax{
 		// A set of list delimiters. These are pairs of strings denoting the
 		// start and end of an S-expression.
@@ -1314,121 +2504,195 @@ func (r *FootREST) buildWhereClause(w string, sc map[string]*sql.ColumnType) (wh
 	var ast sexpr.AST
 	err = sexpr.ParseString(&ast, w, syntax)
 	if err != nil {
-		return "", nil, err
+		return nil, err
 	}
 	if len(ast.Root.Children) == 0 {
-		return "", nil, errors.New("no children")
+		return nil, errors.New("no children")
 	}
+	unescapeQuotes(&ast.Root)
 
 	//rog.Debug(ast.String())
 
 	//defer ast.ReleaseNodes()
 
-	phnum := 0
-	return r.buildWhereClauseInner(ast.Root.Children[0], &phnum, sc)
+	return ast.Root.Children[0], nil
 }
 
 func (r *FootREST) buildWhereClauseInner(node *sexpr.Node, phnum *int, sc map[string]*sql.ColumnType) (string, []any, error) {
+	w, args, _, err := r.buildExpr(node, phnum, sc)
+	return w, args, err
+}
+
+// buildExpr builds an operator or a function expression and returns the kind of its result.
+func (r *FootREST) buildExpr(node *sexpr.Node, phnum *int, sc map[string]*sql.ColumnType) (string, []any, string, error) {
 	if phnum == nil {
 		panic("phnum is nil")
 	}
 
 	if len(node.Children) == 0 {
-		return "", nil, errors.New("invalid expr")
+		return "", nil, "", errors.New("invalid expr")
 	}
 
 	car := node.Children[0]
 	cdr := node.Children[1:]
 
 	if car.Type != sexpr.TokIdent {
-		return "", nil, errors.Errorf("%q is not an operator", car.Data)
+		return "", nil, "", errors.Errorf("%q is not an operator", car.Data)
 	}
 
 	operatorName := strings.ToUpper(string(car.Data))
 	operator, found := r.dialect.Operators[operatorName]
 	if !found {
-		return "", nil, errors.Errorf("operator %q is not registered", operatorName)
+		if f, found := r.dialect.Functions[operatorName]; found {
+			return r.buildFunction(f, cdr, phnum, sc)
+		}
+		return "", nil, "", errors.Errorf("operator %q is not registered", operatorName)
 	}
 	if operator.Format == "" {
 		operator.Format = strings.ReplaceAll(DefaultOperatorFormat, "{OPERATOR}", operatorName)
 	}
 
-	var enc *encoding.Encoder
-	if r.encoding != nil {
-		enc = r.encoding.NewEncoder()
-	}
-
 	args := []any{}
 	subww := []string{}
-	for i, c := range cdr {
-		data := string(c.Data)
+	for i := range cdr {
+		subw, suba, kind, err := r.buildOperand(cdr, i, phnum, sc)
+		if err != nil {
+			return "", nil, "", err
+		}
+		if !kindMatches(operator.Operand, kind) {
+			return "", nil, "", errors.Errorf("operator %q: %s is expected, got %s", operatorName, operator.Operand, kind)
+		}
+		subww = append(subww, subw)
+		args = append(args, suba...)
+	}
+	w, err := operator.ApplyFormat(subww...)
+	if err != nil {
+		return "", nil, "", err
+	}
 
-		if c.Type == sexpr.TokListOpen {
-			subw, suba, err := r.buildWhereClauseInner(c, phnum, sc)
-			if err != nil {
-				return "", nil, err
+	return w, args, operator.Result, nil
+}
+
+// buildOperand builds cdr[i] of an expression and returns the kind of it.
+func (r *FootREST) buildOperand(cdr []*sexpr.Node, i int, phnum *int, sc map[string]*sql.ColumnType) (string, []any, string, error) {
+	c := cdr[i]
+	data := string(c.Data)
+
+	if c.Type == sexpr.TokListOpen && isSubselect(c) {
+		subw, suba, err := r.buildSubselect(c, phnum)
+		if err != nil {
+			return "", nil, "", err
+		}
+		return subw, suba, "", nil
+
+	} else if c.Type == sexpr.TokListOpen {
+		return r.buildExpr(c, phnum, sc)
+
+	} else if strings.HasPrefix(data, ".") {
+		data = data[1:]
+		if !r.isValidName(data) {
+			return "", nil, "", errors.Errorf("invalid column name %q", data)
+		}
+		kind := ""
+		if sc != nil {
+			data := strings.ToUpper(data)
+			typ, ok := sc[data]
+			if !ok {
+				return "", nil, "", errors.Errorf("invalid column name %q", data)
 			}
-			subww = append(subww, subw)
-			args = append(args, suba...)
+			kind = exprKind(typ)
+		}
+		return data, nil, kind, nil
+	}
+
+	ph := r.dialect.Placeholder(*phnum)
+	num := *phnum
+	*phnum++
+
+	if c.Type == sexpr.TokString {
+		return ph, []any{r.dialect.Arg(num, data)}, exprText, nil
+	}
 
-		} else if strings.HasPrefix(data, ".") {
-			data = data[1:]
-			if !r.isValidName(data) {
-				return "", nil, errors.Errorf("invalid column name %q", data)
+	var typ *sql.ColumnType
+	if sc != nil {
+		for ii, cc := range cdr {
+			if ii == i {
+				continue
 			}
-			if sc != nil {
-				data := strings.ToUpper(data)
-				if _, ok := sc[data]; !ok {
-					return "", nil, errors.Errorf("invalid column name %q", data)
-				}
+			ccname := string(cc.Data)
+			if !strings.HasPrefix(ccname, ".") {
+				continue
 			}
-			subww = append(subww, data)
+			ccname = ccname[1:]
+			if t, ok := sc[ccname]; ok {
+				typ = t
+				break
+			}
+		}
+	}
+	value, err := conv(data, typ)
+	if err != nil {
+		return "", nil, "", err
+	}
+	kind := literalKind(value)
+	if c.Type == sexpr.TokNumber {
+		kind = exprNumber
+	}
+	return ph, []any{r.dialect.Arg(num, value)}, kind, nil
+}
 
-		} else {
-			subww = append(subww, r.dialect.Placeholder(*phnum))
-			*phnum++
+func isSubselect(node *sexpr.Node) bool {
+	return len(node.Children) > 0 && node.Children[0].Type == sexpr.TokIdent && strings.EqualFold(string(node.Children[0].Data), "SELECT")
+}
 
-			if c.Type == sexpr.TokString {
-				if enc != nil {
-					s, err := enc.String(data)
-					if err != nil {
-						return "", nil, err
-					}
-					data = s
-				}
-				args = append(args, r.dialect.Arg(len(args), data))
-			} else {
-				var typ *sql.ColumnType
-				if sc != nil {
-					for ii, cc := range cdr {
-						if ii == i {
-							continue
-						}
-						ccname := string(cc.Data)
-						if !strings.HasPrefix(ccname, ".") {
-							continue
-						}
-						ccname = ccname[1:]
-						if t, ok := sc[ccname]; ok {
-							typ = t
-							break
-						}
-					}
-				}
-				value, err := conv(data, typ)
-				if err != nil {
-					return "", nil, err
-				}
-				args = append(args, r.dialect.Arg(len(args), value))
-			}
+// buildSubselect builds (SELECT col FROM table WHERE ...) of (select table .col (where ...)).
+func (r *FootREST) buildSubselect(node *sexpr.Node, phnum *int) (string, []any, error) {
+	cdr := node.Children[1:]
+	if len(cdr) < 2 || len(cdr) > 3 {
+		return "", nil, errors.New("select: (select table .column (where ...)) is expected")
+	}
+
+	table := string(cdr[0].Data)
+	if cdr[0].Type != sexpr.TokIdent {
+		return "", nil, errors.Errorf("select: invalid table name %q", table)
+	}
+	if err := r.validateTableName(table); err != nil {
+		return "", nil, errors.Wrap(err, "select")
+	}
+
+	var sc map[string]*sql.ColumnType
+	if r.useSchema {
+		var err error
+		sc, err = r.getSchema(table)
+		if err != nil {
+			return "", nil, errors.Wrap(err, "schema")
 		}
 	}
-	w, err := operator.ApplyFormat(subww...)
+
+	col := string(cdr[1].Data)
+	if !strings.HasPrefix(col, ".") {
+		return "", nil, errors.Errorf("select: %q is not a column", col)
+	}
+	col = col[1:]
+	if err := r.validateColumnName(col, sc); err != nil || col == "*" {
+		return "", nil, errors.Errorf("select: invalid column name %q", col)
+	}
+
+	stmt := "SELECT " + col + " FROM " + table
+	if len(cdr) == 2 {
+		return "(" + stmt + ")", nil, nil
+	}
+
+	w := cdr[2]
+	if w.Type != sexpr.TokListOpen || len(w.Children) != 2 || !strings.EqualFold(string(w.Children[0].Data), "WHERE") {
+		return "", nil, errors.New("select: (where ...) is expected")
+	}
+	ww, args, err := r.buildWhereClauseInner(w.Children[1], phnum, sc)
 	if err != nil {
 		return "", nil, err
 	}
 
-	return w, args, nil
+	return "(" + stmt + " WHERE " + ww + ")", args, nil
 }
 
 func (r *FootREST) getSchema(table string) (map[string]*sql.ColumnType, error) {
@@ -1441,9 +2705,11 @@ func (r *FootREST) getSchema(table string) (map[string]*sql.ColumnType, error) {
 	r.scMut.Lock()
 	if sc, found := r.schemaCache[table]; found {
 		r.scMut.Unlock()
+		r.metrics.schemaCache(true)
 		return sc, nil
 	}
 	r.scMut.Unlock()
+	r.metrics.schemaCache(false)
 
 	stmt, err := r.conn.Prepare("SELECT * FROM " + table + " WHERE 1=0 ")
 	if err != nil {
@@ -1477,10 +2743,27 @@ func (r *FootREST) getSchema(table string) (map[string]*sql.ColumnType, error) {
 	return scMap, nil
 }
 
+// loadSchemas loads schemas of tables before a transaction,
+// since getSchema inside it may wait for the connection the transaction holds.
+func (r *FootREST) loadSchemas(tables ...string) error {
+	if !r.useSchema {
+		return nil
+	}
+	for _, t := range tables {
+		if _, err := r.getSchema(t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func conv(s string, typ *sql.ColumnType) (any, error) {
 
 	if strings.HasPrefix(s, "-") {
-		return strconv.Atoi(s)
+		if i, err := strconv.Atoi(s); err == nil {
+			return i, nil
+		}
+		return strconv.ParseFloat(s, 64)
 	}
 	if strings.HasPrefix(s, "#") {
 		return strconv.Atoi(s[1:])
@@ -1539,6 +2822,17 @@ func conv(s string, typ *sql.ColumnType) (any, error) {
 //	},
 //}}
 
+// validateTableName validates table is a valid name and exposed by config Tables.
+func (r *FootREST) validateTableName(table string) error {
+	if !r.isValidName(table) {
+		return errors.Errorf("invalid table name %q", table)
+	}
+	if len(r.config.Tables) > 0 && !equalsToAnyOfUpper(table, r.config.Tables...) {
+		return errors.Errorf("table %q is not exposed", table)
+	}
+	return nil
+}
+
 func (r *FootREST) isValidName(name string) bool {
 	if f := r.dialect.IsValidName; f != nil {
 		return f(name)
@@ -1612,16 +2906,50 @@ func equalsToAnyOfUpper(s1 string, ss ...string) bool {
 	return false
 }
 
+// wantsRepresentation reports whether a request has a header Prefer: return=representation.
+func wantsRepresentation(c echo.Context) bool {
+	for _, p := range strings.Split(c.Request().Header.Get(HeaderPrefer), ",") {
+		if strings.EqualFold(strings.TrimSpace(p), "return=representation") {
+			return true
+		}
+	}
+	return false
+}
+
+func representationResponse(c echo.Context, config Config, rr records) error {
+	data, err := json.Marshal(rr)
+	if err != nil {
+		return errorResponse(c, config, err)
+	}
+	return c.String(http.StatusOK, strings.ReplaceAll(config.Format.QueryOK, "%", string(data)))
+}
+
 func errorResponse(c echo.Context, config Config, err error) error {
-	_ = c.String(http.StatusBadRequest, strings.ReplaceAll(config.Format.Error, "%", `"`+escape(err.Error())+`"`))
+	var berr BulkError
+	if errors.As(err, &berr) {
+		data, jerr := json.Marshal(berr)
+		if jerr == nil {
+			_ = c.String(http.StatusBadRequest, strings.ReplaceAll(config.Format.Error, "%", string(data)))
+			return err
+		}
+	}
+
+	status := http.StatusBadRequest
+	if errors.Is(err, ErrPreconditionFailed) {
+		status = http.StatusPreconditionFailed
+	}
+
+	_ = c.String(status, strings.ReplaceAll(config.Format.Error, "%", `"`+escape(err.Error())+`"`))
 	return err
 }
 
-func stacktraceMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+func (r *FootREST) stacktraceMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
 	return func(c echo.Context) error {
 		var err error
 		if err = next(c); err != nil {
-			log.Printf("%v\n", stacktrace.New(err))
+			r.logger(c.Request().Context()).Error("error",
+				slog.String("error", err.Error()),
+				slog.String("stacktrace", fmt.Sprintf("%v", stacktrace.New(err))))
 			c.Error(err)
 		}
 		return err
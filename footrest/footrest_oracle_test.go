@@ -28,4 +28,39 @@ func TestOracleDialect(t *testing.T) {
 	gotwant.TestError(t, err, nil)
 	gotwant.Test(t, w, `SELECT * FROM users WHERE name LIKE :0 || :1`)
 	gotwant.Test(t, args, []interface{}{"Mr.", "%"})
+
+	// units of PostgreSQL are mapped to format models
+	w, args, err = r.BuildGetStmt("users", nil, `(= (DATE_TRUNC 'day' .CREATED) .UPDATED)`, nil, 0, 0)
+	gotwant.TestError(t, err, nil)
+	gotwant.Test(t, w, `SELECT * FROM users WHERE TRUNC(CREATED, DECODE(LOWER(:0), 'minute', 'MI', 'hour', 'HH24', 'day', 'DD', 'week', 'IW', 'month', 'MM', 'quarter', 'Q', 'year', 'YYYY')) = UPDATED`)
+	gotwant.Test(t, args, []interface{}{"day"})
+}
+
+func TestOracleInsertAll(t *testing.T) {
+	r := footrest.New(nil, "oracle", nil, false, nil)
+	stmts, argss, err := r.BuildPostStmts("users", []map[string]any{
+		{"ID": 1, "NAME": "a"},
+		{"ID": 2, "NAME": "b"},
+	})
+	gotwant.TestError(t, err, nil)
+	gotwant.Test(t, stmts, []string{
+		`INSERT ALL INTO users (ID, NAME) VALUES (:0, :1) INTO users (ID, NAME) VALUES (:2, :3) SELECT 1 FROM DUAL`,
+	})
+	gotwant.Test(t, argss, [][]any{{1, "a", 2, "b"}})
+}
+
+func TestOracleCall(t *testing.T) {
+	config := footrest.DefaultConfig()
+	config.Procedures = []string{"pkg.my_func"}
+	r := footrest.New(nil, "oracle", nil, false, config)
+
+	stmt, args, outs, err := r.BuildCallStmt("pkg.my_func", []footrest.SPParam{
+		{Name: "a", Value: "x"},
+		{Direction: "return", Type: "number"},
+	})
+	gotwant.TestError(t, err, nil)
+	gotwant.Test(t, stmt, `BEGIN :0 := pkg.my_func(:1); END;`)
+	gotwant.Test(t, len(args), 2)
+	gotwant.Test(t, args[1], "x")
+	gotwant.Test(t, outs[1] != nil, true)
 }
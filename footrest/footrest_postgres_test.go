@@ -0,0 +1,25 @@
+package footrest_test
+
+import (
+	"testing"
+
+	"github.com/shu-go/gotwant"
+
+	"github.com/shu-go/footrest/footrest"
+
+	_ "github.com/shu-go/footrest/footrest/dialect/postgres"
+)
+
+func TestPostgresDialect(t *testing.T) {
+	r := footrest.New(nil, "postgres", nil, false, nil)
+
+	// $n of formats are not confused with placeholders
+	w, args, err := r.BuildGetStmt("users", nil, `(AND (= .ID #1) (ILIKE 'a%' .NAME) (ICONTAINS .NOTE 'b') (STARTSWITH (|| .NAME 'x') 'c'))`, nil, 0, 0)
+	gotwant.TestError(t, err, nil)
+	gotwant.Test(t, w, `SELECT * FROM users WHERE (ID = $1) AND ($2 ILIKE NAME) AND (NOTE ILIKE '%' || REPLACE(REPLACE(REPLACE($3, '\', '\\'), '%', '\%'), '_', '\_') || '%' ESCAPE '\') AND (NAME || $4 LIKE REPLACE(REPLACE(REPLACE($5, '\', '\\'), '%', '\%'), '_', '\_') || '%' ESCAPE '\')`)
+	gotwant.Test(t, args, []interface{}{1, "a%", "b", "x", "c"})
+
+	w, _, err = r.BuildGetStmt("users", nil, `(= (SUBSTR .NAME #1 #2) 'ab')`, nil, 0, 0)
+	gotwant.TestError(t, err, nil)
+	gotwant.Test(t, w, `SELECT * FROM users WHERE SUBSTR(NAME, $1, $2) = $3`)
+}
@@ -1,8 +1,21 @@
 package footrest_test
 
 import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
+	"golang.org/x/text/encoding/japanese"
 	_ "modernc.org/sqlite"
 
 	"github.com/shu-go/gotwant"
@@ -30,3 +43,1480 @@ func TestSQLiteDialect(t *testing.T) {
 	gotwant.Test(t, w, `SELECT * FROM users WHERE name LIKE ? || ?`)
 	gotwant.Test(t, args, []interface{}{"Mr.", "%"})
 }
+
+func TestSQLiteRecordOrder(t *testing.T) {
+	conn, err := sql.Open("sqlite", ":memory:")
+	gotwant.TestError(t, err, nil)
+	defer conn.Close()
+	conn.SetMaxOpenConns(1)
+
+	_, err = conn.Exec(`CREATE TABLE t1 (zz INTEGER, aa TEXT, bb BLOB, cc DECIMAL(10,2))`)
+	gotwant.TestError(t, err, nil)
+	_, err = conn.Exec(`INSERT INTO t1 VALUES (1, 'one', x'0102', '1.50'), (2, 'two', NULL, '2.00')`)
+	gotwant.TestError(t, err, nil)
+
+	config := footrest.DefaultConfig()
+	r := footrest.New(conn, "sqlite", nil, true, config)
+	rs, err := r.Get(context.Background(), "t1", nil, "", footrest.Columns("zz"), 0, 0)
+	gotwant.TestError(t, err, nil)
+	data, err := json.Marshal(rs)
+	gotwant.TestError(t, err, nil)
+	gotwant.Test(t, string(data), `{"table":"t1","records":[{"zz":1,"aa":"one","bb":"AQI=","cc":"1.5"},{"zz":2,"aa":"two","bb":null,"cc":"2"}]}`)
+
+	config.Output.Decimal = "number"
+	r = footrest.New(conn, "sqlite", nil, true, config)
+	rs, err = r.Get(context.Background(), "t1", footrest.Columns("cc"), "", footrest.Columns("zz"), 0, 0)
+	gotwant.TestError(t, err, nil)
+	data, err = json.Marshal(rs)
+	gotwant.TestError(t, err, nil)
+	gotwant.Test(t, string(data), `{"table":"t1","records":[{"cc":1.5},{"cc":2}]}`)
+
+	// not a finite JSON number
+	_, err = conn.Exec(`INSERT INTO t1 VALUES (3, 'three', NULL, 'NaN'), (4, 'four', NULL, '-Infinity'), (5, 'five', NULL, '0x1p-2')`)
+	gotwant.TestError(t, err, nil)
+	rs, err = r.Get(context.Background(), "t1", footrest.Columns("cc"), "(>= .zz #3)", footrest.Columns("zz"), 0, 0)
+	gotwant.TestError(t, err, nil)
+	data, err = json.Marshal(rs)
+	gotwant.TestError(t, err, nil)
+	gotwant.Test(t, string(data), `{"table":"t1","records":[{"cc":"NaN"},{"cc":"-Infinity"},{"cc":"0x1p-2"}]}`)
+	_, err = conn.Exec(`DELETE FROM t1 WHERE zz >= 3`)
+	gotwant.TestError(t, err, nil)
+	config.Output.Decimal = "string"
+
+	config.Output.Columnar = true
+	config.Output.Binary = "hex"
+	r = footrest.New(conn, "sqlite", nil, true, config)
+	rs, err = r.Get(context.Background(), "t1", footrest.Columns("bb", "zz"), "(= .zz #1)", nil, 0, 0)
+	gotwant.TestError(t, err, nil)
+	data, err = json.Marshal(rs)
+	gotwant.TestError(t, err, nil)
+	gotwant.Test(t, string(data), `{"table":"t1","columns":["bb","zz"],"rows":[["0102",1]]}`)
+}
+
+func TestSQLiteEmbed(t *testing.T) {
+	conn, err := sql.Open("sqlite", ":memory:")
+	gotwant.TestError(t, err, nil)
+	defer conn.Close()
+	conn.SetMaxOpenConns(1)
+
+	for _, s := range []string{
+		`CREATE TABLE customers (id INTEGER PRIMARY KEY, name TEXT)`,
+		`CREATE TABLE orders (id INTEGER PRIMARY KEY, customer_id INTEGER REFERENCES customers)`,
+		`CREATE TABLE lines (order_id INTEGER REFERENCES orders(id), item TEXT, qty INTEGER)`,
+		`INSERT INTO customers VALUES (1, 'c1'), (2, 'c2')`,
+		`INSERT INTO orders VALUES (10, 1), (11, 2), (12, NULL)`,
+		`INSERT INTO lines VALUES (10, 'a', 1), (10, 'b', 2), (11, 'c', 3)`,
+	} {
+		_, err = conn.Exec(s)
+		gotwant.TestError(t, err, nil)
+	}
+
+	r := footrest.New(conn, "sqlite", nil, true, nil)
+	rs, err := r.GetOpts(context.Background(), "orders", nil, "", footrest.Columns("id"), 0, 0, footrest.GetOptions{
+		Embed: []footrest.Embed{
+			{Name: "customers", Select: footrest.Columns("name")},
+			{Name: "lines", Select: footrest.Columns("item"), Where: "(>= .qty #2)"},
+		},
+	})
+	gotwant.TestError(t, err, nil)
+	data, err := json.Marshal(rs)
+	gotwant.TestError(t, err, nil)
+	gotwant.Test(t, string(data), `{"table":"orders","records":[`+
+		`{"id":10,"customer_id":1,"customers":{"name":"c1"},"lines":[{"item":"b"}]},`+
+		`{"id":11,"customer_id":2,"customers":{"name":"c2"},"lines":[{"item":"c"}]},`+
+		`{"id":12,"customer_id":null,"customers":null,"lines":[]}]}`)
+
+	_, err = r.GetOpts(context.Background(), "orders", nil, "", nil, 0, 0, footrest.GetOptions{
+		Embed: []footrest.Embed{{Name: "items"}},
+	})
+	gotwant.TestError(t, err, "no relation")
+
+	// keys selected are kept
+	rs, err = r.GetOpts(context.Background(), "orders", nil, "(= .id #10)", nil, 0, 0, footrest.GetOptions{
+		Embed: []footrest.Embed{{Name: "customers", Select: footrest.Columns("id", "name")}},
+	})
+	gotwant.TestError(t, err, nil)
+	data, err = json.Marshal(rs)
+	gotwant.TestError(t, err, nil)
+	gotwant.Test(t, string(data), `{"table":"orders","records":[{"id":10,"customer_id":1,"customers":{"id":1,"name":"c1"}}]}`)
+
+	for _, rel := range []footrest.Relation{
+		{Table: "orders", RefTable: "customers"},
+		{Table: "orders", Columns: []string{"customer_id"}, RefTable: "customers", RefColumns: []string{"id", "name"}},
+	} {
+		config := footrest.DefaultConfig()
+		config.Relations = []footrest.Relation{rel}
+		r := footrest.New(conn, "sqlite", nil, true, config)
+		_, err = r.GetOpts(context.Background(), "orders", nil, "", nil, 0, 0, footrest.GetOptions{
+			Embed: []footrest.Embed{{Name: "customers"}},
+		})
+		gotwant.TestError(t, err, "invalid relation")
+	}
+}
+
+func TestSQLiteExprType(t *testing.T) {
+	conn, err := sql.Open("sqlite", ":memory:")
+	gotwant.TestError(t, err, nil)
+	defer conn.Close()
+	conn.SetMaxOpenConns(1)
+
+	_, err = conn.Exec(`CREATE TABLE t1 (id INTEGER, name TEXT)`)
+	gotwant.TestError(t, err, nil)
+	_, err = conn.Exec(`INSERT INTO t1 VALUES (1, 'One'), (2, 'Two')`)
+	gotwant.TestError(t, err, nil)
+
+	r := footrest.New(conn, "sqlite", nil, true, nil)
+	rs, err := r.Get(context.Background(), "t1", footrest.Columns("ID", "L=(LOWER .NAME)"), "(> (* .ID #10) #15)", nil, 0, 0)
+	gotwant.TestError(t, err, nil)
+	data, err := json.Marshal(rs)
+	gotwant.TestError(t, err, nil)
+	gotwant.Test(t, string(data), `{"table":"t1","records":[{"id":2,"L":"two"}]}`)
+
+	_, err = r.Get(context.Background(), "t1", nil, "(= (LOWER .ID) 'x')", nil, 0, 0)
+	gotwant.TestError(t, err, "text is expected, got number")
+
+	// a number is of number, even if converted by a type of a text column
+	rs, err = r.Get(context.Background(), "t1", footrest.Columns("ID"), "(= (SUBSTR .NAME 1 1) 'T')", nil, 0, 0)
+	gotwant.TestError(t, err, nil)
+	data, err = json.Marshal(rs)
+	gotwant.TestError(t, err, nil)
+	gotwant.Test(t, string(data), `{"table":"t1","records":[{"id":2}]}`)
+}
+
+func TestSQLiteSearch(t *testing.T) {
+	conn, err := sql.Open("sqlite", ":memory:")
+	gotwant.TestError(t, err, nil)
+	defer conn.Close()
+	conn.SetMaxOpenConns(1)
+
+	for _, s := range []string{
+		`CREATE TABLE docs (id INTEGER PRIMARY KEY, title TEXT, body TEXT)`,
+		`CREATE VIRTUAL TABLE docs_fts USING fts5(title, body, content='docs', content_rowid='id')`,
+		`INSERT INTO docs VALUES (1, 'Go', 'gophers dig holes'), (2, 'SQL', 'tables and gophers'), (3, 'Misc', 'nothing')`,
+		`INSERT INTO docs_fts(rowid, title, body) SELECT id, title, body FROM docs`,
+	} {
+		_, err = conn.Exec(s)
+		gotwant.TestError(t, err, nil)
+	}
+
+	config := footrest.DefaultConfig()
+	config.Search = map[string]footrest.SearchConfig{
+		"docs": {Columns: []string{"body"}},
+	}
+	r := footrest.New(conn, "sqlite", nil, true, config)
+	rs, err := r.GetOpts(context.Background(), "docs", footrest.Columns("id"), "(icontains .title 'q')", footrest.Columns("id"), 0, 0, footrest.GetOptions{Search: "gophers"})
+	gotwant.TestError(t, err, nil)
+	data, err := json.Marshal(rs)
+	gotwant.TestError(t, err, nil)
+	gotwant.Test(t, string(data), `{"table":"docs","records":[{"id":2}]}`)
+
+	_, err = r.GetOpts(context.Background(), "docs_fts", nil, "", nil, 0, 0, footrest.GetOptions{Search: "x"})
+	gotwant.TestError(t, err, "not configured")
+}
+
+func TestSQLiteODataMetadata(t *testing.T) {
+	conn, err := sql.Open("sqlite", ":memory:")
+	gotwant.TestError(t, err, nil)
+	defer conn.Close()
+	conn.SetMaxOpenConns(1)
+
+	_, err = conn.Exec(`CREATE TABLE item (id INTEGER PRIMARY KEY, name TEXT NOT NULL, price DECIMAL(10,2))`)
+	gotwant.TestError(t, err, nil)
+
+	r := footrest.New(conn, "sqlite", nil, true, nil)
+	data, err := r.ODataMetadata(context.Background())
+	gotwant.TestError(t, err, nil)
+	gotwant.Test(t, strings.Contains(string(data), `<EntityType Name="item">
+        <Key>
+          <PropertyRef Name="id"></PropertyRef>
+        </Key>
+        <Property Name="id" Type="Edm.Int64"></Property>
+        <Property Name="name" Type="Edm.String"></Property>
+        <Property Name="price" Type="Edm.Decimal"></Property>
+      </EntityType>`), true)
+	gotwant.Test(t, strings.Contains(string(data), `<EntitySet Name="item" EntityType="FootREST.item"></EntitySet>`), true)
+}
+
+func TestSQLiteGraphQL(t *testing.T) {
+	conn, err := sql.Open("sqlite", ":memory:")
+	gotwant.TestError(t, err, nil)
+	defer conn.Close()
+	conn.SetMaxOpenConns(1)
+
+	for _, s := range []string{
+		`CREATE TABLE customers (id INTEGER PRIMARY KEY, name TEXT)`,
+		`CREATE TABLE orders (id INTEGER PRIMARY KEY, customer_id INTEGER REFERENCES customers, amount INTEGER)`,
+		`CREATE TABLE lines (order_id INTEGER REFERENCES orders(id), item TEXT)`,
+		`INSERT INTO customers VALUES (1, 'c1'), (2, 'c2')`,
+		`INSERT INTO orders VALUES (10, 1, 100), (11, 1, 200), (12, 2, 300)`,
+		`INSERT INTO lines VALUES (10, 'a'), (11, 'b'), (11, 'c')`,
+	} {
+		_, err = conn.Exec(s)
+		gotwant.TestError(t, err, nil)
+	}
+
+	r := footrest.New(conn, "sqlite", nil, true, nil)
+	ctx := context.Background()
+
+	data, err := r.GraphQL(ctx, `query($min: Int) {
+  cs: customers(order: "-id", limit: 1) {
+    name
+    orders(filter: {amount: {gte: $min}}) { amount lines { item } }
+  }
+}`, map[string]any{"min": 150}, "")
+	gotwant.TestError(t, err, nil)
+	jdata, err := json.Marshal(data)
+	gotwant.TestError(t, err, nil)
+	gotwant.Test(t, string(jdata), `{"cs":[{"name":"c2","orders":[{"amount":300,"lines":[]}]}]}`)
+
+	data, err = r.GraphQL(ctx, `mutation {
+  update_orders(filter: {_or: [{id: 10}, {customer_id: {in: [2]}}]}, set: {amount: 0}) { affected_rows }
+  delete_lines(where: "(= .item 'a')") { affected_rows }
+}`, nil, "")
+	gotwant.TestError(t, err, nil)
+	jdata, err = json.Marshal(data)
+	gotwant.TestError(t, err, nil)
+	gotwant.Test(t, string(jdata), `{"update_orders":{"affected_rows":2},"delete_lines":{"affected_rows":1}}`)
+
+	// all or nothing
+	_, err = r.GraphQL(ctx, `mutation {
+  delete_lines(filter: {item: {is_null: false}}) { affected_rows }
+  insert_customers(object: {id: 1, name: "dup"}) { affected_rows }
+}`, nil, "")
+	gotwant.TestError(t, err, "UNIQUE")
+	data, err = r.GraphQL(ctx, `{ lines { item } }`, nil, "")
+	gotwant.TestError(t, err, nil)
+	jdata, err = json.Marshal(data)
+	gotwant.TestError(t, err, nil)
+	gotwant.Test(t, string(jdata), `{"lines":[{"item":"b"},{"item":"c"}]}`)
+}
+
+func TestSQLiteGraphQLParse(t *testing.T) {
+	conn := openSQLite(t,
+		`CREATE TABLE customers (id INTEGER PRIMARY KEY, name TEXT)`,
+		`INSERT INTO customers VALUES (1, 'c1'), (2, 'c2'), (3, 'c"3')`,
+	)
+	r := footrest.New(conn, "sqlite", nil, true, nil)
+	ctx := context.Background()
+
+	for _, c := range []struct {
+		query     string
+		variables map[string]any
+		opName    string
+		want      string
+	}{
+		{
+			query: `query Q($n: String = "c1", $ids: [Int!]! = [1, 2]) {
+  # a comment
+  a: customers(filter: {name: $n, id: {in: $ids}}) { id }
+  b: customers(where: "(= .id 2)", order: ["-id"]) { name t: __typename }
+}`,
+			variables: map[string]any{"n": "c2"},
+			want:      `{"a":[{"id":2}],"b":[{"name":"c2","t":"customers"}]}`,
+		},
+		{
+			query: `{ a: customers(filter: {name: "c\"3"}) { id } b: customers(filter: {name: """c1"""}) { id } }`,
+			want:  `{"a":[{"id":3}],"b":[{"id":1}]}`,
+		},
+		{
+			query:  `query A { customers(limit: 1) { id } } query B { customers(offset: 2) { id } }`,
+			opName: "B",
+			want:   `{"customers":[{"id":3}]}`,
+		},
+		{
+			query:     `query($n: String) { customers(filter: {name: {is_null: false}, id: $n}) { id } }`,
+			variables: map[string]any{"n": nil},
+			want:      `{"customers":[]}`,
+		},
+	} {
+		data, err := r.GraphQL(ctx, c.query, c.variables, c.opName)
+		gotwant.TestError(t, err, nil, gotwant.Desc(c.query))
+		jdata, err := json.Marshal(data)
+		gotwant.TestError(t, err, nil)
+		gotwant.Test(t, string(jdata), c.want, gotwant.Desc(c.query))
+	}
+
+	for _, c := range []struct {
+		query, opName, err string
+	}{
+		{query: `{ customers(filter: {name: "c1) { id } }`, err: "unterminated string"},
+		{query: `{ customers(filter: {name: """c1)) { id } }`, err: "unterminated block string"},
+		{query: `{ customers % }`, err: `unexpected "%"`},
+		{query: `{ customers(limit: ) { id } }`, err: `unexpected ")"`},
+		{query: `{ customers(limit: 1 { id } }`, err: `a name is expected, got "{"`},
+		{query: `query($n Int) { customers { id } }`, err: `":" is expected, got "Int"`},
+		{query: `query($n: [Int) { customers { id } }`, err: `"]" is expected, got ")"`},
+		{query: `query($n: Int = ) { customers { id } }`, err: `unexpected ")"`},
+		{query: `{ c: { id } }`, err: `a name is expected, got "{"`},
+		{query: `{ customers { id }`, err: "a name is expected, got the end"},
+		{query: `{ }`, err: "empty selection set"},
+		{query: ``, err: "no operation"},
+		{query: `fragment f on customers { id }`, err: "fragments are not supported"},
+		{query: `{ customers { ...f } }`, err: "fragments are not supported"},
+		{query: `{ customers @skip(if: true) { id } }`, err: "directives are not supported"},
+		{query: `subscription { customers { id } }`, err: `"subscription" is not supported`},
+		{query: `query A { customers { id } } query B { customers { id } }`, err: "operationName is required"},
+		{query: `query A { customers { id } }`, opName: "B", err: `operation "B" is not found`},
+		{query: `{ __schema { types { name } } }`, err: "introspection (__schema) is not supported"},
+		{query: `{ __type(name: "customers") { name } }`, err: "introspection (__type) is not supported"},
+		{query: `{ customers }`, err: "a selection set is required"},
+		{query: `{ customers(limit: -1) { id } }`, err: "limit must be a non-negative integer"},
+		{query: `{ customers(filter: {id: {between: 1}}) { id } }`, err: `filter operator "between" is not supported`},
+		{query: `{ customers(filter: 1) { id } }`, err: "filter must be an object"},
+		{query: `{ customers { orders { id } } }`, err: `no relation "orders"`},
+		{query: `mutation { delete_customers(filter: {}) { affected_rows } }`, err: "filter or where is required"},
+		{query: `mutation { delete_customers { affected_rows } }`, err: "filter or where is required"},
+		{query: `mutation { update_customers(filter: {id: 1}) { affected_rows } }`, err: "set is required"},
+		{query: `mutation { insert_customers { affected_rows } }`, err: "object or objects is required"},
+		{query: `mutation { upsert_customers(object: {id: 9}) { affected_rows } }`, err: `mutation "upsert_customers" is not supported`},
+		{query: `mutation { insert_customers(object: {id: 9}) { returning } }`, err: "returning needs a selection set"},
+		{query: `mutation { insert_customers(object: {id: 9}) { rows } }`, err: `field "rows" is not in customers_mutation`},
+	} {
+		_, err := r.GraphQL(ctx, c.query, nil, c.opName)
+		gotwant.TestError(t, err, c.err, gotwant.Desc(c.query))
+	}
+
+	// nothing is deleted or inserted
+	data, err := r.GraphQL(ctx, `{ customers { id } }`, nil, "")
+	gotwant.TestError(t, err, nil)
+	jdata, err := json.Marshal(data)
+	gotwant.TestError(t, err, nil)
+	gotwant.Test(t, string(jdata), `{"customers":[{"id":1},{"id":2},{"id":3}]}`)
+}
+
+func TestSQLiteGraphQLReturning(t *testing.T) {
+	conn := openSQLite(t, `CREATE TABLE customers (id INTEGER PRIMARY KEY, name TEXT)`)
+	r := footrest.New(conn, "sqlite", nil, true, nil)
+
+	mutate := func(query string) string {
+		t.Helper()
+
+		// a mutation first loads schemas, not to wait for the connection in the transaction
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		data, err := r.GraphQL(ctx, query, nil, "")
+		gotwant.TestError(t, err, nil)
+		jdata, err := json.Marshal(data)
+		gotwant.TestError(t, err, nil)
+		return string(jdata)
+	}
+
+	gotwant.Test(t, mutate(`mutation {
+  insert_customers(objects: [{id: 1, name: "a"}, {id: 2, name: "b"}]) { affected_rows returning { id name } }
+}`), `{"insert_customers":{"affected_rows":2,"returning":[{"id":1,"name":"a"},{"id":2,"name":"b"}]}}`)
+
+	// rows updated are returned even if they no longer match the filter
+	gotwant.Test(t, mutate(`mutation {
+  update_customers(filter: {name: "a"}, set: {name: "x"}) { affected_rows returning { id name __typename } }
+}`), `{"update_customers":{"affected_rows":1,"returning":[{"id":1,"name":"x","__typename":"customers"}]}}`)
+	gotwant.Test(t, mutate(`mutation {
+  update_customers(filter: {id: 2}, set: {id: 3}) { returning { id name } affected_rows }
+}`), `{"update_customers":{"returning":[{"id":3,"name":"b"}],"affected_rows":1}}`)
+	gotwant.Test(t, mutate(`mutation {
+  update_customers(filter: {id: 9}, set: {name: "y"}) { affected_rows returning { id } }
+}`), `{"update_customers":{"affected_rows":0,"returning":[]}}`)
+
+	gotwant.Test(t, mutate(`mutation {
+  d: delete_customers(where: "(in .id #1 #3)") { affected_rows returning { name } }
+}`), `{"d":{"affected_rows":2,"returning":[{"name":"x"},{"name":"b"}]}}`)
+}
+
+func TestSQLiteIfMatch(t *testing.T) {
+	conn, err := sql.Open("sqlite", ":memory:")
+	gotwant.TestError(t, err, nil)
+	defer conn.Close()
+	conn.SetMaxOpenConns(1)
+
+	_, err = conn.Exec(`CREATE TABLE t1 (id INTEGER PRIMARY KEY, name TEXT, ver INTEGER DEFAULT 1)`)
+	gotwant.TestError(t, err, nil)
+	_, err = conn.Exec(`INSERT INTO t1 (id, name) VALUES (1, 'a'), (2, 'b')`)
+	gotwant.TestError(t, err, nil)
+
+	config := footrest.DefaultConfig()
+	config.Versions = map[string]string{"t1": "ver"}
+	r := footrest.New(conn, "sqlite", nil, true, config)
+	ctx := context.Background()
+
+	ra, err := r.PutIfMatch(ctx, "t1", map[string]any{"name": "x"}, "(= .id #1)", `"1"`)
+	gotwant.TestError(t, err, nil)
+	gotwant.Test(t, ra, int64(1))
+
+	// stale
+	_, err = r.PutIfMatch(ctx, "t1", map[string]any{"name": "y"}, "(= .id #1)", `"1"`)
+	gotwant.Test(t, errors.Is(err, footrest.ErrPreconditionFailed), true)
+	_, err = r.DeleteIfMatch(ctx, "t1", "(= .id #1)", `"1"`)
+	gotwant.Test(t, errors.Is(err, footrest.ErrPreconditionFailed), true)
+
+	rs, err := r.Get(ctx, "t1", footrest.Columns("name", "ver"), "(= .id #1)", nil, 0, 0)
+	gotwant.TestError(t, err, nil)
+	data, err := json.Marshal(rs)
+	gotwant.TestError(t, err, nil)
+	gotwant.Test(t, string(data), `{"table":"t1","records":[{"name":"x","ver":2}]}`)
+
+	// strong comparison
+	_, err = r.PutIfMatch(ctx, "t1", map[string]any{"name": "y"}, "(= .id #1)", `W/"2"`)
+	gotwant.Test(t, errors.Is(err, footrest.ErrPreconditionFailed), true)
+
+	ra, err = r.DeleteIfMatch(ctx, "t1", "(= .id #1)", `"2"`)
+	gotwant.TestError(t, err, nil)
+	gotwant.Test(t, ra, int64(1))
+
+	// a single row
+	_, err = conn.Exec(`INSERT INTO t1 (id, name) VALUES (3, 'c')`)
+	gotwant.TestError(t, err, nil)
+	_, err = r.PutIfMatch(ctx, "t1", map[string]any{"name": "y"}, "(>= .id #2)", `"1"`)
+	gotwant.TestError(t, err, "where matches 2 rows")
+	_, err = r.DeleteIfMatch(ctx, "t1", "(>= .id #2)", `*`)
+	gotwant.TestError(t, err, "where matches 2 rows")
+	var n int
+	gotwant.TestError(t, conn.QueryRow(`SELECT COUNT(*) FROM t1 WHERE name = 'y' OR ver <> 1`).Scan(&n), nil)
+	gotwant.Test(t, n, 0)
+	gotwant.TestError(t, conn.QueryRow(`SELECT COUNT(*) FROM t1`).Scan(&n), nil)
+	gotwant.Test(t, n, 2)
+
+	// the written row is read back by its key, not by the where
+	rec := serve(r, http.MethodPut, "/t1?where="+url.QueryEscape("(= .id #2)"), `{"id":4}`,
+		footrest.HeaderIfMatch, `"1"`, footrest.HeaderPrefer, "return=representation")
+	gotwant.Test(t, rec.Body.String(), `{"result": [{"id":4,"name":"b","ver":2}]}`)
+	gotwant.Test(t, rec.Header().Get(footrest.HeaderETag), `"2"`)
+}
+
+func TestSQLiteIfMatchHash(t *testing.T) {
+	conn := openSQLite(t,
+		`CREATE TABLE t1 (id INTEGER PRIMARY KEY, name TEXT)`,
+		`INSERT INTO t1 VALUES (1, 'a'), (2, 'b')`,
+	)
+
+	d := *footrest.GetDialect("sqlite")
+	d.LockRows = func() [2]string {
+		return [2]string{"NOT INDEXED", "LIMIT -1"}
+	}
+	r := footrest.NewDialect(conn, &d, nil, true, footrest.DefaultConfig())
+	ctx := context.Background()
+
+	etag := func(id string) string {
+		rec := serve(r, http.MethodGet, "/t1?where="+url.QueryEscape("(= .id #"+id+")"), "")
+		gotwant.Test(t, rec.Code, http.StatusOK)
+		return rec.Header().Get(footrest.HeaderETag)
+	}
+
+	tag := etag("1")
+	gotwant.Test(t, tag != "", true)
+
+	ra, err := r.PutIfMatch(ctx, "t1", map[string]any{"name": "x"}, "(= .id #1)", tag)
+	gotwant.TestError(t, err, nil)
+	gotwant.Test(t, ra, int64(1))
+
+	// stale
+	_, err = r.PutIfMatch(ctx, "t1", map[string]any{"name": "y"}, "(= .id #1)", tag)
+	gotwant.Test(t, errors.Is(err, footrest.ErrPreconditionFailed), true)
+	_, err = r.DeleteIfMatch(ctx, "t1", "(= .id #1)", "W/"+etag("1"))
+	gotwant.Test(t, errors.Is(err, footrest.ErrPreconditionFailed), true)
+
+	rec := serve(r, http.MethodDelete, "/t1?where="+url.QueryEscape("(= .id #1)"), "", footrest.HeaderIfMatch, tag)
+	gotwant.Test(t, rec.Code, http.StatusPreconditionFailed)
+	rec = serve(r, http.MethodDelete, "/t1?where="+url.QueryEscape("(= .id #1)"), "", footrest.HeaderIfMatch, etag("1"))
+	gotwant.Test(t, rec.Code, http.StatusOK)
+
+	// the written row is read back by its key, not by the where, in the transaction
+	prefer := []string{footrest.HeaderPrefer, "return=representation"}
+	_, err = conn.Exec(`INSERT INTO t1 VALUES (3, 'c')`)
+	gotwant.TestError(t, err, nil)
+	tag = etag("3")
+	rec = serve(r, http.MethodPut, "/t1?where="+url.QueryEscape("(= .id #3)"), `{"id":4}`,
+		append(prefer, footrest.HeaderIfMatch, tag)...)
+	gotwant.Test(t, rec.Body.String(), `{"result": [{"id":4,"name":"c"}]}`)
+	gotwant.Test(t, rec.Header().Get(footrest.HeaderETag), etag("4"))
+	gotwant.Test(t, tag != etag("4"), true)
+
+	rec = serve(r, http.MethodDelete, "/t1?where="+url.QueryEscape("(= .id #4)"), "",
+		append(prefer, footrest.HeaderIfMatch, tag)...)
+	gotwant.Test(t, rec.Code, http.StatusPreconditionFailed)
+	rec = serve(r, http.MethodDelete, "/t1?where="+url.QueryEscape("(= .id #4)"), "",
+		append(prefer, footrest.HeaderIfMatch, etag("4"))...)
+	gotwant.Test(t, rec.Body.String(), `{"result": [{"id":4,"name":"c"}]}`)
+
+	// the row is read with Dialect.LockRows
+	d.LockRows = func() [2]string {
+		return [2]string{"", "FOR UPDATE"}
+	}
+	r = footrest.NewDialect(conn, &d, nil, true, footrest.DefaultConfig())
+	_, err = r.DeleteIfMatch(ctx, "t1", "(= .id #2)", etag("2"))
+	gotwant.TestError(t, err, "syntax error")
+}
+
+func TestSQLiteCache(t *testing.T) {
+	conn, err := sql.Open("sqlite", ":memory:")
+	gotwant.TestError(t, err, nil)
+	defer conn.Close()
+	conn.SetMaxOpenConns(1)
+
+	_, err = conn.Exec(`CREATE TABLE t1 (id INTEGER PRIMARY KEY, name TEXT)`)
+	gotwant.TestError(t, err, nil)
+	_, err = conn.Exec(`INSERT INTO t1 VALUES (1, 'a')`)
+	gotwant.TestError(t, err, nil)
+
+	config := footrest.DefaultConfig()
+	config.Cache.TTLs = map[string]int64{"t1": 60_000}
+	r := footrest.New(conn, "sqlite", nil, true, config)
+	ctx := context.Background()
+
+	get := func() string {
+		rs, err := r.Get(ctx, "t1", footrest.Columns("name"), "(= .id #1)", nil, 0, 0)
+		gotwant.TestError(t, err, nil)
+		data, err := json.Marshal(rs)
+		gotwant.TestError(t, err, nil)
+		return string(data)
+	}
+
+	gotwant.Test(t, get(), `{"table":"t1","records":[{"name":"a"}]}`)
+
+	// not by footrest
+	_, err = conn.Exec(`UPDATE t1 SET name = 'b'`)
+	gotwant.TestError(t, err, nil)
+	gotwant.Test(t, get(), `{"table":"t1","records":[{"name":"a"}]}`)
+
+	// by footrest
+	_, err = r.Put(ctx, "t1", map[string]any{"name": "c"}, "(= .id #1)")
+	gotwant.TestError(t, err, nil)
+	gotwant.Test(t, get(), `{"table":"t1","records":[{"name":"c"}]}`)
+}
+
+func TestSQLiteStmtCache(t *testing.T) {
+	conn, err := sql.Open("sqlite", ":memory:")
+	gotwant.TestError(t, err, nil)
+	defer conn.Close()
+	conn.SetMaxOpenConns(1)
+
+	_, err = conn.Exec(`CREATE TABLE t1 (id INTEGER PRIMARY KEY, name TEXT)`)
+	gotwant.TestError(t, err, nil)
+	_, err = conn.Exec(`INSERT INTO t1 VALUES (1, 'a')`)
+	gotwant.TestError(t, err, nil)
+
+	config := footrest.DefaultConfig()
+	config.Stmt.CacheSize = 1
+	r := footrest.New(conn, "sqlite", nil, true, config)
+	ctx := context.Background()
+
+	get := func(cols ...string) {
+		_, err := r.Get(ctx, "t1", cols, "(= .id #1)", nil, 0, 0)
+		gotwant.TestError(t, err, nil)
+	}
+
+	get("name")
+	get("name")
+	gotwant.Test(t, r.StmtCacheStats(), footrest.StmtCacheStats{Size: 1, Hits: 1, Misses: 1})
+
+	get("id") // evicts
+	gotwant.Test(t, r.StmtCacheStats(), footrest.StmtCacheStats{Size: 1, Hits: 1, Misses: 2, Evictions: 1})
+
+	// a write prepares its statement before the transaction, and binds it in the transaction
+	_, err = r.Put(ctx, "t1", map[string]any{"name": "b"}, "(= .id #1)")
+	gotwant.TestError(t, err, nil)
+	gotwant.Test(t, r.StmtCacheStats(), footrest.StmtCacheStats{Size: 1, Hits: 2, Misses: 3, Evictions: 2})
+
+	get("id")
+	gotwant.Test(t, r.StmtCacheStats().HitRatio(), 2.0/6)
+
+	r.RefreshSchema()
+	gotwant.Test(t, r.StmtCacheStats().Size, 0)
+	get("id")
+	gotwant.Test(t, r.StmtCacheStats(), footrest.StmtCacheStats{Size: 1, Hits: 2, Misses: 5, Evictions: 3})
+
+	// writes hit
+	config.Stmt.CacheSize = 10
+	r = footrest.New(conn, "sqlite", nil, true, config)
+	for i := 2; i <= 4; i++ {
+		_, err = r.Post(ctx, "t1", map[string]any{"id": i, "name": "p"})
+		gotwant.TestError(t, err, nil)
+		_, err = r.Delete(ctx, "t1", "(= .id #"+strconv.Itoa(i-1)+")")
+		gotwant.TestError(t, err, nil)
+	}
+	rec := serve(r, http.MethodPost, "/!bulk", `[
+  {"method": "POST", "table": "t1", "values": {"id": 5, "name": "p"}},
+  {"method": "DELETE", "table": "t1", "whereExpr": "(= .id #4)"}
+]`)
+	gotwant.Test(t, rec.Code, http.StatusOK)
+	gotwant.Test(t, r.StmtCacheStats(), footrest.StmtCacheStats{Size: 2, Hits: 8, Misses: 2})
+}
+
+func TestSQLiteNewConn(t *testing.T) {
+	config := footrest.DefaultConfig()
+	config.Pool.MaxOpenConns = 3
+	config.Pool.PingRetries = 1
+	config.Pool.PingBackoff = 1
+
+	r, conn, err := footrest.NewConn("sqlite", filepath.Join(t.TempDir(), "test.db"), nil, true, config)
+	gotwant.TestError(t, err, nil)
+	defer conn.Close()
+
+	gotwant.Test(t, conn.Stats().MaxOpenConnections, 3)
+	gotwant.TestError(t, r.Ping(context.Background()), nil)
+
+	_, _, err = footrest.NewConn("sqlite", filepath.Join(t.TempDir(), "nodir", "test.db"), nil, true, config)
+	gotwant.TestError(t, err, "ping")
+}
+
+func TestSQLiteMetrics(t *testing.T) {
+	conn, err := sql.Open("sqlite", ":memory:")
+	gotwant.TestError(t, err, nil)
+	defer conn.Close()
+	conn.SetMaxOpenConns(1)
+
+	_, err = conn.Exec(`CREATE TABLE t1 (id INTEGER PRIMARY KEY, name TEXT)`)
+	gotwant.TestError(t, err, nil)
+	_, err = conn.Exec(`INSERT INTO t1 VALUES (1, 'a'), (2, 'b')`)
+	gotwant.TestError(t, err, nil)
+
+	r := footrest.New(conn, "sqlite", nil, true, nil)
+	ctx := context.Background()
+
+	_, err = r.Get(ctx, "t1", nil, "", nil, 0, 0)
+	gotwant.TestError(t, err, nil)
+	_, err = r.Put(ctx, "t1", map[string]any{"name": "c"}, "(= .id #1)")
+	gotwant.TestError(t, err, nil)
+
+	metrics := string(r.Metrics())
+	for _, line := range []string{
+		"# TYPE footrest_query_duration_seconds histogram",
+		`footrest_query_duration_seconds_count{kind="select"} 1`,
+		`footrest_query_duration_seconds_bucket{kind="update",le="+Inf"} 1`,
+		`footrest_rows_returned_total{kind="select"} 2`,
+		`footrest_rows_affected_total{kind="update"} 1`,
+		"footrest_schema_cache_misses_total 1",
+		"footrest_schema_cache_hits_total 1",
+		"footrest_db_max_open_connections 1",
+	} {
+		if !strings.Contains(metrics, line+"\n") {
+			t.Errorf("%q is not in\n%s", line, metrics)
+		}
+	}
+
+	// methods out of bulk are not labels
+	rec := serve(r, http.MethodPost, "/!bulk", `[
+  {"method": "post", "table": "t1", "values": {"id": 3, "name": "c"}},
+  {"method": "NOSUCH", "table": "t1"}
+]`)
+	gotwant.Test(t, rec.Code, http.StatusBadRequest)
+
+	metrics = string(r.Metrics())
+	gotwant.Test(t, strings.Contains(metrics, "NOSUCH"), false)
+	for _, line := range []string{
+		`footrest_bulk_operations_total{method="POST",result="ok"} 1`,
+		`footrest_bulk_operations_total{method="invalid",result="error"} 1`,
+	} {
+		if !strings.Contains(metrics, line+"\n") {
+			t.Errorf("%q is not in\n%s", line, metrics)
+		}
+	}
+}
+
+func TestSQLiteLog(t *testing.T) {
+	conn, err := sql.Open("sqlite", ":memory:")
+	gotwant.TestError(t, err, nil)
+	defer conn.Close()
+	conn.SetMaxOpenConns(1)
+
+	_, err = conn.Exec(`CREATE TABLE t1 (id INTEGER PRIMARY KEY, name TEXT, password TEXT)`)
+	gotwant.TestError(t, err, nil)
+	_, err = conn.Exec(`INSERT INTO t1 VALUES (1, 'a', 'xyzzy')`)
+	gotwant.TestError(t, err, nil)
+
+	config := footrest.DefaultConfig()
+	config.Log.Format = "json"
+	config.Log.Level = "debug"
+	config.Log.Redact = []string{"PASSWORD"}
+	r := footrest.New(conn, "sqlite", nil, true, config)
+
+	var buf bytes.Buffer
+	r.SetLogger(footrest.NewLogger(config.Log, &buf))
+	ctx := context.Background()
+
+	type entry struct {
+		Msg  string `json:"msg"`
+		Stmt string `json:"stmt"`
+		Args []any  `json:"args"`
+		Rows int64  `json:"rows"`
+	}
+	lastEntry := func() entry {
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		var e entry
+		gotwant.TestError(t, json.Unmarshal([]byte(lines[len(lines)-1]), &e), nil)
+		buf.Reset()
+		return e
+	}
+
+	_, err = r.Get(ctx, "t1", footrest.Columns("name"), "(= .name 'a')", nil, 0, 0)
+	gotwant.TestError(t, err, nil)
+	gotwant.Test(t, lastEntry(), entry{Msg: "sql", Stmt: "SELECT name FROM t1 WHERE name = ?", Args: []any{"a"}, Rows: 1})
+
+	_, err = r.Put(ctx, "t1", map[string]any{"password": "plugh"}, "(= .id #1)")
+	gotwant.TestError(t, err, nil)
+	e := lastEntry()
+	gotwant.Test(t, e.Args, []any{"[REDACTED]", "[REDACTED]"})
+	gotwant.Test(t, e.Rows, int64(1))
+
+	// the query of a request is not logged
+	rec := serve(r, http.MethodGet, "/t1?where="+url.QueryEscape("(= .password 'plugh')"), "")
+	gotwant.Test(t, rec.Code, http.StatusOK)
+	gotwant.Test(t, strings.Contains(buf.String(), "plugh"), false)
+	gotwant.Test(t, strings.Contains(buf.String(), `"uri":"/t1?[REDACTED]"`), true, gotwant.Desc(buf.String()))
+}
+
+// openSQLite opens an in-memory database and executes stmts.
+func openSQLite(t *testing.T, stmts ...string) *sql.DB {
+	t.Helper()
+
+	conn, err := sql.Open("sqlite", ":memory:")
+	gotwant.TestError(t, err, nil)
+	t.Cleanup(func() { conn.Close() })
+	conn.SetMaxOpenConns(1)
+
+	for _, s := range stmts {
+		_, err = conn.Exec(s)
+		gotwant.TestError(t, err, nil)
+	}
+	return conn
+}
+
+// serve serves a request by r.Handler.
+// headers are pairs of a name and a value.
+func serve(r *footrest.FootREST, method, target, body string, headers ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+
+	rec := httptest.NewRecorder()
+	r.Handler().ServeHTTP(rec, req)
+	return rec
+}
+
+func TestSQLiteImport(t *testing.T) {
+	conn := openSQLite(t, `CREATE TABLE t1 (id INTEGER PRIMARY KEY, name TEXT)`)
+
+	config := footrest.DefaultConfig()
+	config.Import.BatchSize = 2
+	r := footrest.New(conn, "sqlite", nil, true, config)
+
+	count := func() int {
+		var n int
+		gotwant.TestError(t, conn.QueryRow(`SELECT COUNT(*) FROM t1`).Scan(&n), nil)
+		return n
+	}
+
+	t.Run("CSV", func(t *testing.T) {
+		// the header is mapped to columns case-insensitively, batches of 2, 2 and 1
+		body := "\ufeffID,Name\n1,a\n2,\n3,\"c,c\"\nx,d\n4\n5,e\n6,f\n"
+		rec := serve(r, http.MethodPost, "/t1", body, "Content-Type", "text/csv")
+		gotwant.Test(t, rec.Code, http.StatusOK)
+		gotwant.Test(t, rec.Body.String(), `{"result": {"inserted":5,"rejected":[{"line":5,"error":"column \"id\": strconv.ParseInt: parsing \"x\": invalid syntax"},{"line":6,"error":"1 fields, want 2"}]}}`)
+		gotwant.Test(t, count(), 5)
+
+		var name sql.NullString
+		gotwant.TestError(t, conn.QueryRow(`SELECT name FROM t1 WHERE id = 2`).Scan(&name), nil)
+		gotwant.Test(t, name.Valid, false)
+	})
+
+	t.Run("CSVHeader", func(t *testing.T) {
+		rec := serve(r, http.MethodPost, "/t1", "id,nosuch\n7,g\n", "Content-Type", "text/csv")
+		gotwant.Test(t, rec.Code, http.StatusBadRequest)
+		gotwant.Test(t, count(), 5)
+	})
+
+	t.Run("NDJSON", func(t *testing.T) {
+		body := `{"id":7,"name":"g"}` + "\n\n" + `[1]` + "\n" + `{"id":8,"name":"h"}`
+		rec := serve(r, http.MethodPost, "/t1", body, "Content-Type", "application/x-ndjson")
+		gotwant.Test(t, rec.Code, http.StatusOK)
+		gotwant.Test(t, rec.Body.String(), `{"result": {"inserted":2,"rejected":[{"line":3,"error":"json: cannot unmarshal array into Go value of type map[string]interface {}"}]}}`)
+		gotwant.Test(t, count(), 7)
+	})
+
+	t.Run("NDJSONSchema", func(t *testing.T) {
+		// the schema is loaded out of the transaction holding the only connection
+		conn := openSQLite(t, `CREATE TABLE t2 (id INTEGER PRIMARY KEY, name TEXT)`)
+		r := footrest.New(conn, "sqlite", nil, true, config)
+		rec := serve(r, http.MethodPost, "/t2", `{"id":1,"name":"a"}`, "Content-Type", "application/x-ndjson")
+		gotwant.Test(t, rec.Body.String(), `{"result": {"inserted":1,"rejected":[]}}`)
+	})
+
+	t.Run("Table", func(t *testing.T) {
+		conn := openSQLite(t,
+			`CREATE TABLE t2 (id INTEGER PRIMARY KEY, name TEXT)`,
+			`CREATE TABLE t3 (id INTEGER PRIMARY KEY, name TEXT)`,
+		)
+		tconfig := *config
+		tconfig.Tables = []string{"t2"}
+		r := footrest.New(conn, "sqlite", nil, true, &tconfig)
+
+		for _, typ := range []string{"text/csv", "application/x-ndjson"} {
+			body := "id,name\n1,a\n"
+			if typ != "text/csv" {
+				body = `{"id":1,"name":"a"}`
+			}
+
+			rec := serve(r, http.MethodPost, "/t2%20WHERE%201=0%20UNION%20SELECT%201", body, "Content-Type", typ)
+			gotwant.Test(t, rec.Code, http.StatusBadRequest, gotwant.Desc(typ))
+			gotwant.Test(t, strings.Contains(rec.Body.String(), "invalid table name"), true, gotwant.Desc(rec.Body.String()))
+
+			rec = serve(r, http.MethodPost, "/t3", body, "Content-Type", typ)
+			gotwant.Test(t, rec.Code, http.StatusBadRequest, gotwant.Desc(typ))
+			gotwant.Test(t, strings.Contains(rec.Body.String(), "not exposed"), true, gotwant.Desc(rec.Body.String()))
+		}
+
+		var n int
+		gotwant.TestError(t, conn.QueryRow(`SELECT (SELECT COUNT(*) FROM t2) + (SELECT COUNT(*) FROM t3)`).Scan(&n), nil)
+		gotwant.Test(t, n, 0)
+	})
+
+	t.Run("Rollback", func(t *testing.T) {
+		// a duplicate key fails the whole import
+		rec := serve(r, http.MethodPost, "/t1", "id,name\n9,i\n1,dup\n", "Content-Type", "text/csv")
+		gotwant.Test(t, rec.Code, http.StatusBadRequest)
+		gotwant.Test(t, count(), 7)
+	})
+
+	t.Run("Encoding", func(t *testing.T) {
+		r := footrest.New(conn, "sqlite", japanese.ShiftJIS, true, config)
+		rec := serve(r, http.MethodPost, "/t1", "id,name\n10,\u3042\n", "Content-Type", "text/csv")
+		gotwant.Test(t, rec.Code, http.StatusOK)
+
+		var name []byte
+		gotwant.TestError(t, conn.QueryRow(`SELECT CAST(name AS BLOB) FROM t1 WHERE id = 10`).Scan(&name), nil)
+		gotwant.Test(t, name, []byte{0x82, 0xa0})
+	})
+}
+
+func TestSQLiteBulk(t *testing.T) {
+	conn := openSQLite(t,
+		`CREATE TABLE t1 (id INTEGER PRIMARY KEY, name TEXT NOT NULL)`,
+		`INSERT INTO t1 VALUES (1, 'a'), (2, 'b')`,
+	)
+	r := footrest.New(conn, "sqlite", nil, true, nil)
+
+	names := func() string {
+		rows, err := conn.Query(`SELECT id || name FROM t1 ORDER BY id`)
+		gotwant.TestError(t, err, nil)
+		defer rows.Close()
+		var ss []string
+		for rows.Next() {
+			var s string
+			gotwant.TestError(t, rows.Scan(&s), nil)
+			ss = append(ss, s)
+		}
+		return strings.Join(ss, ",")
+	}
+
+	type result struct {
+		Index        int    `json:"index"`
+		Method       string `json:"method"`
+		RowsAffected int64  `json:"rowsAffected"`
+		Error        string `json:"error"`
+	}
+	type bulkError struct {
+		Index   int    `json:"index"`
+		Method  string `json:"method"`
+		Table   string `json:"table"`
+		Message string `json:"message"`
+	}
+
+	t.Run("Results", func(t *testing.T) {
+		rec := serve(r, http.MethodPost, "/!bulk", `[
+			{"method": "DELETE", "table": "t1", "where": {"id": "1"}},
+			{"method": "POST", "table": "t1", "values": {"id": 3, "name": "c"}},
+			{"method": "PUT", "table": "t1", "where": {"id": "2"}, "values": {"name": "B"}},
+			{"method": "UPSERT", "table": "t1", "where": {"id": "9"}, "values": {"id": 9, "name": "i"}}
+		]`)
+		gotwant.Test(t, rec.Code, http.StatusOK)
+		gotwant.Test(t, rec.Body.String(), `{"result": [`+
+			`{"index":0,"method":"DELETE","table":"t1","rowsAffected":1},`+
+			`{"index":1,"method":"POST","table":"t1","rowsAffected":1},`+
+			`{"index":2,"method":"PUT","table":"t1","rowsAffected":1},`+
+			`{"index":3,"method":"UPSERT","table":"t1","rowsAffected":1}]}`)
+		gotwant.Test(t, names(), "2B,3c,9i")
+	})
+
+	t.Run("BulkError", func(t *testing.T) {
+		rec := serve(r, http.MethodPost, "/!bulk", `[
+			{"method": "POST", "table": "t1", "values": {"id": 4, "name": "d"}},
+			{"method": "POST", "table": "t1", "values": {"id": 3, "name": "dup"}}
+		]`)
+		gotwant.Test(t, rec.Code, http.StatusBadRequest)
+
+		var body struct {
+			Error bulkError `json:"error"`
+		}
+		gotwant.TestError(t, json.Unmarshal(rec.Body.Bytes(), &body), nil)
+		gotwant.Test(t, body.Error.Index, 1)
+		gotwant.Test(t, body.Error.Method, "POST")
+		gotwant.Test(t, body.Error.Table, "t1")
+		gotwant.Test(t, strings.Contains(body.Error.Message, "UNIQUE"), true)
+
+		// all rolled back
+		gotwant.Test(t, names(), "2B,3c,9i")
+
+		rec = serve(r, http.MethodPost, "/!bulk", `[{"method": "GET", "table": "t1"}]`)
+		gotwant.Test(t, rec.Code, http.StatusBadRequest)
+		gotwant.TestError(t, json.Unmarshal(rec.Body.Bytes(), &body), nil)
+		gotwant.Test(t, body.Error, bulkError{Index: 0, Method: "GET", Table: "t1", Message: `unsupported method "GET"`})
+	})
+
+	t.Run("Continue", func(t *testing.T) {
+		rec := serve(r, http.MethodPost, "/!bulk?continue=1", `[
+			{"method": "POST", "table": "t1", "values": {"id": 4, "name": "d"}},
+			{"method": "POST", "table": "t1", "values": {"id": 3, "name": "dup"}},
+			{"method": "PUT", "table": "t1", "where": {"id": "4"}, "values": {"name": null}},
+			{"method": "POST", "table": "t1", "values": {"id": 5, "name": "e"}}
+		]`)
+		gotwant.Test(t, rec.Code, http.StatusOK)
+
+		var body struct {
+			Result []result `json:"result"`
+		}
+		gotwant.TestError(t, json.Unmarshal(rec.Body.Bytes(), &body), nil)
+		gotwant.Test(t, len(body.Result), 4)
+		gotwant.Test(t, body.Result[0], result{Index: 0, Method: "POST", RowsAffected: 1})
+		gotwant.Test(t, body.Result[1].Error != "", true)
+		gotwant.Test(t, body.Result[2].Error != "", true)
+		gotwant.Test(t, body.Result[3], result{Index: 3, Method: "POST", RowsAffected: 1})
+
+		// only the failing ones are rolled back to their savepoints
+		gotwant.Test(t, names(), "2B,3c,4d,5e,9i")
+	})
+
+	t.Run("Refs", func(t *testing.T) {
+		rec := serve(r, http.MethodPost, "/!bulk", `[
+			{"id": "p", "method": "POST", "table": "t1", "values": {"name": "p"}, "returning": true},
+			{"method": "POST", "table": "t1", "values": {"name": "$ops.p.id"}},
+			{"method": "PUT", "table": "t1", "where": {"id": "$ops.p.id"}, "values": {"name": "$$ops.p.id"}}
+		]`)
+		gotwant.Test(t, rec.Code, http.StatusOK)
+		gotwant.Test(t, rec.Body.String(), `{"result": [`+
+			`{"index":0,"id":"p","method":"POST","table":"t1","rowsAffected":1,"records":[{"id":10,"name":"p"}]},`+
+			`{"index":1,"method":"POST","table":"t1","rowsAffected":1},`+
+			`{"index":2,"method":"PUT","table":"t1","rowsAffected":1}]}`)
+		gotwant.Test(t, names(), "2B,3c,4d,5e,9i,10$ops.p.id,1110")
+
+		// all the references in a where, with text after them, and in whereExpr
+		rec = serve(r, http.MethodPost, "/!bulk", `[
+			{"id": "a", "method": "POST", "table": "t1", "values": {"name": "a"}},
+			{"id": "b", "method": "POST", "table": "t1", "values": {"name": "b"}},
+			{"method": "PUT", "table": "t1", "where": {"id": "in.$ops.a.id,$ops.b.id", "name": "'$$ops.'"}, "values": {"name": "x"}},
+			{"method": "PUT", "table": "t1", "where": {"id": "in.$ops.a.id,$ops.b.id"}, "values": {"name": "$ops.b.name"}},
+			{"method": "PUT", "table": "t1", "whereExpr": "(or (= .id $ops.a.id) (= .id $ops.b.id))", "values": {"name": "z"}},
+			{"method": "DELETE", "table": "t1", "whereExpr": ["=", ".id", "$ops.b.id"]}
+		]`)
+		gotwant.Test(t, rec.Code, http.StatusOK, gotwant.Desc(rec.Body.String()))
+		gotwant.Test(t, rec.Body.String(), `{"result": [`+
+			`{"index":0,"id":"a","method":"POST","table":"t1","rowsAffected":1},`+
+			`{"index":1,"id":"b","method":"POST","table":"t1","rowsAffected":1},`+
+			`{"index":2,"method":"PUT","table":"t1","rowsAffected":0},`+
+			`{"index":3,"method":"PUT","table":"t1","rowsAffected":2},`+
+			`{"index":4,"method":"PUT","table":"t1","rowsAffected":2},`+
+			`{"index":5,"method":"DELETE","table":"t1","rowsAffected":1}]}`)
+		gotwant.Test(t, names(), "2B,3c,4d,5e,9i,10$ops.p.id,1110,12z")
+
+		var body struct {
+			Error bulkError `json:"error"`
+		}
+		rec = serve(r, http.MethodPost, "/!bulk", `[
+			{"id": "p", "method": "DELETE", "table": "t1", "where": {"id": "11"}},
+			{"method": "POST", "table": "t1", "values": {"name": "$ops.p.id"}}
+		]`)
+		gotwant.Test(t, rec.Code, http.StatusBadRequest)
+		gotwant.TestError(t, json.Unmarshal(rec.Body.Bytes(), &body), nil)
+		gotwant.Test(t, body.Error.Message, `reference "$ops.p.id": no rows returned`)
+	})
+
+	t.Run("RefsNoReturning", func(t *testing.T) {
+		// the input values must not be referred as inserted rows
+		d := *footrest.GetDialect("sqlite")
+		d.Returning = nil
+		r := footrest.NewDialect(conn, &d, nil, true, nil)
+
+		rec := serve(r, http.MethodPost, "/!bulk", `[
+			{"id": "p", "method": "POST", "table": "t1", "values": {"name": "q"}, "returning": true},
+			{"method": "POST", "table": "t1", "values": {"name": "$ops.p.name"}}
+		]`)
+		gotwant.Test(t, rec.Code, http.StatusBadRequest)
+
+		var body struct {
+			Error bulkError `json:"error"`
+		}
+		gotwant.TestError(t, json.Unmarshal(rec.Body.Bytes(), &body), nil)
+		gotwant.Test(t, body.Error.Index, 1)
+		gotwant.Test(t, body.Error.Message, `reference "$ops.p.name": no rows returned`)
+
+		// returning without id outputs the input values
+		rec = serve(r, http.MethodPost, "/!bulk", `[
+			{"method": "POST", "table": "t1", "values": {"id": 20, "name": "q"}, "returning": true}
+		]`)
+		gotwant.Test(t, rec.Body.String(), `{"result": [`+
+			`{"index":0,"method":"POST","table":"t1","rowsAffected":1,"records":[{"id":20,"name":"q"}]}]}`)
+	})
+}
+
+func TestSQLiteKeyset(t *testing.T) {
+	conn := openSQLite(t,
+		`CREATE TABLE t1 (id INTEGER PRIMARY KEY, name TEXT)`,
+		`INSERT INTO t1 VALUES (1, 'a'), (2, 'b'), (3, 'c')`,
+	)
+	r := footrest.New(conn, "sqlite", nil, true, nil)
+
+	rec := serve(r, http.MethodGet, "/t1?select=id&order=id&rows=2&AFTER=", "")
+	gotwant.Test(t, rec.Code, http.StatusOK)
+	gotwant.Test(t, rec.Body.String(), `{"result": [{"id":1},{"id":2}]}`)
+	next := rec.Header().Get(footrest.HeaderNextCursor)
+	gotwant.Test(t, next != "", true)
+
+	rec = serve(r, http.MethodGet, "/t1?select=id&order=id&rows=2&After="+url.QueryEscape(next), "")
+	gotwant.Test(t, rec.Code, http.StatusOK)
+	gotwant.Test(t, rec.Body.String(), `{"result": [{"id":3}]}`)
+	gotwant.Test(t, rec.Header().Get(footrest.HeaderNextCursor), "")
+
+	rec = serve(r, http.MethodGet, "/t1?select=id&order=id&rows=2&page=2&after=", "")
+	gotwant.Test(t, rec.Code, http.StatusBadRequest)
+	gotwant.Test(t, strings.Contains(rec.Body.String(), "cursor can not be used with page"), true)
+}
+
+func TestSQLiteBulkGet(t *testing.T) {
+	conn := openSQLite(t,
+		`CREATE TABLE t1 (id INTEGER PRIMARY KEY, name TEXT)`,
+		`INSERT INTO t1 VALUES (1, 'a'), (2, 'b'), (3, 'c')`,
+	)
+	r := footrest.New(conn, "sqlite", nil, true, nil)
+
+	body := `[
+		{"table": "t1", "where": {"id": "1"}, "select": ["name"]},
+		{"table": "t1", "where": {"id": "2"}, "select": ["name"]},
+		{"table": "t1", "where": {"id": "3"}, "select": ["name"]}
+	]`
+	want := `[` +
+		`{"table":"t1","records":[{"name":"a"}]},` +
+		`{"table":"t1","records":[{"name":"b"}]},` +
+		`{"table":"t1","records":[{"name":"c"}]}]`
+
+	t.Run("Snapshot", func(t *testing.T) {
+		rec := serve(r, http.MethodPost, "/!bulkget?isolation=serializable", body)
+		gotwant.Test(t, rec.Code, http.StatusOK)
+		gotwant.Test(t, rec.Body.String(), want)
+
+		rec = serve(r, http.MethodPost, "/!bulkget?isolation=no_such_level", body)
+		gotwant.Test(t, rec.Code, http.StatusBadRequest)
+	})
+
+	t.Run("SetReadOnly", func(t *testing.T) {
+		// the statement runs first in the transaction, which sqlite does not know
+		d := *footrest.GetDialect("sqlite")
+		d.ReadOnlyTx = false
+		d.SetReadOnly = "SET TRANSACTION READ ONLY"
+		r := footrest.NewDialect(conn, &d, nil, true, nil)
+
+		rec := serve(r, http.MethodPost, "/!bulkget?isolation=serializable", body)
+		gotwant.Test(t, rec.Code, http.StatusBadRequest)
+		gotwant.Test(t, strings.Contains(rec.Body.String(), "syntax error"), true)
+
+		rec = serve(r, http.MethodPost, "/!bulkget", body)
+		gotwant.Test(t, rec.Body.String(), want)
+	})
+
+	t.Run("Parallel", func(t *testing.T) {
+		rec := serve(r, http.MethodPost, "/!bulkget?parallel=2", body)
+		gotwant.Test(t, rec.Code, http.StatusOK)
+		gotwant.Test(t, rec.Body.String(), want)
+
+		rec = serve(r, http.MethodPost, "/!bulkget?parallel=2", `[
+			{"table": "t1", "where": {"id": "1"}},
+			{"table": "no_such_table"},
+			{"table": "t1", "where": {"id": "3"}}
+		]`)
+		gotwant.Test(t, rec.Code, http.StatusBadRequest)
+	})
+}
+
+func TestSQLiteEncoding(t *testing.T) {
+	conn := openSQLite(t,
+		`CREATE TABLE t1 (id INTEGER PRIMARY KEY, name TEXT)`,
+		`CREATE TABLE k (code TEXT PRIMARY KEY)`,
+		`CREATE TABLE v (code TEXT REFERENCES k(code), n INTEGER)`,
+	)
+	r := footrest.New(conn, "sqlite", japanese.ShiftJIS, true, nil)
+
+	rec := serve(r, http.MethodPost, "/t1", `[{"id":1,"name":"あ"},{"id":2,"name":"い"}]`)
+	gotwant.Test(t, rec.Code, http.StatusOK)
+
+	var name []byte
+	gotwant.TestError(t, conn.QueryRow(`SELECT CAST(name AS BLOB) FROM t1 WHERE id = 1`).Scan(&name), nil)
+	gotwant.Test(t, name, []byte{0x82, 0xa0})
+
+	// args of where are encoded once
+	rec = serve(r, http.MethodGet, "/t1?select=id&where="+url.QueryEscape("(= .name 'あ')"), "")
+	gotwant.Test(t, rec.Body.String(), `{"result": [{"id":1}]}`)
+
+	rec = serve(r, http.MethodPut, "/t1?where="+url.QueryEscape("(= .name 'い')"), `{"name":"う"}`)
+	gotwant.Test(t, rec.Code, http.StatusOK)
+	gotwant.TestError(t, conn.QueryRow(`SELECT CAST(name AS BLOB) FROM t1 WHERE id = 2`).Scan(&name), nil)
+	gotwant.Test(t, name, []byte{0x82, 0xa4})
+
+	rec = serve(r, http.MethodPost, "/!bulkget?isolation=serializable", `[{"table":"t1","where":{"name":"'う'"},"select":["id"]}]`)
+	gotwant.Test(t, rec.Body.String(), `[{"table":"t1","records":[{"id":2}]}]`)
+	// args of keyset cursors and embeds are encoded too
+	rec = serve(r, http.MethodPost, "/k", `[{"code":"あ"},{"code":"い"}]`)
+	gotwant.Test(t, rec.Code, http.StatusOK)
+	rec = serve(r, http.MethodPost, "/v", `[{"code":"あ","n":1},{"code":"い","n":2}]`)
+	gotwant.Test(t, rec.Code, http.StatusOK)
+
+	rec = serve(r, http.MethodGet, "/k?order=code&rows=1&after=", "")
+	gotwant.Test(t, rec.Body.String(), `{"result": [{"code":"あ"}]}`)
+	rec = serve(r, http.MethodGet, "/k?order=code&rows=1&after="+url.QueryEscape(rec.Header().Get(footrest.HeaderNextCursor)), "")
+	gotwant.Test(t, rec.Body.String(), `{"result": [{"code":"い"}]}`)
+
+	rec = serve(r, http.MethodGet, "/k?order=code&embed=v&v.select=n", "")
+	gotwant.Test(t, rec.Body.String(), `{"result": [{"code":"あ","v":[{"n":1}]},{"code":"い","v":[{"n":2}]}]}`)
+}
+
+func TestSQLiteTx(t *testing.T) {
+	conn := openSQLite(t,
+		`CREATE TABLE t1 (id INTEGER PRIMARY KEY, name TEXT)`,
+		`INSERT INTO t1 VALUES (1, 'a')`,
+	)
+
+	t.Run("Isolation", func(t *testing.T) {
+		r := footrest.New(conn, "sqlite", nil, true, nil)
+
+		rec := serve(r, http.MethodPut, "/t1?where=(=%20.id%20%231)", `{"name":"b"}`, footrest.HeaderIsolation, "serializable")
+		gotwant.Test(t, rec.Code, http.StatusOK)
+
+		rec = serve(r, http.MethodPut, "/t1?where=(=%20.id%20%231)", `{"name":"c"}`, footrest.HeaderIsolation, "snapshot")
+		gotwant.Test(t, rec.Code, http.StatusBadRequest)
+		gotwant.Test(t, rec.Body.String(), `{"error": "isolation level \"snapshot\" is not allowed"}`)
+
+		rec = serve(r, http.MethodPut, "/t1?where=(=%20.id%20%231)", `{"name":"c"}`, footrest.HeaderIsolation, "no_such_level")
+		gotwant.Test(t, rec.Code, http.StatusBadRequest)
+	})
+
+	t.Run("Retry", func(t *testing.T) {
+		var calls int
+		retryable := true
+		d := *footrest.GetDialect("sqlite")
+		d.IsRetryable = func(err error) bool {
+			calls++
+			return retryable && strings.Contains(err.Error(), "UNIQUE")
+		}
+
+		config := footrest.DefaultConfig()
+		config.Tx.Retries = 2
+		config.Tx.RetryBackoff = 1
+		r := footrest.NewDialect(conn, &d, nil, true, config)
+
+		// retried up to Tx.Retries times
+		rec := serve(r, http.MethodPost, "/t1", `{"id":1,"name":"dup"}`)
+		gotwant.Test(t, rec.Code, http.StatusBadRequest)
+		gotwant.Test(t, strings.Contains(rec.Body.String(), "UNIQUE"), true)
+		gotwant.Test(t, calls, 2)
+
+		// a non-retryable error is returned as is
+		calls = 0
+		retryable = false
+		rec = serve(r, http.MethodPost, "/t1", `{"id":1,"name":"dup"}`)
+		gotwant.Test(t, rec.Code, http.StatusBadRequest)
+		gotwant.Test(t, strings.Contains(rec.Body.String(), "UNIQUE"), true)
+		gotwant.Test(t, calls, 1)
+
+		rec = serve(r, http.MethodPost, "/t1", `{"id":2,"name":"b"}`)
+		gotwant.Test(t, rec.Code, http.StatusOK)
+		gotwant.Test(t, calls, 1)
+	})
+}
+
+func TestSQLiteOpenTx(t *testing.T) {
+	// a file for another connection to see the outside of the transaction
+	conn, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "tx.db"))
+	gotwant.TestError(t, err, nil)
+	t.Cleanup(func() { conn.Close() })
+	_, err = conn.Exec(`CREATE TABLE t1 (id INTEGER PRIMARY KEY, name TEXT)`)
+	gotwant.TestError(t, err, nil)
+	_, err = conn.Exec(`INSERT INTO t1 VALUES (1, 'a')`)
+	gotwant.TestError(t, err, nil)
+
+	config := footrest.DefaultConfig()
+	config.Tx.IdleTimeout = 60_000
+	r := footrest.New(conn, "sqlite", nil, true, config)
+
+	name := func() string {
+		var s string
+		gotwant.TestError(t, conn.QueryRow(`SELECT name FROM t1 WHERE id = 1`).Scan(&s), nil)
+		return s
+	}
+	begin := func(r *footrest.FootREST) string {
+		rec := serve(r, http.MethodPost, "/!tx", "")
+		gotwant.Test(t, rec.Code, http.StatusOK)
+		var body struct {
+			Result string `json:"result"`
+		}
+		gotwant.TestError(t, json.Unmarshal(rec.Body.Bytes(), &body), nil)
+		return body.Result
+	}
+
+	t.Run("Commit", func(t *testing.T) {
+		id := begin(r)
+
+		rec := serve(r, http.MethodPut, "/t1?id=1", `{"name":"b"}`, footrest.HeaderTx, id)
+		gotwant.Test(t, rec.Code, http.StatusOK)
+		rec = serve(r, http.MethodGet, "/t1?select=name&id=1", "", footrest.HeaderTx, id)
+		gotwant.Test(t, rec.Body.String(), `{"result": [{"name":"b"}]}`)
+		gotwant.Test(t, name(), "a")
+
+		rec = serve(r, http.MethodPost, "/!tx/"+id+"/commit", "")
+		gotwant.Test(t, rec.Code, http.StatusOK)
+		gotwant.Test(t, name(), "b")
+
+		rec = serve(r, http.MethodGet, "/t1?id=1", "", footrest.HeaderTx, id)
+		gotwant.Test(t, rec.Code, http.StatusBadRequest)
+		gotwant.Test(t, rec.Body.String(), `{"error": "transaction \"`+id+`\" is not open"}`)
+		rec = serve(r, http.MethodPost, "/!tx/"+id+"/commit", "")
+		gotwant.Test(t, rec.Code, http.StatusBadRequest)
+	})
+
+	t.Run("Rollback", func(t *testing.T) {
+		id := begin(r)
+
+		rec := serve(r, http.MethodPut, "/t1?id=1", `{"name":"c"}`, footrest.HeaderTx, id)
+		gotwant.Test(t, rec.Code, http.StatusOK)
+
+		rec = serve(r, http.MethodPost, "/!tx/"+id+"/undo", "")
+		gotwant.Test(t, rec.Code, http.StatusBadRequest)
+
+		rec = serve(r, http.MethodPost, "/!tx/"+id+"/rollback", "")
+		gotwant.Test(t, rec.Code, http.StatusOK)
+		gotwant.Test(t, name(), "b")
+	})
+
+	t.Run("Expiry", func(t *testing.T) {
+		config := footrest.DefaultConfig()
+		config.Tx.IdleTimeout = 10
+		r := footrest.New(conn, "sqlite", nil, true, config)
+
+		id := begin(r)
+		rec := serve(r, http.MethodPut, "/t1?id=1", `{"name":"d"}`, footrest.HeaderTx, id)
+		gotwant.Test(t, rec.Code, http.StatusOK)
+
+		for i := 0; i < 100 && rec.Code == http.StatusOK; i++ {
+			time.Sleep(10 * time.Millisecond)
+			rec = serve(r, http.MethodGet, "/t1?id=1", "", footrest.HeaderTx, id)
+		}
+		gotwant.Test(t, rec.Code, http.StatusBadRequest)
+		gotwant.Test(t, name(), "b")
+	})
+
+	t.Run("MaxOpen", func(t *testing.T) {
+		config := footrest.DefaultConfig()
+		config.Tx.MaxOpen = 1
+		r := footrest.New(conn, "sqlite", nil, true, config)
+
+		id := begin(r)
+		rec := serve(r, http.MethodPost, "/!tx", "")
+		gotwant.Test(t, rec.Code, http.StatusBadRequest)
+		gotwant.Test(t, rec.Body.String(), `{"error": "too many open transactions (max 1)"}`)
+
+		rec = serve(r, http.MethodPost, "/!tx/"+id+"/rollback", "")
+		gotwant.Test(t, rec.Code, http.StatusOK)
+	})
+
+	t.Run("BeginWaiting", func(t *testing.T) {
+		// a begin waiting for a connection does not block the commit freeing it
+		conn, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "wait.db"))
+		gotwant.TestError(t, err, nil)
+		t.Cleanup(func() { conn.Close() })
+		conn.SetMaxOpenConns(1)
+
+		r := footrest.New(conn, "sqlite", nil, true, config)
+
+		id := begin(r)
+
+		opened := make(chan string)
+		go func() {
+			id, _ := r.OpenTx(context.Background())
+			opened <- id
+		}()
+		for conn.Stats().WaitCount == 0 {
+			time.Sleep(time.Millisecond)
+		}
+
+		committed := make(chan error)
+		go func() {
+			committed <- r.CommitTx(id)
+		}()
+		select {
+		case err := <-committed:
+			gotwant.TestError(t, err, nil)
+		case <-time.After(5 * time.Second):
+			t.Fatal("commit is blocked by the waiting begin")
+		}
+
+		id = <-opened
+		gotwant.Test(t, id != "", true)
+		gotwant.TestError(t, r.RollbackTx(id), nil)
+	})
+}
+
+func TestSQLitePostgREST(t *testing.T) {
+	conn := openSQLite(t,
+		`CREATE TABLE t1 (id INTEGER PRIMARY KEY, name TEXT, age INTEGER, flag INTEGER)`,
+		`INSERT INTO t1 VALUES (1, 'a', 10, NULL), (2, 'b,c', 20, 1), (3, 'O''Brien', 30, 0), (4, 'bd', 40, 1)`,
+		// no affinity, a text is not converted into a number
+		`CREATE TABLE t2 (id INTEGER PRIMARY KEY, v)`,
+		`INSERT INTO t2 VALUES (1, -2.5), (2, 1), (3, -3), (4, '007')`,
+	)
+	config := footrest.DefaultConfig()
+	config.Syntax = footrest.SyntaxPostgREST
+	r := footrest.New(conn, "sqlite", nil, true, config)
+
+	t.Run("Filter", func(t *testing.T) {
+		for _, c := range []struct {
+			query, want string
+		}{
+			{query: `age=gte.30`, want: `[{"id":3},{"id":4}]`},
+			{query: `age=not.gte.30`, want: `[{"id":1},{"id":2}]`},
+			{query: `name=eq.a&age=lt.20`, want: `[{"id":1}]`},
+			{query: `name=neq.a&age=lte.20`, want: `[{"id":2}]`},
+			{query: `name=like.b*`, want: `[{"id":2},{"id":4}]`},
+			{query: `name=ilike.B*`, want: `[{"id":2},{"id":4}]`},
+			{query: `flag=is.null`, want: `[{"id":1}]`},
+			{query: `flag=not.is.null`, want: `[{"id":2},{"id":3},{"id":4}]`},
+			{query: `id=in.(1,3)`, want: `[{"id":1},{"id":3}]`},
+			{query: `name=in.("b,c",bd)`, want: `[{"id":2},{"id":4}]`},
+			{query: `name=eq."b,c"`, want: `[{"id":2}]`},
+			{query: `name=eq.O'Brien`, want: `[{"id":3}]`},
+			{query: `or=(name.eq.a,age.gt.30)`, want: `[{"id":1},{"id":4}]`},
+			{query: `or=(name.eq.a,and(age.gt.10,age.lt.30))`, want: `[{"id":1},{"id":2}]`},
+			{query: `not.or=(name.eq.a,and(age.gt.10,not.and(age.lt.30)))`, want: `[{"id":2}]`},
+			{query: `and=(age.gt.10,or(name.eq."b,c",id.in.(3,4)))`, want: `[{"id":2},{"id":3},{"id":4}]`},
+			{query: `order=age.desc&limit=2&offset=1`, want: `[{"id":3},{"id":2}]`},
+		} {
+			rec := serve(r, http.MethodGet, "/t1?select=id&"+strings.NewReplacer(" ", "%20", `"`, "%22", "*", "%2A").Replace(c.query), "")
+			gotwant.Test(t, rec.Body.String(), `{"result": `+c.want+`}`, gotwant.Desc(c.query))
+		}
+
+		// numbers of any sign
+		for _, c := range []struct {
+			query, want string
+		}{
+			{query: `v=gt.-1.5&v=lt.100`, want: `[{"id":2}]`},
+			{query: `v=lt.-2.75`, want: `[{"id":3}]`},
+			{query: `v=eq.-3`, want: `[{"id":3}]`},
+			{query: `v=eq.-2.5`, want: `[{"id":1}]`},
+			{query: `v=eq.007`, want: `[{"id":4}]`},
+			{query: `v=eq.inf`, want: `[]`},
+		} {
+			rec := serve(r, http.MethodGet, "/t2?select=id&"+c.query, "")
+			gotwant.Test(t, rec.Body.String(), `{"result": `+c.want+`}`, gotwant.Desc(c.query))
+		}
+
+		for _, c := range []struct {
+			query, err string
+		}{
+			{query: `age=between.1`, err: `operator \"between\" is not supported`},
+			{query: `age=10`, err: `invalid filter \"10\"`},
+			{query: `id=in.1,2`, err: `in needs (values)`},
+			{query: `or=name.eq.a`, err: `or needs (conditions)`},
+			{query: `or=(name)`, err: `invalid condition \"name\"`},
+			{query: `flag=is.maybe`, err: `invalid value of is`},
+			{query: `limit=x`, err: `limit`},
+		} {
+			rec := serve(r, http.MethodGet, "/t1?"+c.query, "")
+			gotwant.Test(t, rec.Code, http.StatusBadRequest, gotwant.Desc(c.query))
+			gotwant.Test(t, strings.Contains(rec.Body.String(), c.err), true, gotwant.Desc(c.query+": "+rec.Body.String()))
+		}
+	})
+
+	t.Run("Representation", func(t *testing.T) {
+		prefer := []string{footrest.HeaderPrefer, "return=representation"}
+
+		rec := serve(r, http.MethodPost, "/t1", `{"id":5,"name":"e","age":50}`, prefer...)
+		gotwant.Test(t, rec.Body.String(), `{"result": [{"id":5,"name":"e","age":50,"flag":null}]}`)
+
+		// the updated row is read back by its key, not by the where
+		rec = serve(r, http.MethodPut, "/t1?name=eq.e", `{"name":"f"}`, prefer...)
+		gotwant.Test(t, rec.Body.String(), `{"result": [{"id":5,"name":"f","age":50,"flag":null}]}`)
+		rec = serve(r, http.MethodPut, "/t1?id=eq.5", `{"id":6}`, prefer...)
+		gotwant.Test(t, rec.Body.String(), `{"result": [{"id":6,"name":"f","age":50,"flag":null}]}`)
+
+		// updated, not inserted
+		rec = serve(r, http.MethodPut, "/t1?name=eq.f&upsert=1", `{"name":"g"}`, prefer...)
+		gotwant.Test(t, rec.Body.String(), `{"result": [{"id":6,"name":"g","age":50,"flag":null}]}`)
+		// inserted
+		rec = serve(r, http.MethodPut, "/t1?id=eq.7&upsert=1", `{"id":7,"name":"h"}`, prefer...)
+		gotwant.Test(t, rec.Body.String(), `{"result": [{"id":7,"name":"h","age":null,"flag":null}]}`)
+
+		rec = serve(r, http.MethodDelete, "/t1?id=in.(6,7)", "", prefer...)
+		gotwant.Test(t, rec.Body.String(), `{"result": [{"id":6,"name":"g","age":50,"flag":null},{"id":7,"name":"h","age":null,"flag":null}]}`)
+
+		var n int
+		gotwant.TestError(t, conn.QueryRow(`SELECT COUNT(*) FROM t1`).Scan(&n), nil)
+		gotwant.Test(t, n, 4)
+	})
+}
+
+func TestSQLiteTextMatch(t *testing.T) {
+	conn := openSQLite(t,
+		`CREATE TABLE t1 (id INTEGER PRIMARY KEY, name TEXT)`,
+		`INSERT INTO t1 VALUES (1, '100%'), (2, '1000'), (3, 'A_b'), (4, 'axb'), (5, 'a\b')`,
+	)
+	r := footrest.New(conn, "sqlite", nil, true, footrest.DefaultConfig())
+
+	for _, c := range []struct {
+		where, want string
+	}{
+		{where: `(icontains .name '0%')`, want: `[{"id":1}]`},
+		{where: `(icontains .name 'a_')`, want: `[{"id":3}]`},
+		{where: `(icontains .name '\')`, want: `[{"id":5}]`},
+		{where: `(startswith .name 'a_')`, want: `[{"id":3}]`},
+		{where: `(startswith .name '_')`, want: `[]`},
+		{where: `(ilike .name 'a_b')`, want: `[{"id":3},{"id":4},{"id":5}]`},
+	} {
+		rec := serve(r, http.MethodGet, "/t1?select=id&order=id&where="+url.QueryEscape(c.where), "")
+		gotwant.Test(t, rec.Body.String(), `{"result": `+c.want+`}`, gotwant.Desc(c.where))
+	}
+}
+
+func TestSQLiteOData(t *testing.T) {
+	conn := openSQLite(t,
+		`CREATE TABLE t1 (id INTEGER PRIMARY KEY, name TEXT)`,
+		`INSERT INTO t1 VALUES (1, '100%'), (2, '1000'), (3, 'a_b'), (4, 'axb'), (5, 'O''Brien')`,
+	)
+	r := footrest.New(conn, "sqlite", nil, true, footrest.DefaultConfig())
+
+	get := func(filter string) *httptest.ResponseRecorder {
+		return serve(r, http.MethodGet, "/odata/t1?$select=id&$filter="+url.QueryEscape(filter), "")
+	}
+
+	for _, c := range []struct {
+		filter, want string
+	}{
+		{filter: `contains(name,'0%')`, want: `[{"id":1}]`},
+		{filter: `endswith(name,'%')`, want: `[{"id":1}]`},
+		{filter: `startswith(name,'a_')`, want: `[{"id":3}]`},
+		{filter: `contains(name,'''')`, want: `[{"id":5}]`},
+		{filter: `name eq 'O''Brien'`, want: `[{"id":5}]`},
+	} {
+		rec := get(c.filter)
+		gotwant.Test(t, rec.Code, http.StatusOK, gotwant.Desc(c.filter))
+		gotwant.Test(t, rec.Body.String(), `{"@odata.context":"$metadata#t1","value":`+c.want+`}`, gotwant.Desc(c.filter))
+	}
+
+	// errors of requests
+	gotwant.Test(t, get(`name eq`).Code, http.StatusBadRequest)
+	gotwant.Test(t, get(`unknown eq 1`).Code, http.StatusBadRequest)
+	gotwant.Test(t, serve(r, http.MethodGet, "/odata/t1/$count?$filter="+url.QueryEscape(`unknown eq 1`), "").Code, http.StatusBadRequest)
+
+	// errors of the server
+	_, err := conn.Exec(`DROP TABLE t1`)
+	gotwant.TestError(t, err, nil)
+	rec := get(`id eq 1`)
+	gotwant.Test(t, rec.Code, http.StatusInternalServerError)
+	gotwant.Test(t, strings.Contains(rec.Body.String(), `"code":"500"`), true)
+	gotwant.Test(t, serve(r, http.MethodGet, "/odata/t1/$count", "").Code, http.StatusInternalServerError)
+}
@@ -0,0 +1,54 @@
+package footrest_test
+
+import (
+	"database/sql"
+	"net/http"
+	"testing"
+
+	"github.com/shu-go/gotwant"
+
+	"github.com/shu-go/footrest/footrest"
+
+	_ "github.com/shu-go/footrest/footrest/dialect/sqlserver"
+)
+
+func TestSQLServerDialect(t *testing.T) {
+	r := footrest.New(nil, "sqlserver", nil, false, nil)
+
+	w, args, err := r.BuildGetStmt("users", nil, `(AND (ILIKE .NAME 'a%') (ICONTAINS .NOTE 'b'))`, nil, 0, 0)
+	gotwant.TestError(t, err, nil)
+	gotwant.Test(t, w, `SELECT * FROM users WHERE (UPPER(NAME) LIKE UPPER(@arg0)) AND (UPPER(NOTE) LIKE '%' + UPPER(REPLACE(REPLACE(REPLACE(REPLACE(@arg1, '\', '\\'), '%', '\%'), '_', '\_'), '[', '\[')) + '%' ESCAPE '\')`)
+	gotwant.Test(t, args, []interface{}{sql.NamedArg{Name: "arg0", Value: "a%"}, sql.NamedArg{Name: "arg1", Value: "b"}})
+}
+
+func TestSQLServerNamedQuery(t *testing.T) {
+	config := footrest.DefaultConfig()
+	config.Queries = map[string]footrest.QueryConfig{
+		"plain": {SQL: "SELECT id, 'order by' AS [order] FROM t1 WHERE id IN (SELECT TOP 1 id FROM t2 ORDER BY id)"},
+		"cte":   {SQL: "WITH c AS (SELECT id FROM t1) SELECT id FROM c"},
+		"order": {SQL: "SELECT id FROM t1 -- top\nORDER BY id"},
+		"top":   {SQL: "SELECT TOP 10 id FROM t1 ORDER BY id"},
+	}
+	r := footrest.New(nil, "sqlserver", nil, false, config)
+
+	w, _, err := r.BuildNamedQueryStmt("plain", nil, footrest.Columns("id"), 10, 2)
+	gotwant.TestError(t, err, nil)
+	gotwant.Test(t, w, `SELECT * FROM (SELECT id, 'order by' AS [order] FROM t1 WHERE id IN (SELECT TOP 1 id FROM t2 ORDER BY id)) q ORDER BY id OFFSET 10 ROWS FETCH FIRST 10 ROWS ONLY`)
+
+	w, _, err = r.BuildNamedQueryStmt("top", nil, footrest.Columns("-id"), 0, 0)
+	gotwant.TestError(t, err, nil)
+	gotwant.Test(t, w, `SELECT * FROM (SELECT TOP 10 id FROM t1 ORDER BY id) q ORDER BY id DESC`)
+
+	// not wrapped without order nor pagination
+	w, _, err = r.BuildNamedQueryStmt("cte", nil, nil, 0, 0)
+	gotwant.TestError(t, err, nil)
+	gotwant.Test(t, w, `WITH c AS (SELECT id FROM t1) SELECT id FROM c`)
+
+	_, _, err = r.BuildNamedQueryStmt("cte", nil, footrest.Columns("id"), 0, 0)
+	gotwant.TestError(t, err, "WITH can not be a subquery")
+	_, _, err = r.BuildNamedQueryStmt("order", nil, nil, 10, 1)
+	gotwant.TestError(t, err, "ORDER BY without TOP or OFFSET")
+
+	rec := serve(r, http.MethodGet, "/!query/cte?order=id", "")
+	gotwant.Test(t, rec.Code, http.StatusBadRequest)
+}
@@ -1,7 +1,11 @@
 package footrest_test
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"testing"
+	"time"
 
 	"github.com/shu-go/footrest/footrest"
 
@@ -21,6 +25,128 @@ func TestBuildGetStmt(t *testing.T) {
 	gotwant.Test(t, args, []any{1, "hoge%hoge"})
 }
 
+func TestBuildGetStmtIn(t *testing.T) {
+	config := footrest.DefaultConfig()
+	config.Tables = []string{"my_table", "other"}
+	r := footrest.New(nil, "", nil, false, config)
+
+	stmt, args, err := r.BuildGetStmt("my_table", nil, "(and (in .a #1 #2 #3) (notin .b 'x' 'y'))", nil, 0, 0)
+	gotwant.TestError(t, err, nil)
+	gotwant.Test(t, stmt, `SELECT * FROM my_table WHERE (a IN (?, ?, ?)) AND (b NOT IN (?, ?))`)
+	gotwant.Test(t, args, []any{1, 2, 3, "x", "y"})
+
+	stmt, args, err = r.BuildGetStmt("my_table", nil, "(and (= .c #0) (in .a (select other .id (where (> .n #5)))))", nil, 0, 0)
+	gotwant.TestError(t, err, nil)
+	gotwant.Test(t, stmt, `SELECT * FROM my_table WHERE (c = ?) AND (a IN (SELECT id FROM other WHERE n > ?))`)
+	gotwant.Test(t, args, []any{0, 5})
+
+	_, _, err = r.BuildGetStmt("my_table", nil, "(in .a (select secret .id))", nil, 0, 0)
+	gotwant.TestError(t, err, "not exposed")
+
+	_, _, err = r.BuildGetStmt("secret", nil, "", nil, 0, 0)
+	gotwant.TestError(t, err, "not exposed")
+}
+
+func TestBuildGetStmtExpr(t *testing.T) {
+	r := footrest.New(nil, "", nil, false, nil)
+
+	stmt, args, err := r.BuildGetStmt("my_table", footrest.Columns("a", "l=(lower .b)", "s=(+ .c .d #1)"), "(= (coalesce .e 'x') 'y')", footrest.Columns("-l", "(length .b)"), 0, 0)
+	gotwant.TestError(t, err, nil)
+	gotwant.Test(t, stmt, `SELECT a, LOWER(b) AS l, (c + d + ?) AS s FROM my_table WHERE COALESCE(e, ?) = ? ORDER BY l DESC, LENGTH(b)`)
+	gotwant.Test(t, args, []any{1, "x", "y"})
+
+	_, _, err = r.BuildGetStmt("my_table", nil, "(= (lower #1) 'a')", nil, 0, 0)
+	gotwant.TestError(t, err, "text is expected")
+
+	_, _, err = r.BuildGetStmt("my_table", nil, "(= (+ .a 'x') #1)", nil, 0, 0)
+	gotwant.TestError(t, err, "number is expected")
+
+	_, _, err = r.BuildGetStmt("my_table", nil, "(= (unknown .a) #1)", nil, 0, 0)
+	gotwant.TestError(t, err, "not registered")
+}
+
+func TestBuildGetStmtTextMatch(t *testing.T) {
+	r := footrest.New(nil, "", nil, false, nil)
+
+	stmt, args, err := r.BuildGetStmt("my_table", nil, "(and (ilike .a 'x%') (icontains .b 'y') (startswith .c 'z'))", nil, 0, 0)
+	gotwant.TestError(t, err, nil)
+	gotwant.Test(t, stmt, `SELECT * FROM my_table WHERE (UPPER(a) LIKE UPPER(?)) AND (UPPER(b) LIKE '%' || UPPER(REPLACE(REPLACE(REPLACE(?, '\', '\\'), '%', '\%'), '_', '\_')) || '%' ESCAPE '\') AND (c LIKE REPLACE(REPLACE(REPLACE(?, '\', '\\'), '%', '\%'), '_', '\_') || '%' ESCAPE '\')`)
+	gotwant.Test(t, args, []any{"x%", "y", "z"})
+}
+
+func TestBuildGetStmtOpts(t *testing.T) {
+	r := footrest.New(nil, "", nil, false, nil)
+
+	stmt, args, err := r.BuildGetStmtOpts("my_table", nil, "(= .d #1)", footrest.Columns("a"), 10, 2, footrest.GetOptions{Count: true})
+	gotwant.TestError(t, err, nil)
+	gotwant.Test(t, stmt, `SELECT COUNT(*) AS COUNT FROM my_table WHERE d = ?`)
+	gotwant.Test(t, args, []any{1})
+
+	stmt, _, err = r.BuildGetStmtOpts("my_table", nil, "", footrest.Columns("a"), 0, 0, footrest.GetOptions{Count: true, Group: footrest.Columns("a", "b")})
+	gotwant.TestError(t, err, nil)
+	gotwant.Test(t, stmt, `SELECT a, b, COUNT(*) AS COUNT FROM my_table GROUP BY a, b ORDER BY a`)
+
+	_, _, err = r.BuildGetStmtOpts("my_table", footrest.Columns("c"), "", nil, 0, 0, footrest.GetOptions{Group: footrest.Columns("a")})
+	gotwant.TestError(t, err, "not in group")
+
+	// a computed column is only of group columns
+	stmt, _, err = r.BuildGetStmtOpts("my_table", footrest.Columns("a", "x=(LOWER .A)"), "", nil, 0, 0, footrest.GetOptions{Count: true, Group: footrest.Columns("A")})
+	gotwant.TestError(t, err, nil)
+	gotwant.Test(t, stmt, `SELECT a, LOWER(A) AS x, COUNT(*) AS COUNT FROM my_table GROUP BY A`)
+	_, _, err = r.BuildGetStmtOpts("my_table", footrest.Columns("a", "x=(LOWER .c)"), "", nil, 0, 0, footrest.GetOptions{Group: footrest.Columns("a")})
+	gotwant.TestError(t, err, `column "c" of "x=(LOWER .c)" is not in group`)
+
+	stmt, args, err = r.BuildGetStmtOpts("my_table", nil, "(= .d #1)", footrest.Columns("a", "-b"), 10, 0, footrest.GetOptions{After: []any{5, "x"}})
+	gotwant.TestError(t, err, nil)
+	gotwant.Test(t, stmt, `SELECT * FROM my_table WHERE (d = ?) AND ((a > ?) OR (a = ? AND b < ?)) ORDER BY a, b DESC LIMIT 10 OFFSET 0`)
+	gotwant.Test(t, args, []any{1, 5, 5, "x"})
+
+	stmt, _, err = r.BuildGetStmtOpts("my_table", nil, "", footrest.Columns("a"), 0, 0, footrest.GetOptions{Limit: 10, Offset: 20})
+	gotwant.TestError(t, err, nil)
+	gotwant.Test(t, stmt, `SELECT * FROM my_table ORDER BY a LIMIT 10 OFFSET 20`)
+}
+
+func TestParseODataFilter(t *testing.T) {
+	w, err := footrest.ParseODataFilter("Price le 200 and (contains(Name,'a') or Id in (1,2))")
+	gotwant.TestError(t, err, nil)
+	gotwant.Test(t, w, `(AND (<= .PRICE #200) (OR (LIKEESCAPE .NAME '%a%') (IN .ID #1 #2)))`)
+
+	w, err = footrest.ParseODataFilter("not (Name eq null) and tolower(Name) ne 'x' or Price add 1 gt 2.5")
+	gotwant.TestError(t, err, nil)
+	gotwant.Test(t, w, `(OR (AND (NOT (ISNULL .NAME)) (<> (LOWER .NAME) 'x')) (> (+ .PRICE #1) 2.5))`)
+
+	r := footrest.New(nil, "", nil, false, nil)
+	stmt, args, err := r.BuildGetStmt("my_table", nil, w, nil, 0, 0)
+	gotwant.TestError(t, err, nil)
+	gotwant.Test(t, stmt, `SELECT * FROM my_table WHERE ((NOT (NAME IS NULL)) AND (LOWER(NAME) <> ?)) OR ((PRICE + ?) > ?)`)
+	gotwant.Test(t, args, []any{"x", 1, "2.5"})
+
+	// wildcards of LIKE and quotes are literal
+	w, err = footrest.ParseODataFilter(`contains(Name,'1%_a\b') or startswith(Name,'it''s') or endswith(Name,'a''')`)
+	gotwant.TestError(t, err, nil)
+	gotwant.Test(t, w, `(OR (LIKEESCAPE .NAME '%1\%\_a\\b%') (LIKEESCAPE .NAME 'it''s%') (LIKEESCAPE .NAME '%a'''))`)
+	stmt, args, err = r.BuildGetStmt("my_table", nil, w, nil, 0, 0)
+	gotwant.TestError(t, err, nil)
+	gotwant.Test(t, stmt, `SELECT * FROM my_table WHERE (NAME LIKE ? ESCAPE '\') OR (NAME LIKE ? ESCAPE '\') OR (NAME LIKE ? ESCAPE '\')`)
+	gotwant.Test(t, args, []any{`%1\%\_a\\b%`, "it's%", "%a'"})
+
+	w, err = footrest.ParseODataFilter("Name eq 'O''Brien'")
+	gotwant.TestError(t, err, nil)
+	gotwant.Test(t, w, `(= .NAME 'O''Brien')`)
+	_, args, err = r.BuildGetStmt("my_table", nil, w, nil, 0, 0)
+	gotwant.TestError(t, err, nil)
+	gotwant.Test(t, args, []any{"O'Brien"})
+
+	_, err = footrest.ParseODataFilter("contains(Name,Text)")
+	gotwant.TestError(t, err, "needs a string literal")
+
+	_, err = footrest.ParseODataFilter("Name eq")
+	gotwant.TestError(t, err, "unexpected end")
+
+	_, err = footrest.ParseODataFilter("unknown(Name) eq 1")
+	gotwant.TestError(t, err, "not supported")
+}
+
 func TestBuildPostStmt(t *testing.T) {
 	r := footrest.New(nil, "", nil, false, nil)
 	stmt, args, err := r.BuildPostStmt("my_table", map[string]any{
@@ -43,6 +169,89 @@ func TestBuildPostStmt(t *testing.T) {
 	gotwant.Test(t, args, []any{1, nil, nil, 1})
 }
 
+func TestBuildPostStmts(t *testing.T) {
+	d := footrest.DefaultDialect()
+	d.MaxParams = 5
+
+	r := footrest.NewDialect(nil, &d, nil, false, nil)
+	stmts, argss, err := r.BuildPostStmts("my_table", []map[string]any{
+		{"a": 1, "b": 2},
+		{"a": 3, "b": 4},
+		{"a": 5, "b": 6},
+	})
+	gotwant.TestError(t, err, nil)
+	gotwant.Test(t, stmts, []string{
+		`INSERT INTO my_table (a, b) VALUES (?, ?), (?, ?)`,
+		`INSERT INTO my_table (a, b) VALUES (?, ?)`,
+	})
+	gotwant.Test(t, argss, [][]any{{1, 2, 3, 4}, {5, 6}})
+
+	d1 := footrest.DefaultDialect()
+	d1.MultiRowInsert = nil
+
+	r = footrest.NewDialect(nil, &d1, nil, false, nil)
+	stmts, _, err = r.BuildPostStmts("my_table", []map[string]any{
+		{"a": 1},
+		{"a": 3},
+	})
+	gotwant.TestError(t, err, nil)
+	gotwant.Test(t, stmts, []string{
+		`INSERT INTO my_table (a) VALUES (?)`,
+		`INSERT INTO my_table (a) VALUES (?)`,
+	})
+}
+
+func TestBuildCallStmt(t *testing.T) {
+	config := footrest.DefaultConfig()
+	config.Procedures = []string{"my_proc", "my_func"}
+	r := footrest.New(nil, "", nil, false, config)
+
+	stmt, args, _, err := r.BuildCallStmt("my_proc", []footrest.SPParam{
+		{Name: "a", Value: 1},
+		{Name: "b", Direction: "out", Type: "int"},
+	})
+	gotwant.TestError(t, err, nil)
+	gotwant.Test(t, stmt, `CALL my_proc(?, ?)`)
+	gotwant.Test(t, args, []any{1, nil})
+
+	stmt, args, _, err = r.BuildCallStmt("my_func", []footrest.SPParam{
+		{Name: "a", Value: 1},
+		{Direction: "return", Type: "int"},
+	})
+	gotwant.TestError(t, err, nil)
+	gotwant.Test(t, stmt, `SELECT my_func(?)`)
+	gotwant.Test(t, args, []any{1})
+
+	_, _, _, err = r.BuildCallStmt("other_proc", nil)
+	gotwant.TestError(t, err, "not allowed")
+}
+
+func TestBuildNamedQueryStmt(t *testing.T) {
+	config := footrest.DefaultConfig()
+	config.Queries = map[string]footrest.QueryConfig{
+		"sales": {
+			SQL:    "SELECT s.id, s.amount FROM sales s JOIN items i ON s.item = i.id WHERE s.amount >= {min} AND i.name <> '{min}' AND s.day = {day}",
+			Params: map[string]string{"min": "int", "day": "string"},
+		},
+	}
+	r := footrest.New(nil, "", nil, false, config)
+
+	stmt, args, err := r.BuildNamedQueryStmt("sales", map[string]string{"min": "100", "day": "mon"}, nil, 0, 0)
+	gotwant.TestError(t, err, nil)
+	gotwant.Test(t, stmt, `SELECT s.id, s.amount FROM sales s JOIN items i ON s.item = i.id WHERE s.amount >= ? AND i.name <> '{min}' AND s.day = ?`)
+	gotwant.Test(t, args, []any{int64(100), "mon"})
+
+	stmt, _, err = r.BuildNamedQueryStmt("SALES", map[string]string{"min": "100", "day": "mon"}, footrest.Columns("-amount"), 10, 2)
+	gotwant.TestError(t, err, nil)
+	gotwant.Test(t, stmt, `SELECT * FROM (SELECT s.id, s.amount FROM sales s JOIN items i ON s.item = i.id WHERE s.amount >= ? AND i.name <> '{min}' AND s.day = ?) q ORDER BY amount DESC LIMIT 10 OFFSET 10`)
+
+	_, _, err = r.BuildNamedQueryStmt("sales", map[string]string{"min": "x", "day": "mon"}, nil, 0, 0)
+	gotwant.TestError(t, err, "invalid syntax")
+
+	_, _, err = r.BuildNamedQueryStmt("sales", map[string]string{"min": "1"}, nil, 0, 0)
+	gotwant.TestError(t, err, "missing")
+}
+
 func TestPutStmt(t *testing.T) {
 	r := footrest.New(nil, "", nil, false, nil)
 	stmt, args, err := r.BuildPutStmt("my_table", map[string]any{
@@ -52,6 +261,17 @@ func TestPutStmt(t *testing.T) {
 	gotwant.TestError(t, err, nil)
 	gotwant.Test(t, stmt, `UPDATE my_table SET a = ?, b = ? WHERE (d = ?) AND (e LIKE ?)`, gotwant.Format("%q"))
 	gotwant.Test(t, args, []any{1, "text", 1, "hoge%hoge"})
+
+	config := footrest.DefaultConfig()
+	config.Versions = map[string]string{"my_table": "ver"}
+	r = footrest.New(nil, "", nil, false, config)
+	stmt, args, err = r.BuildPutStmt("my_table", map[string]any{
+		"a":   1,
+		"ver": 100,
+	}, "(= .d #1)")
+	gotwant.TestError(t, err, nil)
+	gotwant.Test(t, stmt, `UPDATE my_table SET a = ?, ver = COALESCE(ver, 0) + 1 WHERE d = ?`)
+	gotwant.Test(t, args, []any{1, 1})
 }
 
 func TestDeleteStmt(t *testing.T) {
@@ -99,3 +319,99 @@ func BenchmarkStmt(b *testing.B) {
 	})
 
 }
+
+type sqlStateError string
+
+func (e sqlStateError) Error() string    { return "error " + string(e) }
+func (e sqlStateError) SQLState() string { return string(e) }
+
+func TestDialectIsRetryable(t *testing.T) {
+	d := footrest.DefaultDialect()
+	gotwant.Test(t, d.IsRetryable(sqlStateError("40001")), true)
+	gotwant.Test(t, d.IsRetryable(fmt.Errorf("wrapped: %w", sqlStateError("40P01"))), true)
+	gotwant.Test(t, d.IsRetryable(sqlStateError("23505")), false)
+	gotwant.Test(t, d.IsRetryable(errors.New("deadlock detected")), true)
+	gotwant.Test(t, d.IsRetryable(errors.New("syntax error")), false)
+}
+
+func TestConfigContextFor(t *testing.T) {
+	timeout := func(c *footrest.Config, name string) time.Duration {
+		ctx, cancel := c.ContextFor(name)
+		defer cancel()
+		deadline, ok := ctx.Deadline()
+		if !ok {
+			return -1
+		}
+		return time.Until(deadline).Round(time.Second)
+	}
+
+	c := footrest.DefaultConfig()
+	gotwant.Test(t, timeout(c, "t1"), 5*time.Second)
+	gotwant.Test(t, timeout(c, "!import"), 10*time.Minute)
+
+	c.Timeouts = map[string]int64{"!IMPORT": 60_000}
+	gotwant.Test(t, timeout(c, "!import"), time.Minute)
+
+	c.Import.Timeout = -1
+	c.Timeouts = nil
+	gotwant.Test(t, timeout(c, "!import"), time.Duration(-1))
+
+	// per table and per route
+	c.Timeouts = map[string]int64{"T1": 30_000, "!bulk": 120_000, "t2": -1}
+	gotwant.Test(t, timeout(c, "t1"), 30*time.Second)
+	gotwant.Test(t, timeout(c, "!BULK"), 2*time.Minute)
+	gotwant.Test(t, timeout(c, "t2"), time.Duration(-1))
+	gotwant.Test(t, timeout(c, "t3"), 5*time.Second)
+	gotwant.Test(t, timeout(c, ""), 5*time.Second)
+}
+
+func TestWhereExpr(t *testing.T) {
+	for _, c := range []struct {
+		json, want, err string
+	}{
+		{json: ``},
+		{json: `null`},
+		{json: `"(>= .id #2)"`, want: `(>= .id #2)`},
+		{json: `[">=", ".id", 2]`, want: `(>= .id #2)`},
+		{json: `{">=": [".id", 2]}`, want: `(>= .id #2)`},
+		{json: `{"not": {"is": [".a", null]}}`, want: `(not (is .a NULL))`},
+		{
+			json: `["and", [">=", ".id", 2], ["like", ".text1", "aaa%"], ["=", ".f", 1.5], ["=", ".b", true]]`,
+			want: `(and (>= .id #2) (like .text1 'aaa%') (= .f 1.5) (= .b TRUE))`,
+		},
+		{
+			json: `{"in": [".id", ["select", "t2", ".id", {"=": [".name", "x"]}]]}`,
+			want: `(in .id (select t2 .id (= .name 'x')))`,
+		},
+		{json: `["=", ".name", "O'Brien"]`, want: `(= .name 'O''Brien')`},
+		{json: `1`, err: "neither"},
+		{json: `[]`, err: "empty expression"},
+		{json: `{"=": [".a", 1], "<": [".b", 2]}`, err: "one operator"},
+		{json: `["= x", ".a", 1]`, err: "not an operator"},
+		{json: `[1, ".a"]`, err: "not an operator"},
+		{json: `["=", ".a b", 1]`, err: "invalid column name"},
+		{json: `["select", "t2 x", ".id"]`, err: "invalid table name"},
+		{json: `["=", ".a", {}]`, err: "one operator"},
+	} {
+		got, err := footrest.WhereExpr(json.RawMessage(c.json))
+		if c.err != "" {
+			gotwant.TestError(t, err, c.err, gotwant.Desc(c.json))
+			continue
+		}
+		gotwant.TestError(t, err, nil, gotwant.Desc(c.json))
+		gotwant.Test(t, got, c.want, gotwant.Desc(c.json))
+	}
+}
+
+func TestBuildWhereQuote(t *testing.T) {
+	r := footrest.New(nil, "", nil, false, nil)
+
+	// '' is ' in a string literal
+	stmt, args, err := r.BuildGetStmt("my_table", nil, `(OR (= .a 'O''Brien') (= .b ''''))`, nil, 0, 0)
+	gotwant.TestError(t, err, nil)
+	gotwant.Test(t, stmt, `SELECT * FROM my_table WHERE (a = ?) OR (b = ?)`)
+	gotwant.Test(t, args, []any{"O'Brien", "'"})
+
+	_, _, err = r.BuildGetStmt("my_table", nil, "(= .a '\x00')", nil, 0, 0)
+	gotwant.TestError(t, err, "NUL")
+}
@@ -0,0 +1,1055 @@
+package footrest
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+// graphqlReq is a body of POST /!graphql.
+type graphqlReq struct {
+	Query         string         `json:"query"`
+	Variables     map[string]any `json:"variables"`
+	OperationName string         `json:"operationName"`
+}
+
+// gqlOperation is an operation of a GraphQL document.
+type gqlOperation struct {
+	Type   string // "query" or "mutation"
+	Name   string
+	Fields []gqlField
+}
+
+// gqlField is a field of a selection set whose arguments are resolved with variables.
+type gqlField struct {
+	Alias  string
+	Name   string
+	Args   map[string]any
+	Fields []gqlField
+}
+
+func (f gqlField) key() string {
+	if f.Alias != "" {
+		return f.Alias
+	}
+	return f.Name
+}
+
+type gqlToken struct {
+	kind  byte // 'n'ame, 'i'nt, 'f'loat, 's'tring, 'p'unctuator
+	value string
+}
+
+// parseGraphQL parses a GraphQL document and returns the operation named operationName.
+//
+// Fragments, directives and subscriptions are not supported.
+// Nor is introspection by __schema or __type; GraphQLSchema returns the schema in SDL instead.
+func parseGraphQL(query string, variables map[string]any, operationName string) (gqlOperation, error) {
+	toks, err := gqlTokenize(query)
+	if err != nil {
+		return gqlOperation{}, err
+	}
+
+	p := gqlParser{toks: toks}
+
+	var ops []gqlOperation
+	for p.pos < len(p.toks) {
+		op, err := p.operation(variables)
+		if err != nil {
+			return gqlOperation{}, err
+		}
+		ops = append(ops, op)
+	}
+
+	if len(ops) == 0 {
+		return gqlOperation{}, errors.New("no operation")
+	}
+	if operationName == "" {
+		if len(ops) > 1 {
+			return gqlOperation{}, errors.New("operationName is required for multiple operations")
+		}
+		return ops[0], nil
+	}
+	for _, op := range ops {
+		if op.Name == operationName {
+			return op, nil
+		}
+	}
+	return gqlOperation{}, errors.Errorf("operation %q is not found", operationName)
+}
+
+func gqlTokenize(s string) ([]gqlToken, error) {
+	s = strings.TrimPrefix(s, "\uFEFF")
+
+	var toks []gqlToken
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ',' || unicode.IsSpace(rune(c)):
+			// commas are insignificant
+			i++
+
+		case c == '#':
+			for i < len(s) && s[i] != '\n' && s[i] != '\r' {
+				i++
+			}
+
+		case strings.HasPrefix(s[i:], "..."):
+			toks = append(toks, gqlToken{kind: 'p', value: "..."})
+			i += 3
+
+		case strings.ContainsRune("!$():=@[]{}|", rune(c)):
+			toks = append(toks, gqlToken{kind: 'p', value: string(c)})
+			i++
+
+		case strings.HasPrefix(s[i:], `"""`):
+			end := strings.Index(s[i+3:], `"""`)
+			if end == -1 {
+				return nil, errors.New("unterminated block string")
+			}
+			toks = append(toks, gqlToken{kind: 's', value: strings.TrimSpace(s[i+3 : i+3+end])})
+			i += 3 + end + 3
+
+		case c == '"':
+			j := i + 1
+			for j < len(s) && s[j] != '"' && s[j] != '\n' {
+				if s[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(s) || s[j] != '"' {
+				return nil, errors.New("unterminated string")
+			}
+			v, err := strconv.Unquote(strings.ReplaceAll(s[i:j+1], `\/`, `/`))
+			if err != nil {
+				return nil, errors.Wrapf(err, "string %s", s[i:j+1])
+			}
+			toks = append(toks, gqlToken{kind: 's', value: v})
+			i = j + 1
+
+		case c == '-' || ('0' <= c && c <= '9'):
+			j := i + 1
+			kind := byte('i')
+			for j < len(s) && (('0' <= s[j] && s[j] <= '9') || strings.ContainsRune(".eE+-", rune(s[j]))) {
+				if !('0' <= s[j] && s[j] <= '9') {
+					kind = 'f'
+				}
+				j++
+			}
+			toks = append(toks, gqlToken{kind: kind, value: s[i:j]})
+			i = j
+
+		case c == '_' || ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z'):
+			j := i + 1
+			for j < len(s) && (s[j] == '_' || ('A' <= s[j] && s[j] <= 'Z') || ('a' <= s[j] && s[j] <= 'z') || ('0' <= s[j] && s[j] <= '9')) {
+				j++
+			}
+			toks = append(toks, gqlToken{kind: 'n', value: s[i:j]})
+			i = j
+
+		default:
+			return nil, errors.Errorf("unexpected %q", string(c))
+		}
+	}
+	return toks, nil
+}
+
+type gqlParser struct {
+	toks []gqlToken
+	pos  int
+	vars map[string]any
+}
+
+func (p *gqlParser) peek() gqlToken {
+	if p.pos >= len(p.toks) {
+		return gqlToken{}
+	}
+	return p.toks[p.pos]
+}
+
+func (p *gqlParser) next() gqlToken {
+	t := p.peek()
+	if t.kind != 0 {
+		p.pos++
+	}
+	return t
+}
+
+// punct reports whether the next token is the punctuator v, and consumes it if so.
+func (p *gqlParser) punct(v string) bool {
+	if t := p.peek(); t.kind == 'p' && t.value == v {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *gqlParser) expect(v string) error {
+	if p.punct(v) {
+		return nil
+	}
+	if t := p.peek(); t.kind != 0 {
+		return errors.Errorf("%q is expected, got %q", v, t.value)
+	}
+	return errors.Errorf("%q is expected, got the end", v)
+}
+
+func (p *gqlParser) name() (string, error) {
+	t := p.next()
+	if t.kind != 'n' {
+		if t.kind == 0 {
+			return "", errors.New("a name is expected, got the end")
+		}
+		return "", errors.Errorf("a name is expected, got %q", t.value)
+	}
+	return t.value, nil
+}
+
+func (p *gqlParser) operation(variables map[string]any) (gqlOperation, error) {
+	op := gqlOperation{Type: "query"}
+
+	p.vars = make(map[string]any, len(variables))
+	for k, v := range variables {
+		p.vars[k] = v
+	}
+
+	if t := p.peek(); t.kind == 'n' {
+		switch t.value {
+		case "query", "mutation":
+			op.Type = t.value
+		case "fragment":
+			return op, errors.New("fragments are not supported")
+		default:
+			return op, errors.Errorf("%q is not supported", t.value)
+		}
+		p.pos++
+
+		if p.peek().kind == 'n' {
+			op.Name = p.next().value
+		}
+
+		if p.punct("(") {
+			for !p.punct(")") {
+				err := p.variableDefinition()
+				if err != nil {
+					return op, err
+				}
+			}
+		}
+	}
+
+	if p.peek().value == "@" {
+		return op, errors.New("directives are not supported")
+	}
+
+	fields, err := p.selectionSet()
+	if err != nil {
+		return op, err
+	}
+	op.Fields = fields
+
+	return op, nil
+}
+
+// variableDefinition parses $name: Type = default, and applies the default if the variable is not given.
+func (p *gqlParser) variableDefinition() error {
+	if err := p.expect("$"); err != nil {
+		return err
+	}
+	name, err := p.name()
+	if err != nil {
+		return err
+	}
+	if err := p.expect(":"); err != nil {
+		return err
+	}
+	if err := p.skipType(); err != nil {
+		return err
+	}
+
+	if p.punct("=") {
+		v, err := p.value()
+		if err != nil {
+			return err
+		}
+		if _, found := p.vars[name]; !found {
+			p.vars[name] = v
+		}
+	}
+	return nil
+}
+
+func (p *gqlParser) skipType() error {
+	if p.punct("[") {
+		if err := p.skipType(); err != nil {
+			return err
+		}
+		if err := p.expect("]"); err != nil {
+			return err
+		}
+	} else if _, err := p.name(); err != nil {
+		return err
+	}
+	p.punct("!")
+	return nil
+}
+
+func (p *gqlParser) selectionSet() ([]gqlField, error) {
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+
+	var fields []gqlField
+	for !p.punct("}") {
+		if p.peek().value == "..." {
+			return nil, errors.New("fragments are not supported")
+		}
+
+		f, err := p.field()
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, f)
+	}
+	if len(fields) == 0 {
+		return nil, errors.New("empty selection set")
+	}
+	return fields, nil
+}
+
+func (p *gqlParser) field() (gqlField, error) {
+	var f gqlField
+
+	name, err := p.name()
+	if err != nil {
+		return f, err
+	}
+	if p.punct(":") {
+		f.Alias = name
+		name, err = p.name()
+		if err != nil {
+			return f, err
+		}
+	}
+	f.Name = name
+
+	if p.punct("(") {
+		f.Args = make(map[string]any)
+		for !p.punct(")") {
+			arg, err := p.name()
+			if err != nil {
+				return f, err
+			}
+			if err := p.expect(":"); err != nil {
+				return f, err
+			}
+			v, err := p.value()
+			if err != nil {
+				return f, err
+			}
+			f.Args[arg] = v
+		}
+	}
+
+	if p.peek().value == "@" {
+		return f, errors.New("directives are not supported")
+	}
+
+	if p.peek().value == "{" {
+		f.Fields, err = p.selectionSet()
+		if err != nil {
+			return f, err
+		}
+	}
+
+	return f, nil
+}
+
+func (p *gqlParser) value() (any, error) {
+	t := p.next()
+	switch t.kind {
+	case 'i':
+		return strconv.ParseInt(t.value, 10, 64)
+
+	case 'f':
+		return strconv.ParseFloat(t.value, 64)
+
+	case 's':
+		return t.value, nil
+
+	case 'n':
+		switch t.value {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		case "null":
+			return nil, nil
+		}
+		return t.value, nil // enum
+
+	case 'p':
+		switch t.value {
+		case "$":
+			name, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			return p.vars[name], nil
+
+		case "[":
+			list := []any{}
+			for !p.punct("]") {
+				v, err := p.value()
+				if err != nil {
+					return nil, err
+				}
+				list = append(list, v)
+			}
+			return list, nil
+
+		case "{":
+			obj := map[string]any{}
+			for !p.punct("}") {
+				k, err := p.name()
+				if err != nil {
+					return nil, err
+				}
+				if err := p.expect(":"); err != nil {
+					return nil, err
+				}
+				v, err := p.value()
+				if err != nil {
+					return nil, err
+				}
+				obj[k] = v
+			}
+			return obj, nil
+		}
+	}
+
+	if t.kind == 0 {
+		return nil, errors.New("a value is expected, got the end")
+	}
+	return nil, errors.Errorf("unexpected %q", t.value)
+}
+
+var gqlFilterOperators = map[string]string{
+	"eq":    "=",
+	"neq":   "<>",
+	"gt":    ">",
+	"gte":   ">=",
+	"lt":    "<",
+	"lte":   "<=",
+	"like":  "LIKE",
+	"ilike": "ILIKE",
+	"in":    "IN",
+	"nin":   "NOTIN",
+}
+
+// gqlFilter converts a filter argument into a where S-expr.
+//
+//	{id: {gte: 2}, _or: [{name: {like: "a%"}}, {name: {is_null: true}}]} -> (AND (>= .ID #2) (OR (LIKE .NAME 'a%') (ISNULL .NAME)))
+//
+// A value not of an object is of eq.
+func (r *FootREST) gqlFilter(v any) (string, error) {
+	m, ok := v.(map[string]any)
+	if !ok {
+		return "", errors.Errorf("filter must be an object, got %v", v)
+	}
+
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var conds []string
+	for _, k := range keys {
+		v := m[k]
+
+		switch k {
+		case "_and", "_or":
+			list, ok := v.([]any)
+			if !ok {
+				list = []any{v}
+			}
+			var subs []string
+			for _, item := range list {
+				sub, err := r.gqlFilter(item)
+				if err != nil {
+					return "", err
+				}
+				if sub != "" {
+					subs = append(subs, sub)
+				}
+			}
+			if len(subs) > 0 {
+				conds = append(conds, "("+strings.ToUpper(k[1:])+" "+strings.Join(subs, " ")+")")
+			}
+			continue
+
+		case "_not":
+			sub, err := r.gqlFilter(v)
+			if err != nil {
+				return "", err
+			}
+			if sub != "" {
+				conds = append(conds, "(NOT "+sub+")")
+			}
+			continue
+		}
+
+		if !r.isValidName(k) {
+			return "", errors.Errorf("invalid column name %q", k)
+		}
+		col := "." + strings.ToUpper(k)
+
+		ops, ok := v.(map[string]any)
+		if !ok {
+			ops = map[string]any{"eq": v}
+		}
+		opNames := make([]string, 0, len(ops))
+		for op := range ops {
+			opNames = append(opNames, op)
+		}
+		sort.Strings(opNames)
+
+		for _, op := range opNames {
+			operand := ops[op]
+
+			if op == "is_null" {
+				b, ok := operand.(bool)
+				if !ok {
+					return "", errors.Errorf("is_null of %s must be a boolean", k)
+				}
+				if b {
+					conds = append(conds, "(ISNULL "+col+")")
+				} else {
+					conds = append(conds, "(ISNOTNULL "+col+")")
+				}
+				continue
+			}
+
+			sop, found := gqlFilterOperators[op]
+			if !found {
+				return "", errors.Errorf("filter operator %q is not supported", op)
+			}
+
+			values, ok := operand.([]any)
+			if !ok || (sop != "IN" && sop != "NOTIN") {
+				values = []any{operand}
+			}
+			if len(values) == 0 {
+				return "", errors.Errorf("%s of %s needs values", op, k)
+			}
+			lits := make([]string, 0, len(values))
+			for _, v := range values {
+				lit, err := sexprLiteral(v)
+				if err != nil {
+					return "", err
+				}
+				lits = append(lits, lit)
+			}
+			conds = append(conds, "("+sop+" "+col+" "+strings.Join(lits, " ")+")")
+		}
+	}
+
+	return andWhere(conds...), nil
+}
+
+// gqlWhere returns a where S-expr of filter and where arguments.
+func (r *FootREST) gqlWhere(args map[string]any) (string, error) {
+	var filter string
+	if v, found := args["filter"]; found && v != nil {
+		var err error
+		filter, err = r.gqlFilter(v)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	where, _ := args["where"].(string)
+	return andWhere(filter, where), nil
+}
+
+// gqlStrings returns an argument of a string or a list of strings.
+func gqlStrings(v any) []string {
+	switch v := v.(type) {
+	case string:
+		return splitTopLevel(strings.ToUpper(v))
+	case []any:
+		var result []string
+		for _, s := range v {
+			result = append(result, strings.ToUpper(fmt.Sprint(s)))
+		}
+		return result
+	}
+	return nil
+}
+
+// gqlUint returns an argument of a non-negative integer.
+func gqlUint(args map[string]any, name string) (uint, error) {
+	switch v := args[name].(type) {
+	case nil:
+		return 0, nil
+	case int64:
+		if v >= 0 {
+			return uint(v), nil
+		}
+	case float64:
+		if v >= 0 && v == float64(int64(v)) {
+			return uint(v), nil
+		}
+	}
+	return 0, errors.Errorf("%s must be a non-negative integer", name)
+}
+
+// gqlPlan is a table with columns and embeds to resolve a selection set.
+type gqlPlan struct {
+	table  string
+	sel    []string
+	embeds []Embed
+	subs   map[string]*gqlPlan // by upper-cased embed name
+}
+
+// plan returns a plan of fields of table.
+// A field with a selection set is a relation, others are columns.
+func (r *FootREST) gqlPlan(rels []Relation, table string, fields []gqlField) (*gqlPlan, error) {
+	p := &gqlPlan{table: table, subs: make(map[string]*gqlPlan)}
+
+	addColumn := func(c string) {
+		c = strings.ToUpper(c)
+		if !equalsToAnyOfUpper(c, p.sel...) {
+			p.sel = append(p.sel, c)
+		}
+	}
+
+	for _, f := range fields {
+		if f.Name == "__typename" {
+			continue
+		}
+
+		if len(f.Fields) == 0 {
+			addColumn(f.Name)
+			continue
+		}
+
+		rel, toOne, found := findRelation(rels, table, f.Name)
+		if !found {
+			return nil, errors.Errorf("no relation %q of %q", f.Name, table)
+		}
+		if _, dup := p.subs[strings.ToUpper(f.Name)]; dup {
+			return nil, errors.Errorf("relation %q is selected twice", f.Name)
+		}
+
+		keyColumns, subTable := rel.RefColumns, rel.Table
+		if toOne {
+			keyColumns, subTable = rel.Columns, rel.RefTable
+		}
+		for _, c := range keyColumns {
+			addColumn(c)
+		}
+
+		sub, err := r.gqlPlan(rels, subTable, f.Fields)
+		if err != nil {
+			return nil, err
+		}
+		p.subs[strings.ToUpper(f.Name)] = sub
+
+		where, err := r.gqlWhere(f.Args)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s", f.Name)
+		}
+		p.embeds = append(p.embeds, Embed{
+			Name:   f.Name,
+			Select: sub.sel,
+			Where:  where,
+			Order:  gqlStrings(f.Args["order"]),
+			Embeds: sub.embeds,
+		})
+	}
+
+	return p, nil
+}
+
+// shape returns a row as an object of fields.
+func (p *gqlPlan) shape(fields []gqlField, columns []string, row []any) (record, error) {
+	rec := record{}
+	for _, f := range fields {
+		rec.columns = append(rec.columns, f.key())
+
+		if f.Name == "__typename" {
+			rec.values = append(rec.values, p.table)
+			continue
+		}
+
+		idx, err := columnIndices(columns, []string{f.Name})
+		if err != nil {
+			return record{}, err
+		}
+		v := row[idx[0]]
+
+		if len(f.Fields) > 0 {
+			sub := p.subs[strings.ToUpper(f.Name)]
+			switch sv := v.(type) {
+			case record:
+				v, err = sub.shape(f.Fields, sv.columns, sv.values)
+				if err != nil {
+					return record{}, err
+				}
+			case records:
+				v, err = sub.shapeAll(f.Fields, sv)
+				if err != nil {
+					return record{}, err
+				}
+			}
+		}
+
+		rec.values = append(rec.values, v)
+	}
+	return rec, nil
+}
+
+func (p *gqlPlan) shapeAll(fields []gqlField, rr records) ([]record, error) {
+	result := make([]record, 0, len(rr.rows))
+	for _, row := range rr.rows {
+		rec, err := p.shape(fields, rr.columns, row)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, rec)
+	}
+	return result, nil
+}
+
+// GraphQL executes a GraphQL query or mutation and returns its data.
+//
+// A query field is a table, a mutation field is insert_{table}, update_{table} or delete_{table}.
+// Mutation fields run in a transaction.
+func (r *FootREST) GraphQL(ctx context.Context, query string, variables map[string]any, operationName string) (any, error) {
+	op, err := parseGraphQL(query, variables, operationName)
+	if err != nil {
+		return nil, err
+	}
+
+	rels, err := r.getRelations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if op.Type == "query" {
+		return r.gqlFields(ctx, op, rels)
+	}
+
+	if r.conn == nil {
+		return nil, errors.New("no connection")
+	}
+
+	// schemas and primary keys are read by r.conn, so before the transaction
+	var tables []string
+	for _, f := range op.Fields {
+		if _, table, found := strings.Cut(f.Name, "_"); found && !strings.HasPrefix(f.Name, "__") {
+			if err := r.loadSchemas(strings.ToUpper(table)); err != nil {
+				return nil, errors.Wrapf(err, "%s", f.key())
+			}
+			tables = append(tables, table)
+		}
+	}
+	if _, _, err := r.getTables(ctx); err != nil {
+		return nil, err
+	}
+
+	var data any
+	err = r.inTx(ctx, false, func(tx *sql.Tx) error {
+		var err error
+		data, err = r.gqlFields(withOpenTx(ctx, &openTx{tx: tx}), op, rels)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, t := range tables {
+		r.invalidate(t)
+	}
+
+	return data, nil
+}
+
+func (r *FootREST) gqlFields(ctx context.Context, op gqlOperation, rels []Relation) (record, error) {
+	data := record{}
+	for _, f := range op.Fields {
+		var v any
+		var err error
+		switch {
+		case f.Name == "__typename":
+			v = strings.ToUpper(op.Type[:1]) + op.Type[1:]
+		case strings.HasPrefix(f.Name, "__"):
+			return record{}, errors.Errorf("introspection (%s) is not supported, GET the schema (SDL)", f.Name)
+		case op.Type == "query":
+			v, err = r.gqlQuery(ctx, rels, f)
+		default:
+			v, err = r.gqlMutation(ctx, rels, f)
+		}
+		if err != nil {
+			return record{}, errors.Wrapf(err, "%s", f.key())
+		}
+
+		data.columns = append(data.columns, f.key())
+		data.values = append(data.values, v)
+	}
+	return data, nil
+}
+
+func (r *FootREST) gqlQuery(ctx context.Context, rels []Relation, f gqlField) ([]record, error) {
+	if len(f.Fields) == 0 {
+		return nil, errors.New("a selection set is required")
+	}
+
+	p, err := r.gqlPlan(rels, f.Name, f.Fields)
+	if err != nil {
+		return nil, err
+	}
+
+	where, err := r.gqlWhere(f.Args)
+	if err != nil {
+		return nil, err
+	}
+	limit, err := gqlUint(f.Args, "limit")
+	if err != nil {
+		return nil, err
+	}
+	offset, err := gqlUint(f.Args, "offset")
+	if err != nil {
+		return nil, err
+	}
+
+	rs, err := r.GetOpts(ctx, strings.ToUpper(f.Name), p.sel, where, gqlStrings(f.Args["order"]), 0, 0,
+		GetOptions{Embed: p.embeds, Limit: limit, Offset: offset})
+	if err != nil {
+		return nil, err
+	}
+
+	return p.shapeAll(f.Fields, rs.Records)
+}
+
+func (r *FootREST) gqlMutation(ctx context.Context, rels []Relation, f gqlField) (record, error) {
+	action, table, found := strings.Cut(f.Name, "_")
+	if !found || table == "" {
+		return record{}, errors.Errorf("mutation %q is not supported", f.Name)
+	}
+
+	var returning []gqlField
+	for _, sf := range f.Fields {
+		if sf.Name == "returning" {
+			returning = sf.Fields
+			if len(returning) == 0 {
+				return record{}, errors.New("returning needs a selection set")
+			}
+		}
+	}
+
+	where, err := r.gqlWhere(f.Args)
+	if err != nil {
+		return record{}, err
+	}
+
+	var affected int64
+	var rr records
+	switch action {
+	case "insert":
+		var values any
+		if obj, ok := f.Args["object"].(map[string]any); ok {
+			values = obj
+		} else if list, ok := f.Args["objects"].([]any); ok {
+			objs := make([]map[string]any, 0, len(list))
+			for _, o := range list {
+				obj, ok := o.(map[string]any)
+				if !ok {
+					return record{}, errors.New("objects must be a list of objects")
+				}
+				objs = append(objs, obj)
+			}
+			values = objs
+		} else {
+			return record{}, errors.New("object or objects is required")
+		}
+
+		if returning != nil {
+			rr, err = r.PostReturning(ctx, strings.ToUpper(table), values)
+			affected = int64(len(rr.rows))
+		} else {
+			affected, err = r.Post(ctx, strings.ToUpper(table), values)
+		}
+
+	case "update":
+		set, ok := f.Args["set"].(map[string]any)
+		if !ok {
+			return record{}, errors.New("set is required")
+		}
+
+		if returning != nil {
+			rr, affected, err = r.PutReturning(ctx, strings.ToUpper(table), set, where)
+		} else {
+			affected, err = r.Put(ctx, strings.ToUpper(table), set, where)
+		}
+
+	case "delete":
+		if where == "" {
+			return record{}, errors.New("filter or where is required")
+		}
+
+		if returning != nil {
+			rr, err = r.DeleteReturning(ctx, strings.ToUpper(table), where)
+			affected = int64(len(rr.rows))
+		} else {
+			affected, err = r.Delete(ctx, strings.ToUpper(table), where)
+		}
+
+	default:
+		return record{}, errors.Errorf("mutation %q is not supported", f.Name)
+	}
+	if err != nil {
+		return record{}, err
+	}
+
+	p, err := r.gqlPlan(rels, table, returning)
+	if err != nil {
+		return record{}, err
+	}
+
+	result := record{}
+	for _, sf := range f.Fields {
+		result.columns = append(result.columns, sf.key())
+		switch sf.Name {
+		case "affected_rows":
+			result.values = append(result.values, affected)
+		case "returning":
+			if len(p.embeds) > 0 {
+				return record{}, errors.New("relations in returning are not supported")
+			}
+			rows, err := p.shapeAll(returning, rr)
+			if err != nil {
+				return record{}, err
+			}
+			result.values = append(result.values, rows)
+		case "__typename":
+			result.values = append(result.values, table+"_mutation")
+		default:
+			return record{}, errors.Errorf("field %q is not in %s_mutation", sf.Name, table)
+		}
+	}
+	return result, nil
+}
+
+// GraphQLSchema returns a GraphQL schema (SDL) of exposed tables and their relations.
+func (r *FootREST) GraphQLSchema(ctx context.Context) (string, error) {
+	tables, _, err := r.getTables(ctx)
+	if err != nil {
+		return "", err
+	}
+	rels, err := r.getRelations(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	const where = "filter: JSON, where: String, order: [String!]"
+
+	buf := strings.Builder{}
+	buf.WriteString("scalar JSON\n")
+
+	for _, t := range tables {
+		sc, err := r.getSchema(t)
+		if err != nil {
+			return "", errors.Wrapf(err, "schema of %s", t)
+		}
+
+		names := make([]string, 0, len(sc))
+		for name := range sc {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		fmt.Fprintf(&buf, "\ntype %s {\n", t)
+		for _, name := range names {
+			fmt.Fprintf(&buf, "  %s: %s\n", sc[name].Name(), r.gqlType(sc[name]))
+		}
+		for _, rel := range rels {
+			if strings.EqualFold(rel.Table, t) {
+				n := rel.Name
+				if n == "" {
+					n = rel.RefTable
+				}
+				if _, dup := sc[strings.ToUpper(n)]; !dup {
+					fmt.Fprintf(&buf, "  %s(%s): %s\n", n, where, rel.RefTable)
+				}
+			}
+			if strings.EqualFold(rel.RefTable, t) {
+				n := rel.ReverseName
+				if n == "" {
+					n = rel.Table
+				}
+				if _, dup := sc[strings.ToUpper(n)]; !dup {
+					fmt.Fprintf(&buf, "  %s(%s): [%s!]!\n", n, where, rel.Table)
+				}
+			}
+		}
+		buf.WriteString("}\n")
+
+		fmt.Fprintf(&buf, "\ntype %s_mutation {\n  affected_rows: Int!\n  returning: [%s!]!\n}\n", t, t)
+	}
+
+	buf.WriteString("\ntype Query {\n")
+	for _, t := range tables {
+		fmt.Fprintf(&buf, "  %s(%s, limit: Int, offset: Int): [%s!]!\n", t, where, t)
+	}
+	buf.WriteString("}\n")
+
+	buf.WriteString("\ntype Mutation {\n")
+	for _, t := range tables {
+		fmt.Fprintf(&buf, "  insert_%s(object: JSON, objects: [JSON!]): %s_mutation!\n", t, t)
+		fmt.Fprintf(&buf, "  update_%s(filter: JSON, where: String, set: JSON!): %s_mutation!\n", t, t)
+		fmt.Fprintf(&buf, "  delete_%s(filter: JSON, where: String): %s_mutation!\n", t, t)
+	}
+	buf.WriteString("}\n")
+
+	return buf.String(), nil
+}
+
+// gqlType returns a GraphQL type of a column type.
+func (r *FootREST) gqlType(typ *sql.ColumnType) string {
+	name := strings.ToUpper(typ.DatabaseTypeName())
+	switch {
+	case strings.Contains(name, "INTERVAL"), strings.Contains(name, "POINT"):
+		return "String"
+
+	case strings.Contains(name, "BOOL"), name == "BIT":
+		return "Boolean"
+
+	case strings.Contains(name, "INT"):
+		return "Int"
+
+	case strings.Contains(name, "FLOAT"),
+		strings.Contains(name, "REAL"),
+		strings.Contains(name, "DOUBLE"):
+		return "Float"
+
+	case strings.Contains(name, "DEC"),
+		strings.Contains(name, "NUM"),
+		strings.Contains(name, "MONEY"):
+		if r.config.Output.Decimal == "number" {
+			return "Float"
+		}
+		return "String"
+	}
+
+	return "String"
+}
@@ -0,0 +1,55 @@
+package footrest
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// apply sets the pool settings to conn.
+func (c PoolConfig) apply(conn *sql.DB) {
+	conn.SetMaxOpenConns(c.MaxOpenConns)
+	conn.SetMaxIdleConns(c.MaxIdleConns)
+	conn.SetConnMaxLifetime(time.Duration(c.ConnMaxLifetime) * time.Millisecond)
+	conn.SetConnMaxIdleTime(time.Duration(c.ConnMaxIdleTime) * time.Millisecond)
+}
+
+// pingRetry pings conn, retrying up to config Pool.PingRetries times.
+// Each ping times out in config Timeout.
+func pingRetry(conn *sql.DB, config *Config, l *slog.Logger) error {
+	backoff := time.Duration(config.Pool.PingBackoff) * time.Millisecond
+
+	for attempt := 0; ; attempt++ {
+		ctx, cancel := config.Context()
+		err := conn.PingContext(ctx)
+		cancel()
+		if err == nil {
+			return nil
+		}
+
+		if attempt >= config.Pool.PingRetries {
+			return errors.Wrap(err, "ping")
+		}
+
+		l.Warn("retry ping", slog.Int("attempt", attempt+1), slog.String("error", err.Error()))
+
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// Ping reports whether the database is reachable.
+func (r *FootREST) Ping(ctx context.Context) error {
+	if r.conn == nil {
+		return errors.New("no connection")
+	}
+	return r.conn.PingContext(ctx)
+}
+
+type healthStatus struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
@@ -0,0 +1,283 @@
+package footrest
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// recordSource streams records to be imported.
+//
+// Next returns io.EOF at the end.
+// A rejectError means the line is skipped and reported.
+type recordSource interface {
+	Next() (map[string]any, error)
+}
+
+type rejectedLine struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+type rejectError struct {
+	line int
+	err  error
+}
+
+func (e rejectError) Error() string {
+	return "line " + strconv.Itoa(e.line) + ": " + e.err.Error()
+}
+
+type importReport struct {
+	Inserted int64          `json:"inserted"`
+	Rejected []rejectedLine `json:"rejected"`
+}
+
+// Import inserts all records from src into table in batches of batchSize within one transaction.
+func (r *FootREST) Import(ctx context.Context, table string, src recordSource, batchSize int) (importReport, error) {
+	report := importReport{
+		Rejected: []rejectedLine{},
+	}
+
+	if err := r.validateTableName(table); err != nil {
+		return report, err
+	}
+
+	if batchSize <= 0 {
+		batchSize = 1
+	}
+
+	if r.conn == nil {
+		return report, nil
+	}
+
+	if err := r.loadSchemas(table); err != nil {
+		return report, err
+	}
+
+	// src can not be read again, so no retry.
+	err := r.inTx(ctx, false, func(tx *sql.Tx) error {
+		return r.importTx(ctx, tx, table, src, batchSize, &report)
+	})
+	if err != nil {
+		return report, err
+	}
+
+	r.invalidate(table)
+
+	return report, nil
+}
+
+func (r *FootREST) importTx(ctx context.Context, tx *sql.Tx, table string, src recordSource, batchSize int, report *importReport) error {
+	flush := func(batch []map[string]any) error {
+		strStmts, argss, err := r.BuildPostStmts(table, batch)
+		if err != nil {
+			return err
+		}
+
+		for i := range strStmts {
+			ra, err := r.execTx(ctx, tx, strStmts[i], argss[i])
+			if err != nil {
+				return err
+			}
+			report.Inserted += ra
+		}
+
+		return nil
+	}
+
+	batch := make([]map[string]any, 0, batchSize)
+	for {
+		rec, err := src.Next()
+		if err == io.EOF {
+			break
+		}
+		if rerr, ok := err.(rejectError); ok {
+			report.Rejected = append(report.Rejected, rejectedLine{Line: rerr.line, Error: rerr.err.Error()})
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		batch = append(batch, rec)
+		if len(batch) >= batchSize {
+			err = flush(batch)
+			if err != nil {
+				return err
+			}
+			batch = make([]map[string]any, 0, batchSize)
+		}
+	}
+	if len(batch) > 0 {
+		err := flush(batch)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// csvSource reads a CSV whose first line is a header of column names.
+type csvSource struct {
+	reader  *csv.Reader
+	columns []string
+	types   []*sql.ColumnType
+}
+
+// newCSVSource reads the header and maps it to the columns of table.
+func (r *FootREST) newCSVSource(table string, body io.Reader) (*csvSource, error) {
+	if err := r.validateTableName(table); err != nil {
+		return nil, err
+	}
+
+	reader := csv.NewReader(body)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, errors.Wrap(err, "csv header")
+	}
+
+	var sc map[string]*sql.ColumnType
+	if r.useSchema {
+		sc, err = r.getSchema(table)
+		if err != nil {
+			return nil, errors.Wrap(err, "schema")
+		}
+	}
+
+	src := csvSource{
+		reader:  reader,
+		columns: make([]string, len(header)),
+		types:   make([]*sql.ColumnType, len(header)),
+	}
+	for i, h := range header {
+		h = strings.TrimSpace(strings.TrimPrefix(h, "\ufeff"))
+		if !r.isValidName(h) {
+			return nil, errors.Errorf("invalid column name %q", h)
+		}
+
+		if sc != nil {
+			typ, found := sc[strings.ToUpper(h)]
+			if !found {
+				return nil, errors.Errorf("column %q is not in %q scheme", h, table)
+			}
+			h = typ.Name()
+			src.types[i] = typ
+		}
+		src.columns[i] = h
+	}
+
+	return &src, nil
+}
+
+func (s *csvSource) Next() (map[string]any, error) {
+	fields, err := s.reader.Read()
+	if err == io.EOF {
+		return nil, err
+	}
+	if perr, ok := err.(*csv.ParseError); ok {
+		return nil, rejectError{line: perr.StartLine, err: perr.Err}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	line, _ := s.reader.FieldPos(0)
+	if len(fields) != len(s.columns) {
+		return nil, rejectError{line: line, err: errors.Errorf("%d fields, want %d", len(fields), len(s.columns))}
+	}
+
+	rec := make(map[string]any, len(fields))
+	for i, f := range fields {
+		v, err := convField(f, s.types[i])
+		if err != nil {
+			return nil, rejectError{line: line, err: errors.Wrapf(err, "column %q", s.columns[i])}
+		}
+		rec[s.columns[i]] = v
+	}
+
+	return rec, nil
+}
+
+// ndjsonSource reads a JSON object per line.
+type ndjsonSource struct {
+	reader *bufio.Reader
+	line   int
+}
+
+func newNDJSONSource(body io.Reader) *ndjsonSource {
+	return &ndjsonSource{
+		reader: bufio.NewReader(body),
+	}
+}
+
+func (s *ndjsonSource) Next() (map[string]any, error) {
+	for {
+		data, err := s.reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if err == io.EOF && len(data) == 0 {
+			return nil, io.EOF
+		}
+		s.line++
+
+		data = bytes.TrimSpace(data)
+		if len(data) == 0 {
+			continue
+		}
+
+		var rec map[string]any
+		jerr := json.Unmarshal(data, &rec)
+		if jerr != nil {
+			return nil, rejectError{line: s.line, err: jerr}
+		}
+		if rec == nil {
+			return nil, rejectError{line: s.line, err: errors.New("not an object")}
+		}
+
+		return rec, nil
+	}
+}
+
+// convField converts a CSV field by the column type.
+// An empty field is NULL.
+func convField(s string, typ *sql.ColumnType) (any, error) {
+	if s == "" {
+		return nil, nil
+	}
+	if typ == nil {
+		return s, nil
+	}
+
+	name := strings.ToUpper(typ.DatabaseTypeName())
+	switch {
+	case strings.Contains(name, "INTERVAL"), strings.Contains(name, "POINT"):
+		return s, nil
+
+	case strings.Contains(name, "INT"):
+		return strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+
+	case strings.Contains(name, "FLOAT"),
+		strings.Contains(name, "REAL"),
+		strings.Contains(name, "DOUBLE"):
+		return strconv.ParseFloat(strings.TrimSpace(s), 64)
+
+	case strings.Contains(name, "BOOL"):
+		return strconv.ParseBool(strings.TrimSpace(s))
+	}
+
+	return s, nil
+}
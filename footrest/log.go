@@ -0,0 +1,156 @@
+package footrest
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"regexp"
+	"strings"
+	"time"
+
+	echo "github.com/labstack/echo/v4"
+)
+
+// HeaderRequestID is a request header of an id of a request in logs, generated if absent.
+// It is also a response header.
+const HeaderRequestID = echo.HeaderXRequestID
+
+// redactedArg replaces args of statements touching LogConfig.Redact columns.
+const redactedArg = "[REDACTED]"
+
+// NewLogger returns a logger of config Log writing to w.
+func NewLogger(config LogConfig, w io.Writer) *slog.Logger {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(config.Level)); err != nil {
+		level = slog.LevelInfo
+	}
+	opts := &slog.HandlerOptions{Level: level}
+
+	if strings.EqualFold(config.Format, "json") {
+		return slog.New(slog.NewJSONHandler(w, opts))
+	}
+	return slog.New(slog.NewTextHandler(w, opts))
+}
+
+// SetLogger replaces the logger made of config Log.
+func (r *FootREST) SetLogger(l *slog.Logger) {
+	r.log = l
+}
+
+type loggerKey struct{}
+
+func withLogger(ctx context.Context, l *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, l)
+}
+
+// logger returns the logger of the request in ctx, or the one of r.
+func (r *FootREST) logger(ctx context.Context) *slog.Logger {
+	if l, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return l
+	}
+	return r.log
+}
+
+var identPattern = regexp.MustCompile(`[A-Za-z_][A-Za-z0-9_$#]*`)
+
+// redactArgs returns args, or all of them redacted if strStmt contains a column of config Log.Redact.
+//
+// Args are not mapped to columns, since a statement like "a = ? OR b = ?" can bind any of them.
+func (r *FootREST) redactArgs(strStmt string, args []any) []any {
+	if len(r.config.Log.Redact) == 0 || len(args) == 0 {
+		return args
+	}
+
+	for _, ident := range identPattern.FindAllString(strStmt, -1) {
+		if equalsToAnyOfUpper(ident, r.config.Log.Redact...) {
+			redacted := make([]any, len(args))
+			for i := range redacted {
+				redacted[i] = redactedArg
+			}
+			return redacted
+		}
+	}
+	return args
+}
+
+// redactURI returns uri, with its query redacted if config Log.Redact is set,
+// since query params such as where can have values of any column.
+func (r *FootREST) redactURI(uri string) string {
+	if len(r.config.Log.Redact) == 0 {
+		return uri
+	}
+	if path, _, found := strings.Cut(uri, "?"); found {
+		return path + "?" + redactedArg
+	}
+	return uri
+}
+
+// traceStmt logs a statement executed since start, which read or wrote rows.
+//
+// A statement slower than config Log.SlowQuery is logged as a warning.
+func (r *FootREST) traceStmt(ctx context.Context, strStmt string, args []any, start time.Time, rows int64, err error) {
+	d := time.Since(start)
+
+	level := slog.LevelDebug
+	msg := "sql"
+	if slow := r.config.Log.SlowQuery; slow > 0 && d >= time.Duration(slow)*time.Millisecond {
+		level = slog.LevelWarn
+		msg = "slow query"
+	}
+
+	l := r.logger(ctx)
+	if !l.Enabled(ctx, level) {
+		return
+	}
+
+	attrs := []slog.Attr{
+		slog.String("stmt", strStmt),
+		slog.Any("args", r.redactArgs(strStmt, args)),
+		slog.Duration("duration", d),
+		slog.Int64("rows", rows),
+	}
+	if err != nil {
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+	l.LogAttrs(ctx, level, msg, attrs...)
+}
+
+func newRequestID() string {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// logMiddleware gives a request a logger with its request id, and logs the request.
+func (r *FootREST) logMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		start := time.Now()
+
+		id := c.Request().Header.Get(HeaderRequestID)
+		if id == "" {
+			id = newRequestID()
+		}
+		c.Response().Header().Set(HeaderRequestID, id)
+
+		l := r.log.With(slog.String("request_id", id))
+		c.SetRequest(c.Request().WithContext(withLogger(c.Request().Context(), l)))
+
+		err := next(c)
+
+		l.LogAttrs(c.Request().Context(), slog.LevelInfo, "request",
+			slog.String("method", c.Request().Method),
+			slog.String("uri", r.redactURI(c.Request().RequestURI)),
+			slog.String("route", c.Path()),
+			slog.Int("status", c.Response().Status),
+			slog.Duration("duration", time.Since(start)),
+			slog.Int64("bytes_out", c.Response().Size),
+			slog.String("remote_ip", c.RealIP()),
+		)
+
+		return err
+	}
+}
@@ -0,0 +1,271 @@
+package footrest
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	echo "github.com/labstack/echo/v4"
+)
+
+// metricsContentType is of the Prometheus text exposition format.
+const metricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// durationBuckets are upper bounds (seconds) of histograms of durations.
+var durationBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// metrics are collected in process and exposed by GET /!metrics.
+type metrics struct {
+	mu sync.Mutex
+
+	requests        map[string]float64 // by labels
+	requestDuration map[string]*histogram
+	queryDuration   map[string]*histogram
+	rowsReturned    map[string]float64
+	rowsAffected    map[string]float64
+	bulkOps         map[string]float64
+
+	schemaHits, schemaMisses float64
+}
+
+type histogram struct {
+	counts []uint64 // by durationBuckets, not cumulative
+	count  uint64
+	sum    float64
+}
+
+func (h *histogram) observe(v float64) {
+	if h.counts == nil {
+		h.counts = make([]uint64, len(durationBuckets))
+	}
+	for i, le := range durationBuckets {
+		if v <= le {
+			h.counts[i]++
+			break
+		}
+	}
+	h.count++
+	h.sum += v
+}
+
+// labels formats name="value" pairs like {a="1",b="2"}.
+func labels(kv ...string) string {
+	var b strings.Builder
+	b.WriteByte('{')
+	for i := 0; i+1 < len(kv); i += 2 {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(kv[i])
+		b.WriteString(`="`)
+		b.WriteString(strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(kv[i+1]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+func addCounter(m *map[string]float64, key string, v float64) {
+	if *m == nil {
+		*m = make(map[string]float64)
+	}
+	(*m)[key] += v
+}
+
+func observeHistogram(m *map[string]*histogram, key string, v float64) {
+	if *m == nil {
+		*m = make(map[string]*histogram)
+	}
+	h, found := (*m)[key]
+	if !found {
+		h = &histogram{}
+		(*m)[key] = h
+	}
+	h.observe(v)
+}
+
+func (m *metrics) request(route, table, method string, status int, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	addCounter(&m.requests, labels("route", route, "table", table, "method", method, "status", strconv.Itoa(status)), 1)
+	observeHistogram(&m.requestDuration, labels("route", route, "table", table, "method", method), d.Seconds())
+}
+
+// stmtKind returns the kind of a statement by its first keyword, like "select" or "insert".
+func stmtKind(strStmt string) string {
+	kw, _, _ := strings.Cut(strings.TrimLeft(strStmt, " \t\r\n("), " ")
+	switch kw = strings.ToLower(kw); kw {
+	case "select", "with":
+		return "select"
+	case "insert", "update", "delete", "merge", "call", "exec", "begin":
+		return kw
+	}
+	return "other"
+}
+
+func (m *metrics) query(strStmt string, d time.Duration, rows int64) {
+	kind := stmtKind(strStmt)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	observeHistogram(&m.queryDuration, labels("kind", kind), d.Seconds())
+	addCounter(&m.rowsReturned, labels("kind", kind), float64(rows))
+}
+
+func (m *metrics) exec(strStmt string, d time.Duration, rowsAffected int64) {
+	kind := stmtKind(strStmt)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	observeHistogram(&m.queryDuration, labels("kind", kind), d.Seconds())
+	addCounter(&m.rowsAffected, labels("kind", kind), float64(rowsAffected))
+}
+
+func (m *metrics) schemaCache(hit bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if hit {
+		m.schemaHits++
+	} else {
+		m.schemaMisses++
+	}
+}
+
+// bulk counts a bulk operation.
+// An unsupported method is labeled "invalid", so that methods in requests do not grow metrics.
+func (m *metrics) bulk(method string, err error) {
+	method = strings.ToUpper(method)
+	switch method {
+	case "POST", "PUT", "UPSERT", "DELETE":
+	default:
+		method = "invalid"
+	}
+
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	addCounter(&m.bulkOps, labels("method", method, "result", result), 1)
+}
+
+// metricsMiddleware counts requests by route, table, method and status.
+//
+// Tables of failed requests are not labeled, so that unknown names do not grow metrics.
+func (r *FootREST) metricsMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		start := time.Now()
+		err := next(c)
+
+		status := c.Response().Status
+		if err != nil && !c.Response().Committed {
+			status = http.StatusInternalServerError
+			if he, ok := err.(*echo.HTTPError); ok {
+				status = he.Code
+			}
+		}
+
+		table := strings.ToUpper(c.Param("table"))
+		if status >= 400 {
+			table = ""
+		}
+
+		r.metrics.request(c.Path(), table, c.Request().Method, status, time.Since(start))
+
+		return err
+	}
+}
+
+// Metrics returns metrics in the Prometheus text exposition format.
+func (r *FootREST) Metrics() []byte {
+	var buf bytes.Buffer
+
+	r.metrics.mu.Lock()
+	writeCounters(&buf, "footrest_requests_total", "Requests by route, table, method and status.", r.metrics.requests)
+	writeHistograms(&buf, "footrest_request_duration_seconds", "Request durations by route, table and method.", r.metrics.requestDuration)
+	writeHistograms(&buf, "footrest_query_duration_seconds", "Statement durations by kind.", r.metrics.queryDuration)
+	writeCounters(&buf, "footrest_rows_returned_total", "Rows read by statement kind.", r.metrics.rowsReturned)
+	writeCounters(&buf, "footrest_rows_affected_total", "Rows written by statement kind.", r.metrics.rowsAffected)
+	writeCounters(&buf, "footrest_bulk_operations_total", "Elements of /!bulk by method and result.", r.metrics.bulkOps)
+	writeCounters(&buf, "footrest_schema_cache_hits_total", "Schema cache hits.", map[string]float64{"": r.metrics.schemaHits})
+	writeCounters(&buf, "footrest_schema_cache_misses_total", "Schema cache misses.", map[string]float64{"": r.metrics.schemaMisses})
+	r.metrics.mu.Unlock()
+
+	ss := r.StmtCacheStats()
+	writeCounters(&buf, "footrest_stmt_cache_hits_total", "Prepared statement cache hits.", map[string]float64{"": float64(ss.Hits)})
+	writeCounters(&buf, "footrest_stmt_cache_misses_total", "Prepared statement cache misses.", map[string]float64{"": float64(ss.Misses)})
+	writeGauge(&buf, "footrest_stmt_cache_size", "Cached prepared statements.", float64(ss.Size))
+
+	if r.conn != nil {
+		st := r.conn.Stats()
+		writeGauge(&buf, "footrest_db_max_open_connections", "Max open connections of the pool.", float64(st.MaxOpenConnections))
+		writeGauge(&buf, "footrest_db_open_connections", "Open connections.", float64(st.OpenConnections))
+		writeGauge(&buf, "footrest_db_in_use_connections", "Connections in use.", float64(st.InUse))
+		writeGauge(&buf, "footrest_db_idle_connections", "Idle connections.", float64(st.Idle))
+		writeCounters(&buf, "footrest_db_wait_count_total", "Connections waited for.", map[string]float64{"": float64(st.WaitCount)})
+		writeCounters(&buf, "footrest_db_wait_duration_seconds_total", "Time waited for connections.", map[string]float64{"": st.WaitDuration.Seconds()})
+		writeCounters(&buf, "footrest_db_max_idle_closed_total", "Connections closed by MaxIdleConns.", map[string]float64{"": float64(st.MaxIdleClosed)})
+		writeCounters(&buf, "footrest_db_max_idle_time_closed_total", "Connections closed by ConnMaxIdleTime.", map[string]float64{"": float64(st.MaxIdleTimeClosed)})
+		writeCounters(&buf, "footrest_db_max_lifetime_closed_total", "Connections closed by ConnMaxLifetime.", map[string]float64{"": float64(st.MaxLifetimeClosed)})
+	}
+
+	return buf.Bytes()
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func writeCounters(buf *bytes.Buffer, name, help string, m map[string]float64) {
+	fmt.Fprintf(buf, "# HELP %s %s\n# TYPE %s counter\n", name, help, name)
+	for _, k := range sortedKeys(m) {
+		fmt.Fprintf(buf, "%s%s %s\n", name, k, formatFloat(m[k]))
+	}
+}
+
+func writeGauge(buf *bytes.Buffer, name, help string, v float64) {
+	fmt.Fprintf(buf, "# HELP %s %s\n# TYPE %s gauge\n%s %s\n", name, help, name, name, formatFloat(v))
+}
+
+func writeHistograms(buf *bytes.Buffer, name, help string, m map[string]*histogram) {
+	fmt.Fprintf(buf, "# HELP %s %s\n# TYPE %s histogram\n", name, help, name)
+	for _, k := range sortedKeys(m) {
+		h := m[k]
+
+		// le is appended to the labels
+		prefix := strings.TrimSuffix(k, "}")
+		if prefix != "{" {
+			prefix += ","
+		}
+
+		var cum uint64
+		for i, le := range durationBuckets {
+			cum += h.counts[i]
+			fmt.Fprintf(buf, "%s_bucket%sle=\"%s\"} %d\n", name, prefix, formatFloat(le), cum)
+		}
+		fmt.Fprintf(buf, "%s_bucket%sle=\"+Inf\"} %d\n", name, prefix, h.count)
+		fmt.Fprintf(buf, "%s_sum%s %s\n", name, k, formatFloat(h.sum))
+		fmt.Fprintf(buf, "%s_count%s %d\n", name, k, h.count)
+	}
+}
@@ -0,0 +1,739 @@
+package footrest
+
+import (
+	"context"
+	"database/sql"
+	"encoding/xml"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+
+	echo "github.com/labstack/echo/v4"
+)
+
+// ODataNamespace is a namespace of entity types in $metadata.
+const ODataNamespace = "FootREST"
+
+// odataQuery is GET query params of OData.
+type odataQuery struct {
+	Where  string
+	Select []string
+	Order  []string
+	Top    uint
+	Skip   uint
+	Count  bool
+}
+
+// parseOData parses $filter, $select, $orderby, $top, $skip and $count.
+func parseOData(params url.Values) (odataQuery, error) {
+	var q odataQuery
+
+	if f := params.Get("$filter"); f != "" {
+		w, err := ParseODataFilter(f)
+		if err != nil {
+			return q, errors.Wrap(err, "$filter")
+		}
+		q.Where = w
+	}
+
+	if sel := params.Get("$select"); sel != "" && sel != "*" {
+		for _, c := range strings.Split(sel, ",") {
+			q.Select = append(q.Select, strings.ToUpper(strings.TrimSpace(c)))
+		}
+	}
+
+	if order := params.Get("$orderby"); order != "" {
+		for _, o := range strings.Split(order, ",") {
+			fields := strings.Fields(o)
+			if len(fields) == 0 || len(fields) > 2 {
+				return q, errors.Errorf("$orderby: invalid item %q", o)
+			}
+			col := strings.ToUpper(fields[0])
+			if len(fields) == 2 {
+				switch strings.ToLower(fields[1]) {
+				case "asc":
+				case "desc":
+					col = "-" + col
+				default:
+					return q, errors.Errorf("$orderby: invalid direction %q", fields[1])
+				}
+			}
+			q.Order = append(q.Order, col)
+		}
+	}
+
+	for _, name := range []string{"$top", "$skip"} {
+		s := params.Get(name)
+		if s == "" {
+			continue
+		}
+		n, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return q, errors.Wrapf(err, "%s", name)
+		}
+		if name == "$top" {
+			q.Top = uint(n)
+		} else {
+			q.Skip = uint(n)
+		}
+	}
+
+	if s := params.Get("$count"); s != "" {
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return q, errors.Wrap(err, "$count")
+		}
+		q.Count = b
+	}
+
+	return q, nil
+}
+
+var odataComparisons = map[string]string{
+	"eq": "=",
+	"ne": "<>",
+	"gt": ">",
+	"ge": ">=",
+	"lt": "<",
+	"le": "<=",
+}
+
+var odataArithmetics = map[string]string{
+	"add": "+",
+	"sub": "-",
+	"mul": "*",
+	"div": "/",
+}
+
+// odataFunctions are functions of $filter and where S-exprs they are converted into.
+// $1, $2 are arguments.
+var odataFunctions = map[string]struct {
+	nargs  int
+	format string
+}{
+	"contains":   {2, "(LIKEESCAPE $1 '%$2%')"},
+	"startswith": {2, "(LIKEESCAPE $1 '$2%')"},
+	"endswith":   {2, "(LIKEESCAPE $1 '%$2')"},
+	"tolower":    {1, "(LOWER $1)"},
+	"toupper":    {1, "(UPPER $1)"},
+	"trim":       {1, "(TRIM $1)"},
+	"length":     {1, "(LENGTH $1)"},
+	"concat":     {2, "(|| $1 $2)"},
+}
+
+// likeEscaper escapes wildcards of a LIKEESCAPE pattern by \.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+type odataToken struct {
+	kind  byte // 'i'dentifier, 's'tring, 'n'umber, or one of "(),"
+	value string
+}
+
+// ParseODataFilter converts $filter of OData into a where S-expr.
+//
+//	Price le 200 and (contains(Name,'a') or Id in (1,2)) -> (AND (<= .PRICE #200) (OR (LIKEESCAPE .NAME '%a%') (IN .ID #1 #2)))
+func ParseODataFilter(s string) (string, error) {
+	toks, err := odataTokenize(s)
+	if err != nil {
+		return "", err
+	}
+
+	p := odataParser{toks: toks}
+	w, err := p.or()
+	if err != nil {
+		return "", err
+	}
+	if p.pos < len(p.toks) {
+		return "", errors.Errorf("unexpected %q", p.toks[p.pos].value)
+	}
+	return w, nil
+}
+
+func odataTokenize(s string) ([]odataToken, error) {
+	var toks []odataToken
+	for i := 0; i < len(s); {
+		c := rune(s[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+
+		case c == '(' || c == ')' || c == ',':
+			toks = append(toks, odataToken{kind: byte(c), value: string(c)})
+			i++
+
+		case c == '\'':
+			// 'it''s'
+			buf := strings.Builder{}
+			i++
+			for {
+				if i >= len(s) {
+					return nil, errors.New("unterminated string")
+				}
+				if s[i] == '\'' {
+					if i+1 < len(s) && s[i+1] == '\'' {
+						buf.WriteByte('\'')
+						i += 2
+						continue
+					}
+					i++
+					break
+				}
+				buf.WriteByte(s[i])
+				i++
+			}
+			toks = append(toks, odataToken{kind: 's', value: buf.String()})
+
+		case c == '-' || unicode.IsDigit(c):
+			// numbers, and dates or times like 2024-01-02T03:04:05Z
+			start := i
+			i++
+			for i < len(s) && strings.ContainsRune("0123456789.-+:TZ", rune(s[i])) {
+				i++
+			}
+			toks = append(toks, odataToken{kind: 'n', value: s[start:i]})
+
+		case unicode.IsLetter(c) || c == '_':
+			start := i
+			for i < len(s) && (unicode.IsLetter(rune(s[i])) || unicode.IsDigit(rune(s[i])) || s[i] == '_') {
+				i++
+			}
+			toks = append(toks, odataToken{kind: 'i', value: s[start:i]})
+
+		default:
+			return nil, errors.Errorf("unexpected %q", string(c))
+		}
+	}
+	return toks, nil
+}
+
+type odataParser struct {
+	toks []odataToken
+	pos  int
+}
+
+func (p *odataParser) peek() odataToken {
+	if p.pos >= len(p.toks) {
+		return odataToken{}
+	}
+	return p.toks[p.pos]
+}
+
+// keyword reports whether the next token is the keyword kw, and consumes it if so.
+func (p *odataParser) keyword(kw string) bool {
+	t := p.peek()
+	if t.kind == 'i' && strings.EqualFold(t.value, kw) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *odataParser) expect(kind byte) error {
+	t := p.peek()
+	if t.kind != kind {
+		if t.kind == 0 {
+			return errors.Errorf("%q is expected, got the end", string(kind))
+		}
+		return errors.Errorf("%q is expected, got %q", string(kind), t.value)
+	}
+	p.pos++
+	return nil
+}
+
+func (p *odataParser) or() (string, error) {
+	return p.logical("or", p.and)
+}
+
+func (p *odataParser) and() (string, error) {
+	return p.logical("and", p.not)
+}
+
+func (p *odataParser) logical(op string, operand func() (string, error)) (string, error) {
+	first, err := operand()
+	if err != nil {
+		return "", err
+	}
+
+	conds := []string{first}
+	for p.keyword(op) {
+		c, err := operand()
+		if err != nil {
+			return "", err
+		}
+		conds = append(conds, c)
+	}
+	if len(conds) == 1 {
+		return first, nil
+	}
+	return "(" + strings.ToUpper(op) + " " + strings.Join(conds, " ") + ")", nil
+}
+
+func (p *odataParser) not() (string, error) {
+	if p.keyword("not") {
+		c, err := p.not()
+		if err != nil {
+			return "", err
+		}
+		return "(NOT " + c + ")", nil
+	}
+	return p.comparison()
+}
+
+func (p *odataParser) comparison() (string, error) {
+	left, err := p.additive()
+	if err != nil {
+		return "", err
+	}
+
+	t := p.peek()
+	if t.kind != 'i' {
+		return left, nil
+	}
+	kw := strings.ToLower(t.value)
+
+	if kw == "in" {
+		p.pos++
+		if err := p.expect('('); err != nil {
+			return "", err
+		}
+		values := []string{}
+		for {
+			v, err := p.primary()
+			if err != nil {
+				return "", err
+			}
+			values = append(values, v)
+			if p.peek().kind != ',' {
+				break
+			}
+			p.pos++
+		}
+		if err := p.expect(')'); err != nil {
+			return "", err
+		}
+		return "(IN " + left + " " + strings.Join(values, " ") + ")", nil
+	}
+
+	op, found := odataComparisons[kw]
+	if !found {
+		return left, nil
+	}
+	p.pos++
+
+	right, err := p.additive()
+	if err != nil {
+		return "", err
+	}
+
+	if right == "NULL" {
+		switch kw {
+		case "eq":
+			return "(ISNULL " + left + ")", nil
+		case "ne":
+			return "(ISNOTNULL " + left + ")", nil
+		}
+	}
+	return "(" + op + " " + left + " " + right + ")", nil
+}
+
+func (p *odataParser) additive() (string, error) {
+	return p.arithmetic(p.multiplicative, "add", "sub")
+}
+
+func (p *odataParser) multiplicative() (string, error) {
+	return p.arithmetic(p.primary, "mul", "div")
+}
+
+func (p *odataParser) arithmetic(operand func() (string, error), ops ...string) (string, error) {
+	left, err := operand()
+	if err != nil {
+		return "", err
+	}
+
+	for {
+		t := p.peek()
+		if t.kind != 'i' || !equalsToAnyOfUpper(t.value, ops...) {
+			return left, nil
+		}
+		p.pos++
+
+		right, err := operand()
+		if err != nil {
+			return "", err
+		}
+		left = "(" + odataArithmetics[strings.ToLower(t.value)] + " " + left + " " + right + ")"
+	}
+}
+
+func (p *odataParser) primary() (string, error) {
+	t := p.peek()
+	switch t.kind {
+	case 0:
+		return "", errors.New("unexpected end")
+
+	case '(':
+		p.pos++
+		e, err := p.or()
+		if err != nil {
+			return "", err
+		}
+		if err := p.expect(')'); err != nil {
+			return "", err
+		}
+		return e, nil
+
+	case 's':
+		p.pos++
+		return sexprLiteral(t.value)
+
+	case 'n':
+		p.pos++
+		if i, err := strconv.ParseInt(t.value, 10, 64); err == nil {
+			return "#" + strconv.FormatInt(i, 10), nil
+		}
+		if _, err := strconv.ParseFloat(t.value, 64); err == nil {
+			return t.value, nil
+		}
+		// dates and times
+		return sexprLiteral(t.value)
+
+	case 'i':
+		p.pos++
+		switch strings.ToLower(t.value) {
+		case "null", "true", "false":
+			return strings.ToUpper(t.value), nil
+		}
+
+		if p.peek().kind != '(' {
+			return "." + strings.ToUpper(t.value), nil
+		}
+
+		f, found := odataFunctions[strings.ToLower(t.value)]
+		if !found {
+			return "", errors.Errorf("function %q is not supported", t.value)
+		}
+		p.pos++
+
+		var args []string
+		for p.peek().kind != ')' {
+			if len(args) > 0 {
+				if err := p.expect(','); err != nil {
+					return "", err
+				}
+			}
+			a, err := p.additive()
+			if err != nil {
+				return "", err
+			}
+			args = append(args, a)
+		}
+		p.pos++
+		if len(args) != f.nargs {
+			return "", errors.Errorf("%s needs %d arguments, got %d", t.value, f.nargs, len(args))
+		}
+
+		for i, a := range args {
+			if strings.Contains(f.format, "'%$"+strconv.Itoa(i+1)) || strings.Contains(f.format, "$"+strconv.Itoa(i+1)+"%'") {
+				// a string literal embedded into a pattern
+				if !strings.HasPrefix(a, "'") {
+					return "", errors.Errorf("%s needs a string literal, got %s", t.value, a)
+				}
+				args[i] = likeEscaper.Replace(a[1 : len(a)-1])
+			}
+		}
+		return formatArgs(f.format, args), nil
+	}
+
+	return "", errors.Errorf("unexpected %q", t.value)
+}
+
+// getTables returns exposed tables and their primary keys.
+//
+// Tables are config Tables, or listed by Dialect.Tables if empty.
+// Primary keys are listed by Dialect.PrimaryKeys.
+func (r *FootREST) getTables(ctx context.Context) ([]string, map[string][]string, error) {
+	r.scMut.Lock()
+	if r.tables != nil {
+		tables, keys := r.tables, r.keys
+		r.scMut.Unlock()
+		return tables, keys, nil
+	}
+	r.scMut.Unlock()
+
+	tables := append([]string{}, r.config.Tables...)
+	keys := make(map[string][]string)
+
+	if r.conn != nil && len(tables) == 0 && r.dialect.Tables != "" {
+		list, err := queryStrings(ctx, r.conn, r.dialect.Tables, 1)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "tables")
+		}
+		for _, row := range list {
+			tables = append(tables, row[0])
+		}
+	}
+
+	if r.conn != nil && r.dialect.PrimaryKeys != "" {
+		list, err := queryStrings(ctx, r.conn, r.dialect.PrimaryKeys, 2)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "primary keys")
+		}
+		for _, row := range list {
+			t := strings.ToUpper(row[0])
+			keys[t] = append(keys[t], row[1])
+		}
+	}
+
+	sort.Strings(tables)
+
+	r.scMut.Lock()
+	r.tables, r.keys = tables, keys
+	r.scMut.Unlock()
+
+	return tables, keys, nil
+}
+
+// queryStrings returns rows of n string columns.
+func queryStrings(ctx context.Context, conn *sql.DB, stmt string, n int) ([][]string, error) {
+	rows, err := conn.QueryContext(ctx, stmt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result [][]string
+	for rows.Next() {
+		row := make([]string, n)
+		dest := make([]any, n)
+		for i := range row {
+			dest[i] = &row[i]
+		}
+		err = rows.Scan(dest...)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, row)
+	}
+	return result, rows.Err()
+}
+
+type csdlEdmx struct {
+	XMLName  xml.Name `xml:"edmx:Edmx"`
+	XMLNS    string   `xml:"xmlns:edmx,attr"`
+	Version  string   `xml:"Version,attr"`
+	Services struct {
+		Schema csdlSchema `xml:"Schema"`
+	} `xml:"edmx:DataServices"`
+}
+
+type csdlSchema struct {
+	XMLNS       string           `xml:"xmlns,attr"`
+	Namespace   string           `xml:"Namespace,attr"`
+	EntityTypes []csdlEntityType `xml:"EntityType"`
+	Container   struct {
+		Name       string          `xml:"Name,attr"`
+		EntitySets []csdlEntitySet `xml:"EntitySet"`
+	} `xml:"EntityContainer"`
+}
+
+type csdlEntityType struct {
+	Name       string         `xml:"Name,attr"`
+	Key        *csdlKey       `xml:"Key"`
+	Properties []csdlProperty `xml:"Property"`
+}
+
+type csdlKey struct {
+	PropertyRefs []struct {
+		Name string `xml:"Name,attr"`
+	} `xml:"PropertyRef"`
+}
+
+type csdlProperty struct {
+	Name     string `xml:"Name,attr"`
+	Type     string `xml:"Type,attr"`
+	Nullable string `xml:"Nullable,attr,omitempty"`
+}
+
+type csdlEntitySet struct {
+	Name       string `xml:"Name,attr"`
+	EntityType string `xml:"EntityType,attr"`
+}
+
+// ODataMetadata returns a CSDL document of exposed tables.
+func (r *FootREST) ODataMetadata(ctx context.Context) ([]byte, error) {
+	tables, keys, err := r.getTables(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := csdlEdmx{
+		XMLNS:   "http://docs.oasis-open.org/odata/ns/edmx",
+		Version: "4.0",
+	}
+	schema := &doc.Services.Schema
+	schema.XMLNS = "http://docs.oasis-open.org/odata/ns/edm"
+	schema.Namespace = ODataNamespace
+	schema.Container.Name = "Container"
+
+	for _, t := range tables {
+		sc, err := r.getSchema(t)
+		if err != nil {
+			return nil, errors.Wrapf(err, "schema of %s", t)
+		}
+
+		et := csdlEntityType{Name: t}
+
+		names := make([]string, 0, len(sc))
+		for name := range sc {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			typ := sc[name]
+			p := csdlProperty{Name: typ.Name(), Type: edmType(typ)}
+			if nullable, ok := typ.Nullable(); ok && !nullable {
+				p.Nullable = "false"
+			}
+			et.Properties = append(et.Properties, p)
+		}
+
+		if k := keys[strings.ToUpper(t)]; len(k) > 0 {
+			et.Key = &csdlKey{}
+			for _, col := range k {
+				et.Key.PropertyRefs = append(et.Key.PropertyRefs, struct {
+					Name string `xml:"Name,attr"`
+				}{Name: col})
+			}
+		}
+
+		schema.EntityTypes = append(schema.EntityTypes, et)
+		schema.Container.EntitySets = append(schema.Container.EntitySets, csdlEntitySet{
+			Name:       t,
+			EntityType: ODataNamespace + "." + t,
+		})
+	}
+
+	data, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), data...), nil
+}
+
+// edmType returns an Edm type of a column type.
+func edmType(typ *sql.ColumnType) string {
+	name := strings.ToUpper(typ.DatabaseTypeName())
+	switch {
+	case strings.Contains(name, "INTERVAL"):
+		return "Edm.Duration"
+
+	case strings.Contains(name, "BOOL"), name == "BIT":
+		return "Edm.Boolean"
+
+	case strings.Contains(name, "INT"):
+		return "Edm.Int64"
+
+	case strings.Contains(name, "DEC"),
+		strings.Contains(name, "NUM"),
+		strings.Contains(name, "MONEY"):
+		return "Edm.Decimal"
+
+	case strings.Contains(name, "FLOAT"),
+		strings.Contains(name, "REAL"),
+		strings.Contains(name, "DOUBLE"):
+		return "Edm.Double"
+
+	case name == "DATE":
+		return "Edm.Date"
+
+	case strings.Contains(name, "DATE"),
+		strings.Contains(name, "TIMESTAMP"):
+		return "Edm.DateTimeOffset"
+
+	case strings.Contains(name, "BLOB"),
+		strings.Contains(name, "BINARY"),
+		strings.Contains(name, "BYTEA"),
+		strings.Contains(name, "RAW"):
+		return "Edm.Binary"
+	}
+
+	return "Edm.String"
+}
+
+// odataGet returns rows of table by q, and the count of all matching rows if q.Count.
+func (r *FootREST) odataGet(ctx context.Context, table string, q odataQuery) (records, any, error) {
+	opts := GetOptions{Limit: q.Top, Offset: q.Skip}
+	if _, _, err := r.BuildGetStmtOpts(table, q.Select, q.Where, q.Order, 0, 0, opts); err != nil {
+		return records{}, nil, odataRequestError{err}
+	}
+
+	rs, err := r.GetOpts(ctx, table, q.Select, q.Where, q.Order, 0, 0, opts)
+	if err != nil {
+		return records{}, nil, err
+	}
+	if !q.Count {
+		return rs.Records, nil, nil
+	}
+
+	count, err := r.odataCount(ctx, table, q.Where)
+	if err != nil {
+		return records{}, nil, err
+	}
+	return rs.Records, count, nil
+}
+
+// odataCount returns the count of rows of table matching where.
+func (r *FootREST) odataCount(ctx context.Context, table, where string) (any, error) {
+	opts := GetOptions{Count: true}
+	if _, _, err := r.BuildGetStmtOpts(table, nil, where, nil, 0, 0, opts); err != nil {
+		return nil, odataRequestError{err}
+	}
+
+	rs, err := r.GetOpts(ctx, table, nil, where, nil, 0, 0, opts)
+	if err != nil {
+		return nil, err
+	}
+	if len(rs.Records.rows) == 0 || len(rs.Records.rows[0]) == 0 {
+		return 0, nil
+	}
+	return rs.Records.rows[0][0], nil
+}
+
+// odataRequestError is an error of a request, such as an unknown column.
+// Other errors of odataGet and odataCount are of the server.
+type odataRequestError struct {
+	error
+}
+
+func (e odataRequestError) Unwrap() error {
+	return e.error
+}
+
+// odataStatus returns a status code of err;
+// 504 for a timeout, 400 for an odataRequestError, or 500.
+func odataStatus(err error) int {
+	var rerr odataRequestError
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	case errors.As(err, &rerr):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// odataError responds an error in the OData JSON format.
+func odataError(c echo.Context, status int, err error) error {
+	_ = c.JSON(status, map[string]any{
+		"error": map[string]any{
+			"code":    strconv.Itoa(status),
+			"message": err.Error(),
+		},
+	})
+	return err
+}
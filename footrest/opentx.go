@@ -0,0 +1,204 @@
+package footrest
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// HeaderTx is a request header of an id of an interactive transaction opened by POST /!tx.
+const HeaderTx = "X-Footrest-Tx"
+
+// openTx is an interactive transaction over requests.
+type openTx struct {
+	id     string
+	tx     *sql.Tx
+	cancel context.CancelFunc
+
+	mu    sync.Mutex // held during a request
+	timer *time.Timer
+	done  bool // committed, rolled back or expired, guarded by mu
+}
+
+type openTxKey struct{}
+
+func withOpenTx(ctx context.Context, otx *openTx) context.Context {
+	return context.WithValue(ctx, openTxKey{}, otx)
+}
+
+func openTxFrom(ctx context.Context) *openTx {
+	otx, _ := ctx.Value(openTxKey{}).(*openTx)
+	return otx
+}
+
+// queryerFrom returns the interactive transaction in ctx, or the connection.
+func (r *FootREST) queryerFrom(ctx context.Context) queryer {
+	if otx := openTxFrom(ctx); otx != nil {
+		return otx.tx
+	}
+	return r.conn
+}
+
+// OpenTx begins an interactive transaction and returns its id.
+//
+// The transaction begins with options of WithTxOptions(ctx),
+// but it lives until CommitTx, RollbackTx or Config.Tx.IdleTimeout.
+func (r *FootREST) OpenTx(ctx context.Context) (string, error) {
+	if r.conn == nil {
+		return "", errors.New("no connection")
+	}
+
+	var b [16]byte
+	_, err := rand.Read(b[:])
+	if err != nil {
+		return "", err
+	}
+	id := hex.EncodeToString(b[:])
+
+	// reserve a slot, and begin without txMut not to block other transactions
+	r.txMut.Lock()
+	if max := r.config.Tx.MaxOpen; len(r.openTxs)+r.opening >= max {
+		r.txMut.Unlock()
+		return "", errors.Errorf("too many open transactions (max %d)", max)
+	}
+	r.opening++
+	r.txMut.Unlock()
+
+	txctx, cancel := context.WithCancel(context.Background())
+	tx, err := r.conn.BeginTx(txctx, txOptionsFrom(ctx))
+
+	r.txMut.Lock()
+	defer r.txMut.Unlock()
+	r.opening--
+
+	if err != nil {
+		cancel()
+		return "", err
+	}
+
+	if r.openTxs == nil {
+		r.openTxs = make(map[string]*openTx)
+	}
+
+	otx := &openTx{
+		id:     id,
+		tx:     tx,
+		cancel: cancel,
+	}
+	otx.timer = time.AfterFunc(r.idleTimeout(), func() {
+		r.expireTx(otx)
+	})
+	r.openTxs[id] = otx
+
+	r.logger(ctx).Debug("open tx", slog.String("tx", id))
+
+	return id, nil
+}
+
+// CommitTx commits an interactive transaction.
+func (r *FootREST) CommitTx(id string) error {
+	otx, err := r.takeTx(id)
+	if err != nil {
+		return err
+	}
+	defer otx.cancel()
+
+	r.log.Debug("commit tx", slog.String("tx", id))
+
+	// writes in the transaction are of unknown tables
+	defer r.invalidate()
+
+	return otx.tx.Commit()
+}
+
+// RollbackTx rolls back an interactive transaction.
+func (r *FootREST) RollbackTx(id string) error {
+	otx, err := r.takeTx(id)
+	if err != nil {
+		return err
+	}
+	defer otx.cancel()
+
+	r.log.Debug("rollback tx", slog.String("tx", id))
+
+	return otx.tx.Rollback()
+}
+
+// takeTx removes the transaction from the open ones, waiting for its running request.
+func (r *FootREST) takeTx(id string) (*openTx, error) {
+	r.txMut.Lock()
+	otx, found := r.openTxs[id]
+	if found {
+		delete(r.openTxs, id)
+	}
+	r.txMut.Unlock()
+
+	if !found {
+		return nil, errors.Errorf("transaction %q is not open", id)
+	}
+
+	otx.mu.Lock()
+	otx.timer.Stop()
+	otx.done = true
+	otx.mu.Unlock()
+
+	return otx, nil
+}
+
+// acquireTx locks the transaction for a request.
+// The returned func releases it and restarts the idle timer.
+func (r *FootREST) acquireTx(id string) (*openTx, func(), error) {
+	r.txMut.Lock()
+	otx, found := r.openTxs[id]
+	r.txMut.Unlock()
+
+	if !found {
+		return nil, nil, errors.Errorf("transaction %q is not open", id)
+	}
+
+	otx.mu.Lock()
+	if otx.done {
+		// taken while waiting
+		otx.mu.Unlock()
+		return nil, nil, errors.Errorf("transaction %q is not open", id)
+	}
+	otx.timer.Stop()
+
+	return otx, func() {
+		otx.timer.Reset(r.idleTimeout())
+		otx.mu.Unlock()
+	}, nil
+}
+
+// expireTx rolls back an abandoned transaction.
+func (r *FootREST) expireTx(otx *openTx) {
+	if !otx.mu.TryLock() {
+		// in use, the timer will be reset on release
+		return
+	}
+	defer otx.mu.Unlock()
+
+	r.txMut.Lock()
+	if r.openTxs[otx.id] != otx {
+		r.txMut.Unlock()
+		return
+	}
+	delete(r.openTxs, otx.id)
+	r.txMut.Unlock()
+	otx.done = true
+
+	r.log.Debug("expire tx", slog.String("tx", otx.id))
+
+	_ = otx.tx.Rollback()
+	otx.cancel()
+}
+
+func (r *FootREST) idleTimeout() time.Duration {
+	return time.Duration(r.config.Tx.IdleTimeout) * time.Millisecond
+}
@@ -0,0 +1,301 @@
+package footrest
+
+import (
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// SyntaxPostgREST is a value of Config.Syntax to parse GET query params in the PostgREST style.
+//
+//	?age=gte.18&or=(name.eq.a,name.like.b*)&order=name.desc&select=id,name,customers(name)&limit=10&offset=20
+const SyntaxPostgREST = "postgrest"
+
+// postgrestQuery is GET query params in the PostgREST style.
+type postgrestQuery struct {
+	Where  string
+	Select []string
+	Order  []string
+	Embed  []Embed
+	Limit  uint
+	Offset uint
+}
+
+var postgrestOperators = map[string]string{
+	"eq":  "=",
+	"neq": "<>",
+	"gt":  ">",
+	"gte": ">=",
+	"lt":  "<",
+	"lte": "<=",
+}
+
+// parsePostgREST parses query params in the PostgREST style into a where S-expr and others.
+// Params in reserved are skipped.
+func (r *FootREST) parsePostgREST(params url.Values, reserved ...string) (postgrestQuery, error) {
+	var q postgrestQuery
+
+	if sel := params.Get("select"); sel != "" {
+		for _, c := range postgrestSplit(sel) {
+			c = strings.TrimSpace(c)
+			if open := strings.Index(c, "("); open != -1 && strings.HasSuffix(c, ")") {
+				// embedded resource: customers(name,id)
+				e := Embed{Name: strings.ToUpper(c[:open])}
+				if cols := c[open+1 : len(c)-1]; cols != "" && cols != "*" {
+					e.Select = strings.Split(strings.ToUpper(cols), ",")
+				}
+				q.Embed = append(q.Embed, e)
+				continue
+			}
+			q.Select = append(q.Select, strings.ToUpper(c))
+		}
+	}
+
+	if order := params.Get("order"); order != "" {
+		for _, o := range strings.Split(order, ",") {
+			parts := strings.Split(strings.TrimSpace(o), ".")
+			col := strings.ToUpper(parts[0])
+			for _, p := range parts[1:] {
+				if strings.EqualFold(p, "desc") {
+					col = "-" + col
+				}
+			}
+			q.Order = append(q.Order, col)
+		}
+	}
+
+	for _, name := range []string{"limit", "offset"} {
+		s := params.Get(name)
+		if s == "" {
+			continue
+		}
+		n, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return q, errors.Wrapf(err, "%s", name)
+		}
+		if name == "limit" {
+			q.Limit = uint(n)
+		} else {
+			q.Offset = uint(n)
+		}
+	}
+
+	var conds []string
+	embedConds := make(map[string][]string)
+	for k, vv := range params {
+		if equalsToAnyOfUpper(k, "select", "order", "limit", "offset") || equalsToAnyOfUpper(k, reserved...) {
+			continue
+		}
+
+		for _, v := range vv {
+			var cond string
+			var err error
+
+			logic := strings.TrimPrefix(strings.ToLower(k), "not.")
+			if logic == "and" || logic == "or" {
+				cond, err = r.postgrestLogic(strings.ToLower(k), v)
+				if err != nil {
+					return q, err
+				}
+				conds = append(conds, cond)
+				continue
+			}
+
+			col := k
+			if dot := strings.Index(k, "."); dot != -1 && isEmbedName(q.Embed, k[:dot]) {
+				col = k[dot+1:]
+			}
+			cond, err = r.postgrestFilter(col, v)
+			if err != nil {
+				return q, err
+			}
+			if col != k {
+				name := strings.ToUpper(k[:len(k)-len(col)-1])
+				embedConds[name] = append(embedConds[name], cond)
+			} else {
+				conds = append(conds, cond)
+			}
+		}
+	}
+	q.Where = andWhere(conds...)
+	for i, e := range q.Embed {
+		q.Embed[i].Where = andWhere(embedConds[e.Name]...)
+	}
+
+	return q, nil
+}
+
+// postgrestFilter converts col=[not.]op.value into a where S-expr.
+func (r *FootREST) postgrestFilter(col, v string) (string, error) {
+	if !r.isValidName(col) {
+		return "", errors.Errorf("invalid column name %q", col)
+	}
+	col = "." + strings.ToUpper(col)
+
+	not := false
+	if strings.HasPrefix(v, "not.") {
+		not = true
+		v = v[len("not."):]
+	}
+
+	dot := strings.Index(v, ".")
+	if dot == -1 {
+		return "", errors.Errorf("invalid filter %q", v)
+	}
+	op, value := strings.ToLower(v[:dot]), v[dot+1:]
+
+	var cond string
+	switch op {
+	case "eq", "neq", "gt", "gte", "lt", "lte":
+		lit, err := postgrestLiteral(value)
+		if err != nil {
+			return "", err
+		}
+		cond = "(" + postgrestOperators[op] + " " + col + " " + lit + ")"
+
+	case "like", "ilike":
+		lit, err := sexprLiteral(strings.ReplaceAll(postgrestUnquote(value), "*", "%"))
+		if err != nil {
+			return "", err
+		}
+		cond = "(" + op + " " + col + " " + lit + ")"
+
+	case "is":
+		switch strings.ToLower(value) {
+		case "null":
+			cond = "(ISNULL " + col + ")"
+		case "true", "false":
+			cond = "(IS " + col + " " + strings.ToUpper(value) + ")"
+		default:
+			return "", errors.Errorf("invalid value of is %q", value)
+		}
+
+	case "in":
+		if !strings.HasPrefix(value, "(") || !strings.HasSuffix(value, ")") {
+			return "", errors.Errorf("in needs (values), got %q", value)
+		}
+		lits := []string{}
+		for _, item := range postgrestSplit(value[1 : len(value)-1]) {
+			lit, err := postgrestLiteral(item)
+			if err != nil {
+				return "", err
+			}
+			lits = append(lits, lit)
+		}
+		cond = "(IN " + col + " " + strings.Join(lits, " ") + ")"
+
+	default:
+		return "", errors.Errorf("operator %q is not supported", op)
+	}
+
+	if not {
+		cond = "(NOT " + cond + ")"
+	}
+	return cond, nil
+}
+
+// postgrestLogic converts or=(a.eq.1,and(b.gt.2,c.lt.3)) into a where S-expr.
+func (r *FootREST) postgrestLogic(logic, v string) (string, error) {
+	not := strings.HasPrefix(logic, "not.")
+	logic = strings.TrimPrefix(logic, "not.")
+
+	if !strings.HasPrefix(v, "(") || !strings.HasSuffix(v, ")") {
+		return "", errors.Errorf("%s needs (conditions), got %q", logic, v)
+	}
+
+	var conds []string
+	for _, item := range postgrestSplit(v[1 : len(v)-1]) {
+		item = strings.TrimSpace(item)
+
+		var cond string
+		var err error
+		if open := strings.Index(item, "("); open != -1 {
+			if l := strings.ToLower(item[:open]); l == "and" || l == "or" || l == "not.and" || l == "not.or" {
+				cond, err = r.postgrestLogic(l, item[open:])
+				if err != nil {
+					return "", err
+				}
+				conds = append(conds, cond)
+				continue
+			}
+		}
+
+		dot := strings.Index(item, ".")
+		if dot == -1 {
+			return "", errors.Errorf("invalid condition %q", item)
+		}
+		cond, err = r.postgrestFilter(item[:dot], item[dot+1:])
+		if err != nil {
+			return "", err
+		}
+		conds = append(conds, cond)
+	}
+	if len(conds) == 0 {
+		return "", errors.Errorf("%s needs conditions", logic)
+	}
+
+	cond := "(" + strings.ToUpper(logic) + " " + strings.Join(conds, " ") + ")"
+	if not {
+		cond = "(NOT " + cond + ")"
+	}
+	return cond, nil
+}
+
+// postgrestLiteral converts a value into an S-expr literal.
+func postgrestLiteral(s string) (string, error) {
+	if strings.HasPrefix(s, `"`) {
+		return sexprLiteral(postgrestUnquote(s))
+	}
+
+	switch strings.ToLower(s) {
+	case "null", "true", "false":
+		return strings.ToUpper(s), nil
+	}
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil && (s == "0" || !strings.HasPrefix(strings.TrimPrefix(s, "-"), "0")) {
+		return "#" + strconv.FormatInt(i, 10), nil
+	}
+	// not NaN nor Inf, not a leading zero like 007 of any sign
+	if f, err := strconv.ParseFloat(s, 64); err == nil && !math.IsInf(f, 0) && !math.IsNaN(f) {
+		if u := strings.TrimPrefix(s, "-"); !strings.HasPrefix(u, "0") || strings.HasPrefix(u, "0.") {
+			return s, nil
+		}
+	}
+	return sexprLiteral(s)
+}
+
+// postgrestUnquote unquotes "a,b" into a,b.
+func postgrestUnquote(s string) string {
+	if len(s) >= 2 && strings.HasPrefix(s, `"`) && strings.HasSuffix(s, `"`) {
+		return strings.ReplaceAll(s[1:len(s)-1], `\"`, `"`)
+	}
+	return s
+}
+
+// postgrestSplit splits s by commas out of parentheses and double quotes.
+func postgrestSplit(s string) []string {
+	var result []string
+	depth := 0
+	quoted := false
+	last := 0
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\\' && quoted:
+			i++
+		case c == '"':
+			quoted = !quoted
+		case quoted:
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == ',' && depth == 0:
+			result = append(result, s[last:i])
+			last = i + 1
+		}
+	}
+	return append(result, s[last:])
+}
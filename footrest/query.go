@@ -0,0 +1,153 @@
+package footrest
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// BuildNamedQueryStmt builds a statement of config Queries[name] bound to params.
+//
+// With orderColumns or pagination, the query is wrapped as a subquery.
+func (r *FootREST) BuildNamedQueryStmt(name string, params map[string]string, orderColumns []string, rowsPerPage, page uint) (string, []any, error) {
+	q, found := r.lookupQuery(name)
+	if !found {
+		return "", nil, errors.Errorf("query %q is not defined", name)
+	}
+
+	var args []any
+	buf := strings.Builder{}
+	tmpl := q.SQL
+	quoted := false
+	for len(tmpl) > 0 {
+		c := tmpl[0]
+		if c == '\'' {
+			quoted = !quoted
+		}
+		if c != '{' || quoted {
+			buf.WriteByte(c)
+			tmpl = tmpl[1:]
+			continue
+		}
+
+		end := strings.IndexByte(tmpl, '}')
+		if end == -1 {
+			buf.WriteString(tmpl)
+			break
+		}
+		pname := tmpl[1:end]
+		if pname == "" || !r.isValidName(pname) {
+			buf.WriteByte(c)
+			tmpl = tmpl[1:]
+			continue
+		}
+		tmpl = tmpl[end+1:]
+
+		typ, found := lookupFold(q.Params, pname)
+		if !found {
+			return "", nil, errors.Errorf("query %q: param %q is not declared", name, pname)
+		}
+		s, found := lookupFold(params, pname)
+		if !found {
+			return "", nil, errors.Errorf("query %q: param %q is missing", name, pname)
+		}
+		v, err := convParam(s, typ)
+		if err != nil {
+			return "", nil, errors.Wrapf(err, "query %q: param %q", name, pname)
+		}
+
+		buf.WriteString(r.dialect.Placeholder(len(args)))
+		args = append(args, r.dialect.Arg(len(args), v))
+	}
+	strStmt := strings.TrimSpace(strings.TrimRight(strings.TrimSpace(buf.String()), ";"))
+
+	pagination := r.dialect.Paginate(rowsPerPage, page)
+	if len(orderColumns) == 0 && pagination[0] == "" && pagination[1] == "" {
+		return strStmt, args, nil
+	}
+
+	// ORDER BY
+
+	orderByClause := ""
+	if len(orderColumns) != 0 {
+		orders := make([]string, 0, len(orderColumns))
+		for _, o := range orderColumns {
+			o = strings.TrimSpace(o)
+			desc := strings.HasPrefix(o, "-")
+			o = strings.TrimPrefix(o, "-")
+			if !r.isValidName(o) {
+				return "", nil, errors.Errorf("validate order: invalid column name %q", o)
+			}
+			if desc {
+				o += " DESC"
+			}
+			orders = append(orders, o)
+		}
+
+		orderByClause = "ORDER BY " + strings.Join(orders, ", ")
+	}
+
+	if r.dialect.WrapQuery == nil {
+		return "", nil, errors.Errorf("query %q: order and pagination are not supported", name)
+	}
+	wrapped, err := r.dialect.WrapQuery(strStmt)
+	if err != nil {
+		return "", nil, errors.Wrapf(err, "query %q: order and pagination", name)
+	}
+
+	return strings.Join(nonEmpty(pagination[0], wrapped, orderByClause, pagination[1]), " "), args, nil
+}
+
+// NamedQuery runs a query of config Queries[name].
+func (r *FootREST) NamedQuery(ctx context.Context, name string, params map[string]string, orderColumns []string, rowsPerPage, page uint) (recordSet, error) {
+	strStmt, args, err := r.BuildNamedQueryStmt(name, params, orderColumns, rowsPerPage, page)
+	if err != nil {
+		return recordSet{}, err
+	}
+
+	rr, err := r.query(ctx, r.queryerFrom(ctx), strStmt, args)
+	if err != nil {
+		return recordSet{}, err
+	}
+
+	return recordSet{Table: name, Records: rr, Columnar: r.config.Output.Columnar}, nil
+}
+
+func (r *FootREST) lookupQuery(name string) (QueryConfig, bool) {
+	return lookupFold(r.config.Queries, name)
+}
+
+// lookupFold looks up m by a case-insensitive key.
+func lookupFold[V any](m map[string]V, key string) (V, bool) {
+	if v, found := m[key]; found {
+		return v, true
+	}
+	for k, v := range m {
+		if strings.EqualFold(k, key) {
+			return v, true
+		}
+	}
+
+	var zero V
+	return zero, false
+}
+
+// convParam converts a query param by typ of QueryConfig.Params.
+func convParam(s, typ string) (any, error) {
+	switch strings.ToLower(strings.TrimSpace(typ)) {
+	case "", "string":
+		return s, nil
+	case "int":
+		return strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	case "float":
+		return strconv.ParseFloat(strings.TrimSpace(s), 64)
+	case "bool":
+		return strconv.ParseBool(strings.TrimSpace(s))
+	case "time":
+		return time.Parse(time.RFC3339, strings.TrimSpace(s))
+	}
+	return nil, errors.Errorf("unknown type %q", typ)
+}
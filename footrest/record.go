@@ -0,0 +1,340 @@
+package footrest
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/text/encoding"
+)
+
+// records keeps the column order of a result set.
+//
+// It is marshaled as an array of objects whose keys are in the SELECT order.
+type records struct {
+	columns []string
+	rows    [][]any
+}
+
+func (rr records) MarshalJSON() ([]byte, error) {
+	buf := bytes.Buffer{}
+	buf.WriteByte('[')
+	for ri, row := range rr.rows {
+		if ri > 0 {
+			buf.WriteByte(',')
+		}
+		err := writeObject(&buf, rr.columns, row)
+		if err != nil {
+			return nil, err
+		}
+	}
+	buf.WriteByte(']')
+
+	return buf.Bytes(), nil
+}
+
+// columnar is marshaled as {"columns":[...],"rows":[[...]]}.
+type columnar records
+
+func (rr columnar) MarshalJSON() ([]byte, error) {
+	rows := rr.rows
+	if rows == nil {
+		rows = [][]any{}
+	}
+	return json.Marshal(struct {
+		Columns []string `json:"columns"`
+		Rows    [][]any  `json:"rows"`
+	}{
+		Columns: rr.columns,
+		Rows:    rows,
+	})
+}
+
+type recordSet struct {
+	Table    string
+	Records  records
+	Columnar bool
+	Next     string // keyset cursor of the next page
+}
+
+func (rs recordSet) MarshalJSON() ([]byte, error) {
+	buf := bytes.Buffer{}
+	buf.WriteString(`{"table":`)
+	data, err := json.Marshal(rs.Table)
+	if err != nil {
+		return nil, err
+	}
+	buf.Write(data)
+
+	if rs.Columnar {
+		data, err = json.Marshal(columnar(rs.Records))
+		if err != nil {
+			return nil, err
+		}
+		// {"columns":...,"rows":...} -> ,"columns":...,"rows":...
+		buf.WriteByte(',')
+		buf.Write(data[1 : len(data)-1])
+	} else {
+		data, err = json.Marshal(rs.Records)
+		if err != nil {
+			return nil, err
+		}
+		buf.WriteString(`,"records":`)
+		buf.Write(data)
+	}
+	if rs.Next != "" {
+		data, err = json.Marshal(rs.Next)
+		if err != nil {
+			return nil, err
+		}
+		buf.WriteString(`,"next":`)
+		buf.Write(data)
+	}
+	buf.WriteByte('}')
+
+	return buf.Bytes(), nil
+}
+
+// body returns the value to be embedded into Format.QueryOK.
+func (rs recordSet) body() json.Marshaler {
+	if rs.Columnar {
+		return columnar(rs.Records)
+	}
+	return rs.Records
+}
+
+func writeObject(buf *bytes.Buffer, columns []string, values []any) error {
+	buf.WriteByte('{')
+	for i, c := range columns {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+
+		data, err := json.Marshal(c)
+		if err != nil {
+			return err
+		}
+		buf.Write(data)
+		buf.WriteByte(':')
+
+		data, err = json.Marshal(values[i])
+		if err != nil {
+			return err
+		}
+		buf.Write(data)
+	}
+	buf.WriteByte('}')
+
+	return nil
+}
+
+// scanRecords reads all rows, decoding strings by r.encoding and formatting values by r.config.Output.
+func (r *FootREST) scanRecords(rows *sql.Rows) (records, error) {
+	colnames, err := rows.Columns()
+	if err != nil {
+		return records{}, err
+	}
+
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		return records{}, err
+	}
+
+	var dec *encoding.Decoder
+	if r.encoding != nil {
+		dec = r.encoding.NewDecoder()
+	}
+
+	rr := records{
+		columns: colnames,
+		rows:    make([][]any, 0, 8),
+	}
+	for rows.Next() {
+		cols := make([]any, len(colnames))
+		colptrs := make([]any, len(cols))
+		for i := range cols {
+			colptrs[i] = &cols[i]
+		}
+
+		err = rows.Scan(colptrs...)
+		if err != nil {
+			return records{}, err
+		}
+
+		for i := range cols {
+			cols[i], err = r.formatValue(cols[i], types[i], dec)
+			if err != nil {
+				return records{}, err
+			}
+		}
+
+		rr.rows = append(rr.rows, cols)
+	}
+
+	return rr, rows.Err()
+}
+
+func (r *FootREST) formatValue(v any, typ *sql.ColumnType, dec *encoding.Decoder) (any, error) {
+	kind := columnKind(typ)
+
+	if b, ok := v.([]byte); ok && (kind == kindText || kind == kindDecimal) {
+		v = string(b)
+	}
+
+	switch v := v.(type) {
+	case string:
+		if dec != nil {
+			s, err := dec.String(v)
+			if err != nil {
+				return nil, err
+			}
+			v = s
+		}
+		if kind == kindDecimal {
+			return r.formatDecimal(v), nil
+		}
+		return v, nil
+
+	case []byte:
+		if strings.EqualFold(r.config.Output.Binary, "hex") {
+			return hex.EncodeToString(v), nil
+		}
+		return base64.StdEncoding.EncodeToString(v), nil
+
+	case float64:
+		if kind == kindDecimal {
+			return r.formatDecimal(strconv.FormatFloat(v, 'f', -1, 64)), nil
+		}
+		return v, nil
+
+	case int64:
+		// an integer-valued decimal like 2.00 may be read as int64
+		if kind == kindDecimal {
+			return r.formatDecimal(strconv.FormatInt(v, 10)), nil
+		}
+		return v, nil
+
+	case time.Time:
+		return v.Format(time.RFC3339Nano), nil
+	}
+
+	return v, nil
+}
+
+// formatDecimal returns s as a json.Number if config Output.Decimal is "number" and s is a finite JSON number,
+// otherwise s as is (NaN, Infinity, ...).
+func (r *FootREST) formatDecimal(s string) any {
+	if strings.EqualFold(r.config.Output.Decimal, "number") {
+		n := json.Number(strings.TrimSpace(s))
+		if f, err := n.Float64(); err == nil && !math.IsInf(f, 0) && !math.IsNaN(f) && json.Valid([]byte(n)) {
+			return n
+		}
+	}
+	return s
+}
+
+const (
+	kindOther = iota
+	kindText
+	kindDecimal
+)
+
+func columnKind(typ *sql.ColumnType) int {
+	if typ == nil {
+		return kindOther
+	}
+
+	name := strings.ToUpper(typ.DatabaseTypeName())
+	switch {
+	case strings.Contains(name, "DEC"),
+		strings.Contains(name, "NUM"),
+		strings.Contains(name, "MONEY"):
+		return kindDecimal
+
+	case strings.Contains(name, "CHAR"),
+		strings.Contains(name, "TEXT"),
+		strings.Contains(name, "CLOB"),
+		strings.Contains(name, "XML"),
+		strings.Contains(name, "JSON"):
+		return kindText
+	}
+
+	return kindOther
+}
+
+// encodeCursor encodes keyset values as an opaque string.
+func encodeCursor(values []any) (string, error) {
+	data, err := json.Marshal(values)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decodeCursor decodes a string of encodeCursor.
+// An empty string is an empty cursor (the first page).
+func decodeCursor(s string) ([]any, error) {
+	if s == "" {
+		return []any{}, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errors.Wrap(err, "cursor")
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var values []any
+	err = dec.Decode(&values)
+	if err != nil {
+		return nil, errors.Wrap(err, "cursor")
+	}
+
+	for i, v := range values {
+		if n, ok := v.(json.Number); ok {
+			if iv, err := n.Int64(); err == nil {
+				values[i] = iv
+			} else if fv, err := n.Float64(); err == nil {
+				values[i] = fv
+			}
+		}
+	}
+
+	return values, nil
+}
+
+// nextCursor returns a cursor of the last row, or "" if it is not the full page.
+func nextCursor(rr records, orderColumns []string, rowsPerPage uint) (string, error) {
+	if rowsPerPage == 0 || len(orderColumns) == 0 || uint(len(rr.rows)) < rowsPerPage {
+		return "", nil
+	}
+
+	last := rr.rows[len(rr.rows)-1]
+	values := make([]any, 0, len(orderColumns))
+	for _, o := range orderColumns {
+		o = strings.TrimPrefix(strings.TrimSpace(o), "-")
+
+		found := false
+		for i, c := range rr.columns {
+			if strings.EqualFold(c, o) {
+				values = append(values, last[i])
+				found = true
+				break
+			}
+		}
+		if !found {
+			return "", errors.Errorf("cursor: order column %q is not selected", o)
+		}
+	}
+
+	return encodeCursor(values)
+}
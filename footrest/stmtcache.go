@@ -0,0 +1,237 @@
+package footrest
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"sync"
+)
+
+// stmtCache is an LRU cache of statements prepared on the connection pool, keyed by statement text.
+type stmtCache struct {
+	mu    sync.Mutex
+	lru   *list.List // of *stmtEntry, most recently used first
+	items map[string]*list.Element
+
+	hits, misses, evictions uint64
+}
+
+type stmtEntry struct {
+	strStmt string
+	stmt    *sql.Stmt
+	refs    int  // in use
+	evicted bool // closed when refs becomes 0
+}
+
+// StmtCacheStats is statistics of the prepared statement cache.
+type StmtCacheStats struct {
+	Size      int
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+}
+
+// HitRatio returns Hits / (Hits + Misses), or 0 if no lookups.
+func (s StmtCacheStats) HitRatio() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+// acquire returns a cached statement and counts a hit.
+func (c *stmtCache) acquire(strStmt string) (*stmtEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, found := c.items[strStmt]; found {
+		c.hits++
+		c.lru.MoveToFront(elem)
+		e := elem.Value.(*stmtEntry)
+		e.refs++
+		return e, true
+	}
+	return nil, false
+}
+
+// contains reports whether strStmt is cached, without counting.
+func (c *stmtCache) contains(strStmt string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, found := c.items[strStmt]
+	return found
+}
+
+// miss counts a miss.
+func (c *stmtCache) miss() {
+	c.mu.Lock()
+	c.misses++
+	c.mu.Unlock()
+}
+
+// add adds stmt in use, evicting least recently used ones over maxSize.
+// If strStmt has been added by another, stmt is closed and the existing one is returned.
+func (c *stmtCache) add(strStmt string, stmt *sql.Stmt, maxSize int) *stmtEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.items == nil {
+		c.lru = list.New()
+		c.items = make(map[string]*list.Element)
+	}
+
+	if elem, found := c.items[strStmt]; found {
+		stmt.Close()
+		e := elem.Value.(*stmtEntry)
+		e.refs++
+		return e
+	}
+
+	e := &stmtEntry{strStmt: strStmt, stmt: stmt, refs: 1}
+	c.items[strStmt] = c.lru.PushFront(e)
+
+	for c.lru.Len() > maxSize {
+		c.evict(c.lru.Back())
+		c.evictions++
+	}
+
+	return e
+}
+
+func (c *stmtCache) release(e *stmtEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e.refs--
+	if e.evicted && e.refs == 0 {
+		e.stmt.Close()
+	}
+}
+
+// evict removes elem, closing its statement unless in use. c.mu must be held.
+func (c *stmtCache) evict(elem *list.Element) {
+	e := c.lru.Remove(elem).(*stmtEntry)
+	delete(c.items, e.strStmt)
+	e.evicted = true
+	if e.refs == 0 {
+		e.stmt.Close()
+	}
+}
+
+// clear evicts all the statements.
+func (c *stmtCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.lru == nil {
+		return
+	}
+	for c.lru.Len() > 0 {
+		c.evict(c.lru.Back())
+	}
+}
+
+func (c *stmtCache) stats() StmtCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s := StmtCacheStats{
+		Hits:      c.hits,
+		Misses:    c.misses,
+		Evictions: c.evictions,
+	}
+	if c.lru != nil {
+		s.Size = c.lru.Len()
+	}
+	return s
+}
+
+// StmtCacheStats returns statistics of the prepared statement cache.
+func (r *FootREST) StmtCacheStats() StmtCacheStats {
+	return r.stmts.stats()
+}
+
+// prepare returns strStmt prepared for q (*sql.DB or *sql.Tx), and a func to be called after use.
+//
+// Statements are cached on the connection pool and bound to a transaction by tx.StmtContext.
+// On a miss in a transaction, the statement is prepared on the transaction and not cached,
+// because preparing on the pool would wait for a connection the transaction may hold.
+// Writes call prepareCache before their transactions not to miss.
+func (r *FootREST) prepare(ctx context.Context, q queryer, strStmt string) (*sql.Stmt, func(), error) {
+	tx, inTx := q.(*sql.Tx)
+
+	if r.config.Stmt.CacheSize <= 0 || r.conn == nil {
+		stmt, err := q.PrepareContext(ctx, strStmt)
+		if err != nil {
+			return nil, nil, err
+		}
+		return stmt, func() { stmt.Close() }, nil
+	}
+
+	e, found := r.stmts.acquire(strStmt)
+	if !found {
+		r.stmts.miss()
+
+		if inTx {
+			stmt, err := tx.PrepareContext(ctx, strStmt)
+			if err != nil {
+				return nil, nil, err
+			}
+			return stmt, func() { stmt.Close() }, nil
+		}
+
+		stmt, err := r.conn.PrepareContext(ctx, strStmt)
+		if err != nil {
+			return nil, nil, err
+		}
+		e = r.stmts.add(strStmt, stmt, r.config.Stmt.CacheSize)
+	}
+
+	if inTx {
+		stmt := tx.StmtContext(ctx, e.stmt)
+		return stmt, func() {
+			stmt.Close()
+			r.stmts.release(e)
+		}, nil
+	}
+	return e.stmt, func() { r.stmts.release(e) }, nil
+}
+
+// prepareCache prepares strStmts on the connection pool and caches them,
+// to be bound to a transaction begun after.
+// An error is left to the execution in the transaction.
+func (r *FootREST) prepareCache(ctx context.Context, strStmts ...string) {
+	if r.config.Stmt.CacheSize <= 0 || r.conn == nil || openTxFrom(ctx) != nil {
+		return
+	}
+
+	for _, s := range strStmts {
+		if r.stmts.contains(s) {
+			continue
+		}
+
+		stmt, err := r.conn.PrepareContext(ctx, s)
+		if err != nil {
+			continue
+		}
+		r.stmts.miss()
+		r.stmts.release(r.stmts.add(s, stmt, r.config.Stmt.CacheSize))
+	}
+}
+
+// RefreshSchema forgets introspected schemas, relations and tables,
+// closes cached prepared statements and clears cached results of GET.
+//
+// Call it after DDL.
+func (r *FootREST) RefreshSchema() {
+	r.scMut.Lock()
+	r.schemaCache = nil
+	r.relations = nil
+	r.tables = nil
+	r.keys = nil
+	r.scMut.Unlock()
+
+	r.stmts.clear()
+	r.invalidate()
+}
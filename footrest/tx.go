@@ -0,0 +1,96 @@
+package footrest
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// HeaderIsolation is a request header of an isolation level of a transaction.
+const HeaderIsolation = "X-Footrest-Isolation"
+
+type txOptionsKey struct{}
+
+// WithTxOptions returns a context whose transactions begin with opts.
+func WithTxOptions(ctx context.Context, opts *sql.TxOptions) context.Context {
+	return context.WithValue(ctx, txOptionsKey{}, opts)
+}
+
+func txOptionsFrom(ctx context.Context) *sql.TxOptions {
+	opts, _ := ctx.Value(txOptionsKey{}).(*sql.TxOptions)
+	return opts
+}
+
+// isolationContext applies an isolation level name to ctx if it is allowed by config.
+func (r *FootREST) isolationContext(ctx context.Context, name string) (context.Context, error) {
+	if name == "" {
+		return ctx, nil
+	}
+
+	lv, err := parseIsolation(name)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, allowed := range r.config.Tx.Isolations {
+		if alv, err := parseIsolation(allowed); err == nil && alv == lv {
+			return WithTxOptions(ctx, &sql.TxOptions{Isolation: lv}), nil
+		}
+	}
+
+	return nil, errors.Errorf("isolation level %q is not allowed", name)
+}
+
+// inTx runs f in a transaction and commits it.
+//
+// The transaction begins with options of WithTxOptions.
+// If retry, f is retried on an error Dialect.IsRetryable reports up to Config.Tx.Retries times.
+//
+// In an interactive transaction of ctx, f just runs in it.
+func (r *FootREST) inTx(ctx context.Context, retry bool, f func(tx *sql.Tx) error) error {
+	if otx := openTxFrom(ctx); otx != nil {
+		return f(otx.tx)
+	}
+
+	backoff := time.Duration(r.config.Tx.RetryBackoff) * time.Millisecond
+
+	for attempt := 0; ; attempt++ {
+		err := r.inTxOnce(ctx, f)
+		if err == nil {
+			return nil
+		}
+
+		if !retry || attempt >= r.config.Tx.Retries || r.dialect.IsRetryable == nil || !r.dialect.IsRetryable(err) {
+			return err
+		}
+
+		r.logger(ctx).Debug("retry", slog.Int("attempt", attempt+1), slog.String("error", err.Error()))
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func (r *FootREST) inTxOnce(ctx context.Context, f func(tx *sql.Tx) error) error {
+	tx, err := r.conn.BeginTx(ctx, txOptionsFrom(ctx))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	err = f(tx)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
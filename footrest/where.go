@@ -0,0 +1,141 @@
+package footrest
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// andWhere joins where S-exprs by AND, skipping empty ones.
+func andWhere(ws ...string) string {
+	ws = nonEmpty(ws...)
+	if len(ws) == 0 {
+		return ""
+	}
+	if len(ws) == 1 {
+		return ws[0]
+	}
+	return "(AND " + strings.Join(ws, " ") + ")"
+}
+
+// WhereExpr converts a JSON value into a where S-expr of FootREST.Get.
+//
+// The value is either an S-expr string `"(>= .id 2)"`,
+// a JSON-array form `[">=", ".id", 2]`
+// or a JSON-object form `{">=": [".id", 2]}`.
+func WhereExpr(raw json.RawMessage) (string, error) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+		return "", nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+
+	var v any
+	err := dec.Decode(&v)
+	if err != nil {
+		return "", err
+	}
+
+	if s, ok := v.(string); ok {
+		return s, nil
+	}
+	switch v.(type) {
+	case []any, map[string]any:
+	default:
+		return "", errors.Errorf("whereExpr %s is neither a string, an array nor an object", string(raw))
+	}
+
+	return jsonToSExpr(v)
+}
+
+// jsonToSExpr converts a JSON-array or JSON-object form into an S-expr.
+//
+// ["and", [">=", ".id", 2], ["like", ".text1", "aaa%"]] -> (and (>= .id #2) (like .text1 'aaa%'))
+//
+// {"and": [{">=": [".id", 2]}, {"like": [".text1", "aaa%"]}]} -> (and (>= .id #2) (like .text1 'aaa%'))
+func jsonToSExpr(v any) (string, error) {
+	switch v := v.(type) {
+	case []any:
+		if len(v) == 0 {
+			return "", errors.New("empty expression")
+		}
+		return jsonOpToSExpr(v[0], v[1:])
+
+	case map[string]any:
+		if len(v) != 1 {
+			return "", errors.Errorf("an expression object must have one operator, got %d", len(v))
+		}
+		for op, operands := range v {
+			if a, ok := operands.([]any); ok {
+				return jsonOpToSExpr(op, a)
+			}
+			return jsonOpToSExpr(op, []any{operands})
+		}
+
+	case string:
+		if strings.HasPrefix(v, ".") {
+			if strings.ContainsAny(v, " ()'") {
+				return "", errors.Errorf("invalid column name %q", v)
+			}
+			return v, nil
+		}
+		return sexprLiteral(v)
+
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return "#" + strconv.FormatInt(i, 10), nil
+		}
+		if _, err := v.Float64(); err != nil {
+			return "", err
+		}
+		return v.String(), nil
+
+	case float64:
+		return sexprLiteral(v)
+
+	case bool:
+		return strings.ToUpper(strconv.FormatBool(v)), nil
+
+	case nil:
+		return "NULL", nil
+	}
+
+	return "", errors.Errorf("%v can not be in an expression", v)
+}
+
+func jsonOpToSExpr(op any, operands []any) (string, error) {
+	name, ok := op.(string)
+	if !ok || name == "" || strings.ContainsAny(name, " ()'") {
+		return "", errors.Errorf("%v is not an operator", op)
+	}
+
+	buf := strings.Builder{}
+	buf.WriteString("(")
+	buf.WriteString(name)
+	for i, a := range operands {
+		var s string
+		if table, ok := a.(string); ok && i == 0 && strings.EqualFold(name, "select") {
+			// (select table .col (where ...))
+			if strings.ContainsAny(table, " ()'") {
+				return "", errors.Errorf("invalid table name %q", table)
+			}
+			s = table
+		} else {
+			var err error
+			s, err = jsonToSExpr(a)
+			if err != nil {
+				return "", err
+			}
+		}
+		buf.WriteString(" ")
+		buf.WriteString(s)
+	}
+	buf.WriteString(")")
+
+	return buf.String(), nil
+}
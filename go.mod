@@ -9,7 +9,6 @@ require (
 	github.com/pkg/errors v0.9.1
 	github.com/shu-go/gli/v2 v2.3.0
 	github.com/shu-go/gotwant v0.4.1
-	github.com/shu-go/rog v0.1.0
 	github.com/shu-go/stacktrace v0.0.1
 	github.com/sijms/go-ora/v2 v2.9.0
 	golang.org/x/text v0.40.0
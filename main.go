@@ -4,12 +4,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"log/slog"
 	"os"
 
 	"golang.org/x/text/encoding"
 
 	"github.com/shu-go/gli/v2"
-	"github.com/shu-go/rog"
 
 	"github.com/shu-go/footrest/footrest"
 
@@ -42,14 +42,14 @@ func (c globalCmd) Run(args []string) error {
 	}
 
 	if config.Debug {
-		rog.EnableDebug()
-		defer rog.DisableDebug()
+		config.Log.Level = "debug"
 	}
 
-	rog.Debug("type", config.DBType)
-	rog.Debug("conn", config.Connection)
-	rog.Debug("addr", config.Addr)
-	rog.Debug("root", config.Root)
+	// the connection string is not logged, it may have a password
+	footrest.NewLogger(config.Log, os.Stderr).Debug("config",
+		slog.String("type", config.DBType),
+		slog.String("addr", config.Addr),
+		slog.String("root", config.Root))
 
 	var enc encoding.Encoding
 	r, conn, err := footrest.NewConn(config.DBType, config.Connection, enc, true, &config.Config)